@@ -0,0 +1,291 @@
+package safeheap
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+)
+
+// Heap is a thread-safe binary heap ordered by a less function.
+// Pop returns the smallest element, so a heap built with a greater-than
+// function is a max-heap.
+//
+// Push returns a Handle for the element, which can later be used to update or
+// remove it in O(log n).
+type Heap[T any] struct {
+	mu       sync.Mutex
+	h        heap[T]
+	notEmpty chan struct{} // closed when an element is pushed
+}
+
+// Handle identifies an element pushed to a Heap.
+type Handle[T any] struct {
+	value T
+	index int // position in the heap, -1 once the element is removed
+	owner *heap[T]
+}
+
+// NewHeap creates a new Heap ordered by less.
+func NewHeap[T any](less func(a, b T) bool) *Heap[T] {
+	return &Heap[T]{h: heap[T]{less: less}}
+}
+
+// NewOrderedHeap creates a new min-heap of ordered values.
+func NewOrderedHeap[T cmp.Ordered]() *Heap[T] {
+	return NewHeap(cmp.Less[T])
+}
+
+// Push adds an element to the heap and returns its handle.
+func (hp *Heap[T]) Push(x T) *Handle[T] {
+	hp.mu.Lock()
+	defer hp.mu.Unlock()
+	handle := hp.h.push(x)
+	if hp.notEmpty != nil {
+		close(hp.notEmpty)
+		hp.notEmpty = nil
+	}
+	return handle
+}
+
+// Pop removes and returns the smallest element.
+// The boolean is false if the heap is empty.
+func (hp *Heap[T]) Pop() (T, bool) {
+	hp.mu.Lock()
+	defer hp.mu.Unlock()
+	if len(hp.h.items) == 0 {
+		var zero T
+		return zero, false
+	}
+	return hp.h.remove(0), true
+}
+
+// PopWait removes and returns the smallest element, waiting while the heap is
+// empty. It returns the context's error if ctx is done first.
+func (hp *Heap[T]) PopWait(ctx context.Context) (T, error) {
+	hp.mu.Lock()
+	for len(hp.h.items) == 0 {
+		if hp.notEmpty == nil {
+			hp.notEmpty = make(chan struct{})
+		}
+		ch := hp.notEmpty
+		hp.mu.Unlock()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			var zero T
+			return zero, ctx.Err()
+		}
+		hp.mu.Lock()
+	}
+	defer hp.mu.Unlock()
+	return hp.h.remove(0), nil
+}
+
+// Peek returns the smallest element without removing it.
+// The boolean is false if the heap is empty.
+func (hp *Heap[T]) Peek() (T, bool) {
+	hp.mu.Lock()
+	defer hp.mu.Unlock()
+	if len(hp.h.items) == 0 {
+		var zero T
+		return zero, false
+	}
+	return hp.h.items[0].value, true
+}
+
+// Update replaces the element identified by the handle and restores the heap order.
+// It returns false if the element is no longer in the heap.
+func (hp *Heap[T]) Update(handle *Handle[T], x T) bool {
+	hp.mu.Lock()
+	defer hp.mu.Unlock()
+	if !hp.owns(handle) {
+		return false
+	}
+	handle.value = x
+	hp.h.fix(handle.index)
+	return true
+}
+
+// Fix restores the heap order after the element identified by the handle has
+// changed in place, for example through a pointer. It returns false if the
+// element is no longer in the heap.
+func (hp *Heap[T]) Fix(handle *Handle[T]) bool {
+	hp.mu.Lock()
+	defer hp.mu.Unlock()
+	if !hp.owns(handle) {
+		return false
+	}
+	hp.h.fix(handle.index)
+	return true
+}
+
+// Remove removes the element identified by the handle and returns it.
+// The boolean is false if the element is no longer in the heap.
+func (hp *Heap[T]) Remove(handle *Handle[T]) (T, bool) {
+	hp.mu.Lock()
+	defer hp.mu.Unlock()
+	if !hp.owns(handle) {
+		var zero T
+		return zero, false
+	}
+	return hp.h.remove(handle.index), true
+}
+
+// Contains returns true if the element identified by the handle is in the heap.
+func (hp *Heap[T]) Contains(handle *Handle[T]) bool {
+	hp.mu.Lock()
+	defer hp.mu.Unlock()
+	return hp.owns(handle)
+}
+
+// Value returns the element identified by the handle.
+// The boolean is false if the element is no longer in the heap.
+func (hp *Heap[T]) Value(handle *Handle[T]) (T, bool) {
+	hp.mu.Lock()
+	defer hp.mu.Unlock()
+	if !hp.owns(handle) {
+		var zero T
+		return zero, false
+	}
+	return handle.value, true
+}
+
+// Len returns the number of elements in the heap.
+func (hp *Heap[T]) Len() int {
+	hp.mu.Lock()
+	defer hp.mu.Unlock()
+	return len(hp.h.items)
+}
+
+// IsEmpty returns true if the heap is empty.
+func (hp *Heap[T]) IsEmpty() bool {
+	return hp.Len() == 0
+}
+
+// Clear removes all elements from the heap. Existing handles become invalid.
+func (hp *Heap[T]) Clear() {
+	hp.mu.Lock()
+	defer hp.mu.Unlock()
+	hp.h.clear()
+}
+
+// Sorted returns a new slice containing the elements of the heap from smallest
+// to largest. The heap is not modified; the sort runs after the lock is released.
+func (hp *Heap[T]) Sorted() []T {
+	hp.mu.Lock()
+	values := hp.h.values()
+	less := hp.h.less
+	hp.mu.Unlock()
+	sortFunc(values, less)
+	return values
+}
+
+// owns returns true if the handle refers to an element of this heap.
+// The caller must hold the lock.
+func (hp *Heap[T]) owns(handle *Handle[T]) bool {
+	return handle != nil && handle.owner == &hp.h && handle.index >= 0
+}
+
+// heap is the unsynchronised binary heap shared by Heap and TopK.
+type heap[T any] struct {
+	items []*Handle[T]
+	less  func(a, b T) bool
+}
+
+func (h *heap[T]) push(x T) *Handle[T] {
+	handle := &Handle[T]{value: x, index: len(h.items), owner: h}
+	h.items = append(h.items, handle)
+	h.up(handle.index)
+	return handle
+}
+
+// remove removes the element at index i and returns it.
+func (h *heap[T]) remove(i int) T {
+	last := len(h.items) - 1
+	removed := h.items[i]
+	if i != last {
+		h.swap(i, last)
+	}
+	h.items[last] = nil
+	h.items = h.items[:last]
+	if i != last {
+		h.fix(i)
+	}
+	removed.index = -1
+	return removed.value
+}
+
+// fix restores the heap order after the element at index i has changed.
+func (h *heap[T]) fix(i int) {
+	if !h.down(i) {
+		h.up(i)
+	}
+}
+
+func (h *heap[T]) clear() {
+	for _, handle := range h.items {
+		handle.index = -1
+	}
+	h.items = nil
+}
+
+func (h *heap[T]) values() []T {
+	values := make([]T, len(h.items))
+	for i, handle := range h.items {
+		values[i] = handle.value
+	}
+	return values
+}
+
+func (h *heap[T]) up(i int) {
+	for i > 0 {
+		parent := (i - 1) / 2
+		if !h.less(h.items[i].value, h.items[parent].value) {
+			break
+		}
+		h.swap(i, parent)
+		i = parent
+	}
+}
+
+// down moves the element at index i towards the leaves and reports whether it moved.
+func (h *heap[T]) down(i int) bool {
+	start := i
+	n := len(h.items)
+	for {
+		smallest := 2*i + 1
+		if smallest >= n {
+			break
+		}
+		if right := smallest + 1; right < n && h.less(h.items[right].value, h.items[smallest].value) {
+			smallest = right
+		}
+		if !h.less(h.items[smallest].value, h.items[i].value) {
+			break
+		}
+		h.swap(i, smallest)
+		i = smallest
+	}
+	return i > start
+}
+
+func (h *heap[T]) swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.items[i].index = i
+	h.items[j].index = j
+}
+
+// sortFunc sorts values in ascending order according to less.
+func sortFunc[T any](values []T, less func(a, b T) bool) {
+	slices.SortFunc(values, func(a, b T) int {
+		switch {
+		case less(a, b):
+			return -1
+		case less(b, a):
+			return 1
+		default:
+			return 0
+		}
+	})
+}
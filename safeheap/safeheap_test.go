@@ -0,0 +1,188 @@
+package safeheap
+
+import (
+	"context"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeap_PushPop(t *testing.T) {
+	h := NewOrderedHeap[int]()
+	_, ok := h.Pop()
+	require.False(t, ok)
+
+	values := rand.Perm(100)
+	for _, v := range values {
+		h.Push(v)
+	}
+	require.Equal(t, 100, h.Len())
+
+	top, ok := h.Peek()
+	require.True(t, ok)
+	require.Equal(t, 0, top)
+	for i := 0; i < 100; i++ {
+		v, ok := h.Pop()
+		require.True(t, ok)
+		require.Equal(t, i, v)
+	}
+	require.True(t, h.IsEmpty())
+}
+
+func TestHeap_MaxHeap(t *testing.T) {
+	type job struct {
+		name     string
+		priority int
+	}
+	h := NewHeap(func(a, b job) bool { return a.priority > b.priority })
+	h.Push(job{"low", 1})
+	h.Push(job{"high", 10})
+	h.Push(job{"mid", 5})
+
+	j, _ := h.Pop()
+	require.Equal(t, "high", j.name)
+	j, _ = h.Pop()
+	require.Equal(t, "mid", j.name)
+}
+
+func TestHeap_Handles(t *testing.T) {
+	h := NewOrderedHeap[int]()
+	handles := make(map[int]*Handle[int])
+	for _, v := range []int{5, 3, 8, 1, 9} {
+		handles[v] = h.Push(v)
+	}
+
+	require.True(t, h.Update(handles[9], 0))
+	top, _ := h.Peek()
+	require.Equal(t, 0, top)
+	v, ok := h.Value(handles[9])
+	require.True(t, ok)
+	require.Equal(t, 0, v)
+
+	v, ok = h.Remove(handles[3])
+	require.True(t, ok)
+	require.Equal(t, 3, v)
+	require.False(t, h.Contains(handles[3]))
+	_, ok = h.Remove(handles[3])
+	require.False(t, ok)
+	require.False(t, h.Update(handles[3], 4))
+
+	require.Equal(t, []int{0, 1, 5, 8}, h.Sorted())
+	require.Equal(t, 4, h.Len())
+
+	popped, _ := h.Pop()
+	require.Equal(t, 0, popped)
+	require.False(t, h.Contains(handles[9]))
+
+	other := NewOrderedHeap[int]()
+	require.False(t, other.Contains(handles[5]))
+	require.False(t, other.Fix(handles[5]))
+
+	h.Clear()
+	require.False(t, h.Contains(handles[5]))
+}
+
+func TestHeap_Fix(t *testing.T) {
+	type task struct {
+		priority int
+	}
+	h := NewHeap(func(a, b *task) bool { return a.priority < b.priority })
+	a, b := &task{1}, &task{2}
+	h.Push(a)
+	handle := h.Push(b)
+
+	b.priority = 0
+	require.True(t, h.Fix(handle))
+	top, _ := h.Peek()
+	require.Same(t, b, top)
+}
+
+func TestHeap_RandomOperations(t *testing.T) {
+	h := NewOrderedHeap[int]()
+	var handles []*Handle[int]
+	expected := make(map[*Handle[int]]int)
+	for i := 0; i < 2000; i++ {
+		switch op := rand.IntN(4); {
+		case op < 2 || len(expected) == 0:
+			v := rand.IntN(1000)
+			handle := h.Push(v)
+			handles = append(handles, handle)
+			expected[handle] = v
+		case op == 2:
+			handle := handles[rand.IntN(len(handles))]
+			v := rand.IntN(1000)
+			_, live := expected[handle]
+			require.Equal(t, live, h.Update(handle, v))
+			if live {
+				expected[handle] = v
+			}
+		default:
+			handle := handles[rand.IntN(len(handles))]
+			_, live := expected[handle]
+			_, ok := h.Remove(handle)
+			require.Equal(t, live, ok)
+			delete(expected, handle)
+		}
+	}
+
+	want := make([]int, 0, len(expected))
+	for _, v := range expected {
+		want = append(want, v)
+	}
+	slices.Sort(want)
+	for _, w := range want {
+		v, ok := h.Pop()
+		require.True(t, ok)
+		require.Equal(t, w, v)
+	}
+	require.True(t, h.IsEmpty())
+}
+
+func TestHeap_PopWait(t *testing.T) {
+	h := NewOrderedHeap[int]()
+	result := make(chan int)
+	go func() {
+		v, err := h.PopWait(context.Background())
+		require.NoError(t, err)
+		result <- v
+	}()
+
+	select {
+	case <-result:
+		t.Fatal("PopWait returned before an element was pushed")
+	case <-time.After(20 * time.Millisecond):
+	}
+	h.Push(7)
+	require.Equal(t, 7, <-result)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := h.PopWait(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestHeap_Concurrent(t *testing.T) {
+	h := NewOrderedHeap[int]()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				handle := h.Push(g*100 + i)
+				if i%10 == 0 {
+					h.Remove(handle)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	require.Equal(t, 720, h.Len())
+
+	sorted := h.Sorted()
+	require.True(t, slices.IsSorted(sorted))
+}
@@ -0,0 +1,90 @@
+package safeheap
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// TopK is a thread-safe collection that keeps only the k largest elements
+// pushed to it, according to a less function. It is backed by a min-heap of
+// at most k elements, so Push is O(log k) regardless of how many elements
+// have been offered.
+type TopK[T any] struct {
+	mu sync.Mutex
+	h  heap[T]
+	k  int
+}
+
+// NewTopK creates a new TopK that keeps the k largest elements according to less.
+// It panics if k is not positive.
+func NewTopK[T any](k int, less func(a, b T) bool) *TopK[T] {
+	if k <= 0 {
+		panic(fmt.Sprintf("safeheap: TopK size must be positive, got %d", k))
+	}
+	return &TopK[T]{h: heap[T]{less: less}, k: k}
+}
+
+// NewOrderedTopK creates a new TopK that keeps the k largest ordered values.
+func NewOrderedTopK[T cmp.Ordered](k int) *TopK[T] {
+	return NewTopK(k, cmp.Less[T])
+}
+
+// Push offers an element and returns true if it was kept. When the collection
+// is full, the element replaces the smallest kept element if it is larger.
+func (t *TopK[T]) Push(x T) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.h.items) < t.k {
+		t.h.push(x)
+		return true
+	}
+	if !t.h.less(t.h.items[0].value, x) {
+		return false
+	}
+	t.h.items[0].value = x
+	t.h.down(0)
+	return true
+}
+
+// Min returns the smallest kept element, which is the threshold a new element
+// must exceed once the collection is full. The boolean is false if it is empty.
+func (t *TopK[T]) Min() (T, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.h.items) == 0 {
+		var zero T
+		return zero, false
+	}
+	return t.h.items[0].value, true
+}
+
+// Items returns a new slice containing the kept elements from largest to smallest.
+func (t *TopK[T]) Items() []T {
+	t.mu.Lock()
+	values := t.h.values()
+	t.mu.Unlock()
+	sortFunc(values, t.h.less)
+	slices.Reverse(values)
+	return values
+}
+
+// Len returns the number of kept elements.
+func (t *TopK[T]) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.h.items)
+}
+
+// Cap returns the maximum number of kept elements.
+func (t *TopK[T]) Cap() int {
+	return t.k
+}
+
+// Clear removes all kept elements.
+func (t *TopK[T]) Clear() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.h.clear()
+}
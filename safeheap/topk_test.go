@@ -0,0 +1,62 @@
+package safeheap
+
+import (
+	"math/rand/v2"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopK(t *testing.T) {
+	require.Panics(t, func() { NewOrderedTopK[int](0) })
+
+	top := NewOrderedTopK[int](3)
+	require.Equal(t, 3, top.Cap())
+	_, ok := top.Min()
+	require.False(t, ok)
+
+	for _, v := range rand.Perm(100) {
+		top.Push(v)
+	}
+	require.Equal(t, []int{99, 98, 97}, top.Items())
+	require.Equal(t, 3, top.Len())
+
+	threshold, ok := top.Min()
+	require.True(t, ok)
+	require.Equal(t, 97, threshold)
+	require.False(t, top.Push(50))
+	require.True(t, top.Push(100))
+	require.Equal(t, []int{100, 99, 98}, top.Items())
+
+	top.Clear()
+	require.Equal(t, 0, top.Len())
+}
+
+func TestTopK_Leaderboard(t *testing.T) {
+	type score struct {
+		player string
+		points int
+	}
+	top := NewTopK(2, func(a, b score) bool { return a.points < b.points })
+	top.Push(score{"alice", 10})
+	top.Push(score{"bob", 30})
+	top.Push(score{"carol", 20})
+	require.Equal(t, []score{{"bob", 30}, {"carol", 20}}, top.Items())
+}
+
+func TestTopK_Concurrent(t *testing.T) {
+	top := NewOrderedTopK[int](10)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				top.Push(g*1000 + i)
+			}
+		}(g)
+	}
+	wg.Wait()
+	require.Equal(t, []int{7999, 7998, 7997, 7996, 7995, 7994, 7993, 7992, 7991, 7990}, top.Items())
+}
@@ -0,0 +1,197 @@
+package safemap
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+)
+
+// ErrValueExists is returned by BiMap.Set when the value is already mapped to
+// another key and the conflict policy is RejectConflict.
+var ErrValueExists = errors.New("value already mapped to another key")
+
+// ConflictPolicy decides what BiMap.Set does when the value is already mapped
+// to a different key.
+type ConflictPolicy int
+
+const (
+	// RejectConflict leaves the map unchanged and returns ErrValueExists.
+	RejectConflict ConflictPolicy = iota
+	// ReplaceConflict removes the other key so the value maps to the new key.
+	ReplaceConflict
+)
+
+// BiMap is a thread-safe bidirectional map: every key maps to one value and
+// every value maps back to one key. Both directions are updated atomically.
+type BiMap[K comparable, V comparable] struct {
+	shared   *biMapShared
+	forward  map[K]V
+	backward map[V]K
+	inverse  *BiMap[V, K]
+}
+
+// biMapShared is the state shared by a BiMap and its inverse.
+type biMapShared struct {
+	mu     sync.RWMutex
+	policy ConflictPolicy
+}
+
+// NewBiMap creates a new BiMap that rejects conflicting values.
+func NewBiMap[K comparable, V comparable]() *BiMap[K, V] {
+	bm := &BiMap[K, V]{
+		shared:   &biMapShared{},
+		forward:  make(map[K]V),
+		backward: make(map[V]K),
+	}
+	bm.inverse = &BiMap[V, K]{
+		shared:   bm.shared,
+		forward:  bm.backward,
+		backward: bm.forward,
+		inverse:  bm,
+	}
+	return bm
+}
+
+// SetConflictPolicy sets what Set does when the value is already mapped to a
+// different key. The policy is shared with the inverse view.
+func (bm *BiMap[K, V]) SetConflictPolicy(policy ConflictPolicy) {
+	bm.shared.mu.Lock()
+	defer bm.shared.mu.Unlock()
+	bm.shared.policy = policy
+}
+
+// Inverse returns a view of the map with keys and values swapped.
+// The view shares its data and lock with bm, so changes made through either
+// are visible in both.
+func (bm *BiMap[K, V]) Inverse() *BiMap[V, K] {
+	return bm.inverse
+}
+
+// Set maps the key to the value, replacing any value previously mapped to the key.
+// If the value is already mapped to a different key, the conflict policy
+// decides whether Set returns ErrValueExists or removes the other key.
+func (bm *BiMap[K, V]) Set(k K, v V) error {
+	bm.shared.mu.Lock()
+	defer bm.shared.mu.Unlock()
+	if other, ok := bm.backward[v]; ok && other != k {
+		if bm.shared.policy == RejectConflict {
+			return fmt.Errorf("%w: %v is mapped to %v", ErrValueExists, v, other)
+		}
+		delete(bm.forward, other)
+	}
+	if old, ok := bm.forward[k]; ok {
+		delete(bm.backward, old)
+	}
+	bm.forward[k] = v
+	bm.backward[v] = k
+	return nil
+}
+
+// SetNX maps the key to the value if neither the key nor the value is mapped yet.
+// It returns true if the pair was added.
+func (bm *BiMap[K, V]) SetNX(k K, v V) bool {
+	bm.shared.mu.Lock()
+	defer bm.shared.mu.Unlock()
+	if _, ok := bm.forward[k]; ok {
+		return false
+	}
+	if _, ok := bm.backward[v]; ok {
+		return false
+	}
+	bm.forward[k] = v
+	bm.backward[v] = k
+	return true
+}
+
+// GetByKey returns the value mapped to the key.
+func (bm *BiMap[K, V]) GetByKey(k K) ValueResult[V] {
+	bm.shared.mu.RLock()
+	defer bm.shared.mu.RUnlock()
+	v, ok := bm.forward[k]
+	return ValueResult[V]{Value: v, Found: ok}
+}
+
+// GetByValue returns the key mapped to the value.
+func (bm *BiMap[K, V]) GetByValue(v V) ValueResult[K] {
+	return bm.inverse.GetByKey(v)
+}
+
+// ContainsKey returns true if the key is mapped.
+func (bm *BiMap[K, V]) ContainsKey(k K) bool {
+	return bm.GetByKey(k).Found
+}
+
+// ContainsValue returns true if the value is mapped.
+func (bm *BiMap[K, V]) ContainsValue(v V) bool {
+	return bm.GetByValue(v).Found
+}
+
+// DeleteByKey deletes the key and its value and returns the value.
+// The boolean is false if the key was not mapped.
+func (bm *BiMap[K, V]) DeleteByKey(k K) (V, bool) {
+	bm.shared.mu.Lock()
+	defer bm.shared.mu.Unlock()
+	v, ok := bm.forward[k]
+	if ok {
+		delete(bm.forward, k)
+		delete(bm.backward, v)
+	}
+	return v, ok
+}
+
+// DeleteByValue deletes the value and its key and returns the key.
+// The boolean is false if the value was not mapped.
+func (bm *BiMap[K, V]) DeleteByValue(v V) (K, bool) {
+	return bm.inverse.DeleteByKey(v)
+}
+
+// Len returns the number of key-value pairs.
+func (bm *BiMap[K, V]) Len() int {
+	bm.shared.mu.RLock()
+	defer bm.shared.mu.RUnlock()
+	return len(bm.forward)
+}
+
+// IsEmpty returns true if the map is empty.
+func (bm *BiMap[K, V]) IsEmpty() bool {
+	return bm.Len() == 0
+}
+
+// Clear deletes all key-value pairs.
+func (bm *BiMap[K, V]) Clear() {
+	bm.shared.mu.Lock()
+	defer bm.shared.mu.Unlock()
+	clear(bm.forward)
+	clear(bm.backward)
+}
+
+// GetKeys returns the keys of the map as a slice.
+func (bm *BiMap[K, V]) GetKeys() []K {
+	bm.shared.mu.RLock()
+	defer bm.shared.mu.RUnlock()
+	keys := make([]K, 0, len(bm.forward))
+	for k := range bm.forward {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// GetValues returns the values of the map as a slice.
+func (bm *BiMap[K, V]) GetValues() []V {
+	return bm.inverse.GetKeys()
+}
+
+// Export returns a new map with the same key-value pairs as the BiMap.
+func (bm *BiMap[K, V]) Export() map[K]V {
+	bm.shared.mu.RLock()
+	defer bm.shared.mu.RUnlock()
+	return maps.Clone(bm.forward)
+}
+
+// String returns a string representation of the BiMap.
+func (bm *BiMap[K, V]) String() string {
+	bm.shared.mu.RLock()
+	defer bm.shared.mu.RUnlock()
+	return fmt.Sprintf("%v", bm.forward)
+}
@@ -0,0 +1,122 @@
+package safemap
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBiMap_SetGet(t *testing.T) {
+	bm := NewBiMap[int, string]()
+	require.True(t, bm.IsEmpty())
+	require.NoError(t, bm.Set(1, "alice"))
+	require.NoError(t, bm.Set(2, "bob"))
+
+	require.Equal(t, ValueResult[string]{Value: "alice", Found: true}, bm.GetByKey(1))
+	require.Equal(t, ValueResult[int]{Value: 2, Found: true}, bm.GetByValue("bob"))
+	require.False(t, bm.GetByKey(3).Found)
+	require.False(t, bm.GetByValue("carol").Found)
+	require.True(t, bm.ContainsKey(1))
+	require.True(t, bm.ContainsValue("bob"))
+
+	// re-mapping a key releases its old value
+	require.NoError(t, bm.Set(1, "alicia"))
+	require.False(t, bm.ContainsValue("alice"))
+	require.Equal(t, map[int]string{1: "alicia", 2: "bob"}, bm.Export())
+	require.ElementsMatch(t, []int{1, 2}, bm.GetKeys())
+	require.ElementsMatch(t, []string{"alicia", "bob"}, bm.GetValues())
+	require.Equal(t, "map[1:alicia 2:bob]", bm.String())
+}
+
+func TestBiMap_Conflict(t *testing.T) {
+	bm := NewBiMap[int, string]()
+	require.NoError(t, bm.Set(1, "alice"))
+	require.NoError(t, bm.Set(1, "alice"))
+
+	err := bm.Set(2, "alice")
+	require.ErrorIs(t, err, ErrValueExists)
+	require.Equal(t, map[int]string{1: "alice"}, bm.Export())
+
+	bm.SetConflictPolicy(ReplaceConflict)
+	require.NoError(t, bm.Set(2, "alice"))
+	require.Equal(t, map[int]string{2: "alice"}, bm.Export())
+	require.Equal(t, 2, bm.GetByValue("alice").Value)
+}
+
+func TestBiMap_SetNX(t *testing.T) {
+	bm := NewBiMap[string, string]()
+	require.True(t, bm.SetNX("a", "x"))
+	require.False(t, bm.SetNX("a", "y"))
+	require.False(t, bm.SetNX("b", "x"))
+	require.Equal(t, map[string]string{"a": "x"}, bm.Export())
+}
+
+func TestBiMap_Delete(t *testing.T) {
+	bm := NewBiMap[int, string]()
+	require.NoError(t, bm.Set(1, "a"))
+	require.NoError(t, bm.Set(2, "b"))
+
+	v, ok := bm.DeleteByKey(1)
+	require.True(t, ok)
+	require.Equal(t, "a", v)
+	require.False(t, bm.ContainsValue("a"))
+	_, ok = bm.DeleteByKey(1)
+	require.False(t, ok)
+
+	k, ok := bm.DeleteByValue("b")
+	require.True(t, ok)
+	require.Equal(t, 2, k)
+	require.False(t, bm.ContainsKey(2))
+	require.Equal(t, 0, bm.Len())
+}
+
+func TestBiMap_Inverse(t *testing.T) {
+	bm := NewBiMap[int, string]()
+	inv := bm.Inverse()
+	require.Same(t, bm, inv.Inverse())
+
+	require.NoError(t, bm.Set(1, "one"))
+	require.Equal(t, 1, inv.GetByKey("one").Value)
+
+	require.NoError(t, inv.Set("two", 2))
+	require.Equal(t, "two", bm.GetByKey(2).Value)
+	require.Equal(t, 2, bm.Len())
+
+	// the policy is shared with the view
+	require.ErrorIs(t, inv.Set("uno", 1), ErrValueExists)
+	bm.SetConflictPolicy(ReplaceConflict)
+	require.NoError(t, inv.Set("uno", 1))
+	require.Equal(t, "uno", bm.GetByKey(1).Value)
+
+	inv.Clear()
+	require.True(t, bm.IsEmpty())
+}
+
+func TestBiMap_Concurrent(t *testing.T) {
+	bm := NewBiMap[int, string]()
+	bm.SetConflictPolicy(ReplaceConflict)
+	inv := bm.Inverse()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				k := (g + i) % 50
+				_ = bm.Set(k, strconv.Itoa(i%30))
+				inv.DeleteByKey(strconv.Itoa(k % 30))
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	// both directions stay consistent
+	forward := bm.Export()
+	backward := inv.Export()
+	require.Equal(t, len(forward), len(backward))
+	for k, v := range forward {
+		require.Equal(t, k, backward[v])
+	}
+}
@@ -0,0 +1,240 @@
+package safemap
+
+import (
+	"fmt"
+	"sync"
+)
+
+// BoundedMap is a thread-safe map that holds at most a fixed number of
+// key-value pairs. When a new key would exceed the capacity, the key chosen by
+// the eviction policy is removed first.
+//
+// Reads update the policy state (recency, frequency), so unlike SafeMap every
+// operation takes an exclusive lock.
+type BoundedMap[K comparable, V any] struct {
+	mu       sync.Mutex
+	m        map[K]V
+	capacity int
+	policy   EvictionPolicy[K]
+	onEvict  func(k K, v V)
+}
+
+// evicted is a key-value pair removed by the eviction policy.
+type evicted[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// NewBoundedMap creates a new BoundedMap that holds at most capacity entries
+// and evicts according to policy. It panics if capacity is not positive or
+// policy is nil.
+func NewBoundedMap[K comparable, V any](capacity int, policy EvictionPolicy[K]) *BoundedMap[K, V] {
+	if capacity <= 0 {
+		panic(fmt.Sprintf("safemap: BoundedMap capacity must be positive, got %d", capacity))
+	}
+	if policy == nil {
+		panic("safemap: BoundedMap policy must not be nil")
+	}
+	return &BoundedMap[K, V]{
+		m:        make(map[K]V, capacity),
+		capacity: capacity,
+		policy:   policy,
+	}
+}
+
+// NewLRUMap creates a new BoundedMap that evicts the least recently used key.
+func NewLRUMap[K comparable, V any](capacity int) *BoundedMap[K, V] {
+	return NewBoundedMap[K, V](capacity, NewLRUPolicy[K]())
+}
+
+// NewLFUMap creates a new BoundedMap that evicts the least frequently used key.
+func NewLFUMap[K comparable, V any](capacity int) *BoundedMap[K, V] {
+	return NewBoundedMap[K, V](capacity, NewLFUPolicy[K]())
+}
+
+// SetOnEvict sets a callback that is called for every key-value pair evicted
+// to make room for a new key. Explicit deletions do not trigger it.
+// The callback runs after the map lock is released, so it may use the map.
+func (bm *BoundedMap[K, V]) SetOnEvict(fn func(k K, v V)) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.onEvict = fn
+}
+
+// Cap returns the maximum number of key-value pairs.
+func (bm *BoundedMap[K, V]) Cap() int {
+	return bm.capacity
+}
+
+// Get returns the value associated with the key and records the access.
+func (bm *BoundedMap[K, V]) Get(k K) ValueResult[V] {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	v, ok := bm.m[k]
+	if ok {
+		bm.policy.Access(k)
+	}
+	return ValueResult[V]{Value: v, Found: ok}
+}
+
+// Peek returns the value associated with the key without recording the access.
+func (bm *BoundedMap[K, V]) Peek(k K) ValueResult[V] {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	v, ok := bm.m[k]
+	return ValueResult[V]{Value: v, Found: ok}
+}
+
+// Contains returns true if the key exists, without recording the access.
+func (bm *BoundedMap[K, V]) Contains(k K) bool {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	_, ok := bm.m[k]
+	return ok
+}
+
+// Set sets the value associated with the key, evicting another key if the map
+// is full. Updating an existing key counts as an access.
+func (bm *BoundedMap[K, V]) Set(k K, v V) {
+	bm.mu.Lock()
+	victims, onEvict := bm.setLocked(k, v), bm.onEvict
+	bm.mu.Unlock()
+	notifyEvicted(victims, onEvict)
+}
+
+// SetNX sets the value associated with the key if the key does not exist,
+// evicting another key if the map is full.
+func (bm *BoundedMap[K, V]) SetNX(k K, v V) bool {
+	bm.mu.Lock()
+	if _, ok := bm.m[k]; ok {
+		bm.mu.Unlock()
+		return false
+	}
+	victims, onEvict := bm.setLocked(k, v), bm.onEvict
+	bm.mu.Unlock()
+	notifyEvicted(victims, onEvict)
+	return true
+}
+
+// setLocked stores the pair and returns the entries evicted to make room.
+// The caller must hold the lock.
+func (bm *BoundedMap[K, V]) setLocked(k K, v V) []evicted[K, V] {
+	if _, ok := bm.m[k]; ok {
+		bm.m[k] = v
+		bm.policy.Access(k)
+		return nil
+	}
+
+	var victims []evicted[K, V]
+	for len(bm.m) >= bm.capacity {
+		victim, ok := bm.policy.Victim()
+		if !ok {
+			break
+		}
+		value, ok := bm.m[victim]
+		if !ok {
+			// the policy is out of step with the map; going over capacity
+			// beats asking it for the same key forever
+			bm.policy.Remove(victim)
+			break
+		}
+		victims = append(victims, evicted[K, V]{key: victim, value: value})
+		bm.policy.Remove(victim)
+		delete(bm.m, victim)
+	}
+	bm.m[k] = v
+	bm.policy.Add(k)
+	return victims
+}
+
+// notifyEvicted calls onEvict for each evicted entry, in eviction order.
+func notifyEvicted[K comparable, V any](victims []evicted[K, V], onEvict func(K, V)) {
+	if onEvict == nil {
+		return
+	}
+	for _, e := range victims {
+		onEvict(e.key, e.value)
+	}
+}
+
+// Delete deletes the key-value pair associated with the key.
+func (bm *BoundedMap[K, V]) Delete(k K) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	if _, ok := bm.m[k]; ok {
+		delete(bm.m, k)
+		bm.policy.Remove(k)
+	}
+}
+
+// Pop deletes the key-value pair associated with the key and returns the value.
+func (bm *BoundedMap[K, V]) Pop(k K) (V, bool) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	v, ok := bm.m[k]
+	if ok {
+		delete(bm.m, k)
+		bm.policy.Remove(k)
+	}
+	return v, ok
+}
+
+// Len returns the number of key-value pairs.
+func (bm *BoundedMap[K, V]) Len() int {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	return len(bm.m)
+}
+
+// IsEmpty returns true if the map is empty.
+func (bm *BoundedMap[K, V]) IsEmpty() bool {
+	return bm.Len() == 0
+}
+
+// Clear deletes all key-value pairs.
+func (bm *BoundedMap[K, V]) Clear() {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.m = make(map[K]V, bm.capacity)
+	bm.policy.Clear()
+}
+
+// GetKeys returns the keys of the map as a slice.
+func (bm *BoundedMap[K, V]) GetKeys() []K {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	keys := make([]K, 0, len(bm.m))
+	for k := range bm.m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// GetValues returns the values of the map as a slice.
+func (bm *BoundedMap[K, V]) GetValues() []V {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	values := make([]V, 0, len(bm.m))
+	for _, v := range bm.m {
+		values = append(values, v)
+	}
+	return values
+}
+
+// Export returns a new map with the same key-value pairs as the BoundedMap.
+func (bm *BoundedMap[K, V]) Export() map[K]V {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	m := make(map[K]V, len(bm.m))
+	for k, v := range bm.m {
+		m[k] = v
+	}
+	return m
+}
+
+// String returns a string representation of the BoundedMap.
+func (bm *BoundedMap[K, V]) String() string {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	return fmt.Sprintf("%v", bm.m)
+}
@@ -0,0 +1,182 @@
+package safemap
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewBoundedMap(t *testing.T) {
+	bm := NewLRUMap[int, string](2)
+	require.NotNil(t, bm)
+	require.Equal(t, 2, bm.Cap())
+	require.True(t, bm.IsEmpty())
+
+	require.Panics(t, func() { NewLRUMap[int, string](0) })
+	require.Panics(t, func() { NewBoundedMap[int, string](2, nil) })
+}
+
+func TestBoundedMap_LRUEviction(t *testing.T) {
+	bm := NewLRUMap[int, string](2)
+	bm.Set(1, "one")
+	bm.Set(2, "two")
+	require.True(t, bm.Get(1).Found)
+
+	bm.Set(3, "three")
+	require.Equal(t, 2, bm.Len())
+	require.True(t, bm.Contains(1))
+	require.False(t, bm.Contains(2))
+	require.True(t, bm.Contains(3))
+}
+
+func TestBoundedMap_LFUEviction(t *testing.T) {
+	bm := NewLFUMap[int, string](2)
+	bm.Set(1, "one")
+	bm.Set(2, "two")
+	bm.Get(2)
+	bm.Get(2)
+	bm.Get(1)
+
+	bm.Set(3, "three")
+	require.Equal(t, map[int]string{2: "two", 3: "three"}, bm.Export())
+}
+
+func TestBoundedMap_PeekDoesNotTouch(t *testing.T) {
+	bm := NewLRUMap[int, string](2)
+	bm.Set(1, "one")
+	bm.Set(2, "two")
+	require.Equal(t, "one", bm.Peek(1).Value)
+	bm.Set(3, "three")
+	require.False(t, bm.Contains(1))
+}
+
+func TestBoundedMap_SetExistingDoesNotEvict(t *testing.T) {
+	bm := NewLRUMap[int, string](2)
+	bm.Set(1, "one")
+	bm.Set(2, "two")
+	bm.Set(1, "uno")
+	require.Equal(t, map[int]string{1: "uno", 2: "two"}, bm.Export())
+
+	bm.Set(3, "three")
+	require.Equal(t, map[int]string{1: "uno", 3: "three"}, bm.Export())
+}
+
+func TestBoundedMap_SetNX(t *testing.T) {
+	bm := NewLRUMap[int, string](1)
+	require.True(t, bm.SetNX(1, "one"))
+	require.False(t, bm.SetNX(1, "uno"))
+	require.True(t, bm.SetNX(2, "two"))
+	require.Equal(t, map[int]string{2: "two"}, bm.Export())
+}
+
+func TestBoundedMap_OnEvict(t *testing.T) {
+	bm := NewLRUMap[int, string](2)
+	var keys []int
+	var values []string
+	bm.SetOnEvict(func(k int, v string) {
+		keys = append(keys, k)
+		values = append(values, v)
+		// the lock is released, so the callback may use the map
+		require.False(t, bm.Contains(k))
+	})
+	bm.Set(1, "one")
+	bm.Set(2, "two")
+	bm.Set(3, "three")
+	bm.Set(4, "four")
+	bm.Delete(3)
+	require.Equal(t, []int{1, 2}, keys)
+	require.Equal(t, []string{"one", "two"}, values)
+}
+
+func TestBoundedMap_DeletePop(t *testing.T) {
+	bm := NewLRUMap[int, string](2)
+	bm.Set(1, "one")
+	bm.Set(2, "two")
+	bm.Delete(1)
+	v, ok := bm.Pop(2)
+	require.True(t, ok)
+	require.Equal(t, "two", v)
+	_, ok = bm.Pop(2)
+	require.False(t, ok)
+	require.True(t, bm.IsEmpty())
+
+	bm.Set(3, "three")
+	bm.Set(4, "four")
+	require.Equal(t, 2, bm.Len())
+}
+
+func TestBoundedMap_Clear(t *testing.T) {
+	bm := NewLFUMap[int, string](2)
+	bm.Set(1, "one")
+	bm.Set(2, "two")
+	bm.Clear()
+	require.True(t, bm.IsEmpty())
+	bm.Set(3, "three")
+	require.Equal(t, []int{3}, bm.GetKeys())
+	require.Equal(t, []string{"three"}, bm.GetValues())
+	require.Equal(t, "map[3:three]", bm.String())
+}
+
+// stuckPolicy always names the same victim and never forgets it.
+type stuckPolicy struct{ victim int }
+
+func (p stuckPolicy) Add(int)             {}
+func (p stuckPolicy) Access(int)          {}
+func (p stuckPolicy) Remove(int)          {}
+func (p stuckPolicy) Victim() (int, bool) { return p.victim, true }
+func (p stuckPolicy) Clear()              {}
+
+func TestBoundedMap_PolicyOutOfStep(t *testing.T) {
+	bm := NewBoundedMap[int, string](2, stuckPolicy{victim: 1})
+	var evicted []int
+	bm.SetOnEvict(func(k int, _ string) { evicted = append(evicted, k) })
+
+	bm.Set(1, "one")
+	bm.Set(2, "two")
+	// the victim is evicted once; asking again names a key that is gone
+	bm.Set(3, "three")
+	bm.Set(4, "four")
+	require.Equal(t, []int{1}, evicted)
+	require.ElementsMatch(t, []int{2, 3, 4}, bm.GetKeys())
+
+	// a victim that was never added is not evicted either
+	bm = NewBoundedMap[int, string](1, stuckPolicy{victim: 42})
+	bm.Set(1, "one")
+	bm.Set(2, "two")
+	require.Equal(t, 2, bm.Len())
+}
+
+func TestBoundedMap_Concurrent(t *testing.T) {
+	const capacity = 64
+	for name, bm := range map[string]*BoundedMap[int, int]{
+		"LRU": NewLRUMap[int, int](capacity),
+		"LFU": NewLFUMap[int, int](capacity),
+	} {
+		t.Run(name, func(t *testing.T) {
+			var mu sync.Mutex
+			evictions := 0
+			bm.SetOnEvict(func(int, int) {
+				mu.Lock()
+				evictions++
+				mu.Unlock()
+			})
+
+			var wg sync.WaitGroup
+			for g := 0; g < 8; g++ {
+				wg.Add(1)
+				go func(g int) {
+					defer wg.Done()
+					for i := 0; i < 500; i++ {
+						bm.Set(g*500+i, i)
+						bm.Get(g*500 + i/2)
+						require.LessOrEqual(t, len(bm.Export()), capacity)
+					}
+				}(g)
+			}
+			wg.Wait()
+			require.Equal(t, capacity, bm.Len())
+			require.Equal(t, 8*500-capacity, evictions)
+		})
+	}
+}
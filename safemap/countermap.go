@@ -0,0 +1,242 @@
+package safemap
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// CounterMap is a thread-safe map of int64 counters.
+// Every counter is updated atomically while holding the map's read lock, so
+// concurrent increments of existing keys never wait for each other; only the
+// first increment of a new key and deletions take the write lock.
+type CounterMap[K comparable] struct {
+	mu sync.RWMutex
+	m  map[K]*atomic.Int64
+}
+
+// NewCounterMap creates a new CounterMap.
+func NewCounterMap[K comparable]() *CounterMap[K] {
+	return &CounterMap[K]{m: make(map[K]*atomic.Int64)}
+}
+
+// Inc increments the counter of the key by one and returns the new value.
+func (cm *CounterMap[K]) Inc(k K) int64 {
+	return cm.Add(k, 1)
+}
+
+// Add adds delta to the counter of the key and returns the new value.
+// A missing key starts at zero.
+func (cm *CounterMap[K]) Add(k K, delta int64) int64 {
+	cm.mu.RLock()
+	if c, ok := cm.m[k]; ok {
+		defer cm.mu.RUnlock()
+		return c.Add(delta)
+	}
+	cm.mu.RUnlock()
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	c, ok := cm.m[k]
+	if !ok {
+		c = new(atomic.Int64)
+		cm.m[k] = c
+	}
+	return c.Add(delta)
+}
+
+// Get returns the counter of the key, or zero if the key does not exist.
+func (cm *CounterMap[K]) Get(k K) int64 {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	if c, ok := cm.m[k]; ok {
+		return c.Load()
+	}
+	return 0
+}
+
+// Reset sets the counter of the key to zero and returns its previous value.
+// The key is kept.
+func (cm *CounterMap[K]) Reset(k K) int64 {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	if c, ok := cm.m[k]; ok {
+		return c.Swap(0)
+	}
+	return 0
+}
+
+// Delete deletes the counter of the key and returns its last value.
+func (cm *CounterMap[K]) Delete(k K) int64 {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	c, ok := cm.m[k]
+	if !ok {
+		return 0
+	}
+	delete(cm.m, k)
+	return c.Load()
+}
+
+// Len returns the number of counters.
+func (cm *CounterMap[K]) Len() int {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return len(cm.m)
+}
+
+// Clear deletes all counters.
+func (cm *CounterMap[K]) Clear() {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.m = make(map[K]*atomic.Int64)
+}
+
+// Snapshot returns a new map with the current value of every counter.
+// Counters are read one at a time, so concurrent increments may be reflected
+// for some keys and not others.
+func (cm *CounterMap[K]) Snapshot() map[K]int64 {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	snapshot := make(map[K]int64, len(cm.m))
+	for k, c := range cm.m {
+		snapshot[k] = c.Load()
+	}
+	return snapshot
+}
+
+// TopN returns the n keys with the highest counters, from highest to lowest.
+// A negative n returns all keys.
+func (cm *CounterMap[K]) TopN(n int) []Entry[K, int64] {
+	return topN(cm.Snapshot(), n)
+}
+
+// FloatCounterMap is a thread-safe map of float64 counters with the same
+// locking behaviour as CounterMap.
+type FloatCounterMap[K comparable] struct {
+	mu sync.RWMutex
+	m  map[K]*atomic.Uint64 // math.Float64bits of the value
+}
+
+// NewFloatCounterMap creates a new FloatCounterMap.
+func NewFloatCounterMap[K comparable]() *FloatCounterMap[K] {
+	return &FloatCounterMap[K]{m: make(map[K]*atomic.Uint64)}
+}
+
+// Inc increments the counter of the key by one and returns the new value.
+func (fm *FloatCounterMap[K]) Inc(k K) float64 {
+	return fm.Add(k, 1)
+}
+
+// Add adds delta to the counter of the key and returns the new value.
+// A missing key starts at zero.
+func (fm *FloatCounterMap[K]) Add(k K, delta float64) float64 {
+	fm.mu.RLock()
+	if c, ok := fm.m[k]; ok {
+		defer fm.mu.RUnlock()
+		return addFloat(c, delta)
+	}
+	fm.mu.RUnlock()
+
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	c, ok := fm.m[k]
+	if !ok {
+		c = new(atomic.Uint64)
+		fm.m[k] = c
+	}
+	return addFloat(c, delta)
+}
+
+// Get returns the counter of the key, or zero if the key does not exist.
+func (fm *FloatCounterMap[K]) Get(k K) float64 {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+	if c, ok := fm.m[k]; ok {
+		return math.Float64frombits(c.Load())
+	}
+	return 0
+}
+
+// Reset sets the counter of the key to zero and returns its previous value.
+// The key is kept.
+func (fm *FloatCounterMap[K]) Reset(k K) float64 {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+	if c, ok := fm.m[k]; ok {
+		return math.Float64frombits(c.Swap(0))
+	}
+	return 0
+}
+
+// Delete deletes the counter of the key and returns its last value.
+func (fm *FloatCounterMap[K]) Delete(k K) float64 {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	c, ok := fm.m[k]
+	if !ok {
+		return 0
+	}
+	delete(fm.m, k)
+	return math.Float64frombits(c.Load())
+}
+
+// Len returns the number of counters.
+func (fm *FloatCounterMap[K]) Len() int {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+	return len(fm.m)
+}
+
+// Clear deletes all counters.
+func (fm *FloatCounterMap[K]) Clear() {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	fm.m = make(map[K]*atomic.Uint64)
+}
+
+// Snapshot returns a new map with the current value of every counter.
+// See CounterMap.Snapshot for its consistency.
+func (fm *FloatCounterMap[K]) Snapshot() map[K]float64 {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+	snapshot := make(map[K]float64, len(fm.m))
+	for k, c := range fm.m {
+		snapshot[k] = math.Float64frombits(c.Load())
+	}
+	return snapshot
+}
+
+// TopN returns the n keys with the highest counters, from highest to lowest.
+// A negative n returns all keys.
+func (fm *FloatCounterMap[K]) TopN(n int) []Entry[K, float64] {
+	return topN(fm.Snapshot(), n)
+}
+
+// addFloat atomically adds delta to the float64 stored in c and returns the result.
+func addFloat(c *atomic.Uint64, delta float64) float64 {
+	for {
+		old := c.Load()
+		sum := math.Float64frombits(old) + delta
+		if c.CompareAndSwap(old, math.Float64bits(sum)) {
+			return sum
+		}
+	}
+}
+
+// topN returns the n entries of snapshot with the highest values, from highest to lowest.
+func topN[K comparable, N int64 | float64](snapshot map[K]N, n int) []Entry[K, N] {
+	entries := make([]Entry[K, N], 0, len(snapshot))
+	for k, v := range snapshot {
+		entries = append(entries, Entry[K, N]{Key: k, Value: v})
+	}
+	slices.SortFunc(entries, func(a, b Entry[K, N]) int {
+		return cmp.Compare(b.Value, a.Value)
+	})
+	if n >= 0 && n < len(entries) {
+		entries = entries[:n]
+	}
+	return entries
+}
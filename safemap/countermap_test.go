@@ -0,0 +1,163 @@
+package safemap
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCounterMap(t *testing.T) {
+	cm := NewCounterMap[string]()
+	require.Equal(t, int64(0), cm.Get("a"))
+	require.Equal(t, int64(1), cm.Inc("a"))
+	require.Equal(t, int64(6), cm.Add("a", 5))
+	require.Equal(t, int64(-2), cm.Add("b", -2))
+	require.Equal(t, int64(6), cm.Get("a"))
+	require.Equal(t, 2, cm.Len())
+
+	require.Equal(t, int64(6), cm.Reset("a"))
+	require.Equal(t, int64(0), cm.Get("a"))
+	require.Equal(t, 2, cm.Len())
+	require.Equal(t, int64(0), cm.Reset("missing"))
+
+	require.Equal(t, int64(-2), cm.Delete("b"))
+	require.Equal(t, int64(0), cm.Delete("b"))
+	require.Equal(t, map[string]int64{"a": 0}, cm.Snapshot())
+
+	cm.Clear()
+	require.Equal(t, 0, cm.Len())
+}
+
+func TestCounterMap_TopN(t *testing.T) {
+	cm := NewCounterMap[string]()
+	cm.Add("a", 1)
+	cm.Add("b", 30)
+	cm.Add("c", 20)
+	require.Equal(t, []Entry[string, int64]{{"b", 30}, {"c", 20}}, cm.TopN(2))
+	require.Len(t, cm.TopN(5), 3)
+	require.Len(t, cm.TopN(-1), 3)
+}
+
+func TestCounterMap_Concurrent(t *testing.T) {
+	cm := NewCounterMap[int]()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				cm.Inc(i % 10)
+			}
+		}()
+	}
+	wg.Wait()
+	for k := 0; k < 10; k++ {
+		require.Equal(t, int64(800), cm.Get(k))
+	}
+}
+
+func TestFloatCounterMap(t *testing.T) {
+	fm := NewFloatCounterMap[string]()
+	require.Equal(t, 1.0, fm.Inc("latency"))
+	require.Equal(t, 1.25, fm.Add("latency", 0.25))
+	fm.Add("errors", 3)
+	require.Equal(t, 1.25, fm.Get("latency"))
+	require.Equal(t, []Entry[string, float64]{{"errors", 3}}, fm.TopN(1))
+
+	require.Equal(t, 1.25, fm.Reset("latency"))
+	require.Equal(t, 0.0, fm.Get("latency"))
+	require.Equal(t, 3.0, fm.Delete("errors"))
+	require.Equal(t, map[string]float64{"latency": 0}, fm.Snapshot())
+	fm.Clear()
+	require.Equal(t, 0, fm.Len())
+}
+
+func TestFloatCounterMap_Concurrent(t *testing.T) {
+	fm := NewFloatCounterMap[string]()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				fm.Add("x", 0.5)
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, 4000.0, fm.Get("x"))
+}
+
+func TestCounterMap_WritePrometheus(t *testing.T) {
+	cm := NewCounterMap[string]()
+	cm.Add("/users", 3)
+	cm.Add(`/say"hi"`, 1)
+	cm.Add("/", 10)
+
+	var buf bytes.Buffer
+	err := cm.WritePrometheus(&buf, PrometheusOptions{
+		Name:  "http_requests_total",
+		Help:  "Requests by path.",
+		Label: "path",
+	})
+	require.NoError(t, err)
+	require.Equal(t, `# HELP http_requests_total Requests by path.
+# TYPE http_requests_total counter
+http_requests_total{path="/"} 10
+http_requests_total{path="/say\"hi\""} 1
+http_requests_total{path="/users"} 3
+`, buf.String())
+
+	require.Error(t, cm.WritePrometheus(&buf, PrometheusOptions{}))
+}
+
+func TestWritePrometheus_InvalidOptions(t *testing.T) {
+	cm := NewCounterMap[string]()
+	cm.Inc("a")
+
+	var buf bytes.Buffer
+	require.NoError(t, cm.WritePrometheus(&buf, PrometheusOptions{Name: "ns:requests_total", Label: "_path2"}))
+	for _, opts := range []PrometheusOptions{
+		{Name: "http-requests"},
+		{Name: "2xx_total"},
+		{Name: "requests total"},
+		{Name: "requests", Label: "path:full"},
+		{Name: "requests", Label: "0path"},
+		{Name: "requests", Label: `path"`},
+		{Name: "requests", Type: "gauage"},
+		{Name: "requests", Type: "counter\nrequests 1"},
+	} {
+		buf.Reset()
+		require.Error(t, cm.WritePrometheus(&buf, opts), "%+v", opts)
+		require.Empty(t, buf.String())
+	}
+}
+
+func TestWritePrometheus_CollidingLabels(t *testing.T) {
+	cm := NewCounterMap[any]()
+	cm.Inc(1)
+	cm.Inc("1")
+
+	var buf bytes.Buffer
+	err := cm.WritePrometheus(&buf, PrometheusOptions{Name: "requests_total"})
+	require.ErrorContains(t, err, `same Prometheus label value "1"`)
+	require.Empty(t, buf.String())
+
+	cm.Delete("1")
+	require.NoError(t, cm.WritePrometheus(&buf, PrometheusOptions{Name: "requests_total"}))
+}
+
+func TestFloatCounterMap_WritePrometheus(t *testing.T) {
+	fm := NewFloatCounterMap[int]()
+	fm.Add(1, 0.5)
+	fm.Add(2, 1e21)
+
+	var buf bytes.Buffer
+	require.NoError(t, fm.WritePrometheus(&buf, PrometheusOptions{Name: "queue_seconds", Type: "gauge"}))
+	require.Equal(t, `# TYPE queue_seconds gauge
+queue_seconds{key="1"} 0.5
+queue_seconds{key="2"} 1e+21
+`, buf.String())
+}
@@ -0,0 +1,12 @@
+// Package safemap provides thread-safe maps: SafeMap and ShardedMap, and maps
+// with additional structure or behaviour built in the same style, such as
+// SortedMap, OrderedMap, BiMap, MultiMap, CounterMap, LoadingMap and
+// PersistentMap.
+//
+// # Iteration
+//
+// The iterators of the map types (All, Keys, Values and their variants) never
+// hold a lock while the loop body runs, so the body may freely read or modify
+// the map. Unless a method documents otherwise, the sequence reflects the map
+// at the moment iteration starts.
+package safemap
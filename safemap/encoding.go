@@ -0,0 +1,286 @@
+package safemap
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+var (
+	_ json.Marshaler             = (*SafeMap[string, int])(nil)
+	_ json.Unmarshaler           = (*SafeMap[string, int])(nil)
+	_ gob.GobEncoder             = (*SafeMap[string, int])(nil)
+	_ gob.GobDecoder             = (*SafeMap[string, int])(nil)
+	_ encoding.BinaryMarshaler   = (*SafeMap[string, int])(nil)
+	_ encoding.BinaryUnmarshaler = (*SafeMap[string, int])(nil)
+	_ json.Marshaler             = (*OrderedMap[string, int])(nil)
+	_ json.Unmarshaler           = (*OrderedMap[string, int])(nil)
+)
+
+var (
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// jsonObjectKey returns true if encoding/json can use K as the key of a JSON
+// object and decode it back: strings, integers and text marshalers.
+func jsonObjectKey[K comparable]() bool {
+	t := reflect.TypeFor[K]()
+	if t.Implements(textMarshalerType) && reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	default:
+		return false
+	}
+}
+
+// marshalMapJSON encodes m as a JSON object if K can be an object key and as
+// an array of [key, value] pairs otherwise.
+func marshalMapJSON[K comparable, V any](m map[K]V) ([]byte, error) {
+	if jsonObjectKey[K]() {
+		return json.Marshal(m)
+	}
+	pairs := make([][2]any, 0, len(m))
+	for k, v := range m {
+		pairs = append(pairs, [2]any{k, v})
+	}
+	return json.Marshal(pairs)
+}
+
+// unmarshalMapJSON decodes a JSON object or an array of [key, value] pairs.
+func unmarshalMapJSON[K comparable, V any](data []byte) (map[K]V, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '[' {
+		m := make(map[K]V)
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+
+	entries, err := unmarshalPairsJSON[K, V](data)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[K]V, len(entries))
+	for _, e := range entries {
+		m[e.Key] = e.Value
+	}
+	return m, nil
+}
+
+// unmarshalPairsJSON decodes an array of [key, value] pairs.
+func unmarshalPairsJSON[K comparable, V any](data []byte) ([]Entry[K, V], error) {
+	var pairs [][2]json.RawMessage
+	if err := json.Unmarshal(data, &pairs); err != nil {
+		return nil, err
+	}
+	entries := make([]Entry[K, V], len(pairs))
+	for i, pair := range pairs {
+		if err := json.Unmarshal(pair[0], &entries[i].Key); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(pair[1], &entries[i].Value); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// marshalEntriesJSON encodes entries like marshalMapJSON, keeping their order.
+func marshalEntriesJSON[K comparable, V any](entries []Entry[K, V]) ([]byte, error) {
+	if !jsonObjectKey[K]() {
+		pairs := make([][2]any, len(entries))
+		for i, e := range entries {
+			pairs[i] = [2]any{e.Key, e.Value}
+		}
+		return json.Marshal(pairs)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, e := range entries {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := encodeJSONKey(e.Key)
+		if err != nil {
+			return nil, err
+		}
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// unmarshalEntriesJSON decodes a JSON object or an array of [key, value]
+// pairs, keeping the order of the input. It returns nil for a JSON null.
+func unmarshalEntriesJSON[K comparable, V any](data []byte) ([]Entry[K, V], error) {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		return nil, nil
+	}
+	if len(data) > 0 && data[0] == '[' {
+		return unmarshalPairsJSON[K, V](data)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil {
+		return nil, err
+	} else if tok != json.Delim('{') {
+		return nil, fmt.Errorf("safemap: cannot decode %v into an ordered map", tok)
+	}
+	entries := make([]Entry[K, V], 0)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		k, err := decodeJSONKey[K](tok.(string))
+		if err != nil {
+			return nil, err
+		}
+		var v V
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		entries = append(entries, Entry[K, V]{Key: k, Value: v})
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// encodeJSONKey returns the object member name encoding/json uses for k.
+func encodeJSONKey[K comparable](k K) (string, error) {
+	rv := reflect.ValueOf(&k).Elem()
+	if rv.Kind() == reflect.String {
+		return rv.String(), nil
+	}
+	if tm, ok := any(k).(encoding.TextMarshaler); ok {
+		text, err := tm.MarshalText()
+		return string(text), err
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	default:
+		return "", fmt.Errorf("safemap: unsupported JSON key type %T", k)
+	}
+}
+
+// decodeJSONKey parses an object member name into a key the way encoding/json does.
+func decodeJSONKey[K comparable](name string) (K, error) {
+	var k K
+	rv := reflect.ValueOf(&k).Elem()
+	if tu, ok := any(&k).(encoding.TextUnmarshaler); ok {
+		return k, tu.UnmarshalText([]byte(name))
+	}
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(name)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(name, 10, 64)
+		if err != nil || rv.OverflowInt(n) {
+			return k, fmt.Errorf("safemap: invalid JSON key %q for %T", name, k)
+		}
+		rv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(name, 10, 64)
+		if err != nil || rv.OverflowUint(n) {
+			return k, fmt.Errorf("safemap: invalid JSON key %q for %T", name, k)
+		}
+		rv.SetUint(n)
+	default:
+		return k, fmt.Errorf("safemap: unsupported JSON key type %T", k)
+	}
+	return k, nil
+}
+
+// MarshalJSON implements json.Marshaler. Maps with string, integer or
+// encoding.TextMarshaler keys are encoded as a JSON object; any other map is
+// encoded as an array of [key, value] pairs.
+func (sm *SafeMap[K, V]) MarshalJSON() ([]byte, error) {
+	return marshalMapJSON(sm.Export())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It accepts both encodings
+// produced by MarshalJSON and atomically replaces the contents of the map.
+func (sm *SafeMap[K, V]) UnmarshalJSON(data []byte) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		return nil
+	}
+	m, err := unmarshalMapJSON[K, V](data)
+	if err != nil {
+		return err
+	}
+	sm.replace(m)
+	return nil
+}
+
+// GobEncode implements gob.GobEncoder.
+func (sm *SafeMap[K, V]) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(sm.Export()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode implements gob.GobDecoder. It atomically replaces the contents of the map.
+func (sm *SafeMap[K, V]) GobDecode(data []byte) error {
+	m := make(map[K]V)
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&m); err != nil {
+		return err
+	}
+	sm.replace(m)
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler using the gob encoding.
+func (sm *SafeMap[K, V]) MarshalBinary() ([]byte, error) {
+	return sm.GobEncode()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler using the gob encoding.
+func (sm *SafeMap[K, V]) UnmarshalBinary(data []byte) error {
+	return sm.GobDecode(data)
+}
+
+// replace atomically replaces the contents of the map with m, which must not
+// be used by the caller afterwards. Subscribers see the old keys cleared and
+// the new keys set.
+func (sm *SafeMap[K, V]) replace(m map[K]V) {
+	sm.Lock()
+	defer sm.Unlock()
+	sm.clearLocked()
+	sm.data = segmentedMapFrom(m)
+	if len(sm.subs) > 0 {
+		var zero V
+		for k, v := range m {
+			sm.publishSet(k, zero, false, v)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package safemap
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSafeMap_MarshalJSONObject(t *testing.T) {
+	sm := NewSafeMapFromMap(map[string]int{"a": 1, "b": 2})
+	data, err := json.Marshal(sm)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"a":1,"b":2}`, string(data))
+
+	intKeys := NewSafeMapFromMap(map[int]string{1: "one"})
+	data, err = json.Marshal(intKeys)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"1":"one"}`, string(data))
+}
+
+func TestSafeMap_MarshalJSONPairs(t *testing.T) {
+	type point struct {
+		X, Y int
+	}
+	sm := NewSafeMapFromMap(map[point]string{{1, 2}: "a"})
+	data, err := json.Marshal(sm)
+	require.NoError(t, err)
+	require.JSONEq(t, `[[{"X":1,"Y":2},"a"]]`, string(data))
+
+	decoded := NewSafeMap[point, string]()
+	require.NoError(t, json.Unmarshal(data, decoded))
+	require.Equal(t, sm.Export(), decoded.Export())
+}
+
+func TestSafeMap_UnmarshalJSON(t *testing.T) {
+	sm := NewSafeMapFromMap(map[string]int{"old": 1})
+	require.NoError(t, json.Unmarshal([]byte(`{"a":1,"b":2}`), sm))
+	require.Equal(t, map[string]int{"a": 1, "b": 2}, sm.Export())
+
+	require.NoError(t, json.Unmarshal([]byte(`[["c",3]]`), sm))
+	require.Equal(t, map[string]int{"c": 3}, sm.Export())
+
+	require.Error(t, json.Unmarshal([]byte(`{"a":"x"}`), sm))
+	require.Equal(t, map[string]int{"c": 3}, sm.Export())
+}
+
+func TestSafeMap_JSONEmbedded(t *testing.T) {
+	type config struct {
+		Name     string
+		Settings *SafeMap[string, int]
+	}
+	in := config{Name: "cfg", Settings: NewSafeMapFromMap(map[string]int{"retries": 3})}
+	data, err := json.Marshal(in)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"Name":"cfg","Settings":{"retries":3}}`, string(data))
+
+	var out config
+	require.NoError(t, json.Unmarshal(data, &out))
+	require.Equal(t, "cfg", out.Name)
+	require.Equal(t, 3, out.Settings.Get("retries").Value)
+	out.Settings.Set("timeout", 10)
+	require.Equal(t, 2, out.Settings.Len())
+}
+
+func TestSafeMap_UnmarshalJSONEvents(t *testing.T) {
+	sm := NewSafeMapFromMap(map[string]int{"old": 1})
+	sub := sm.Subscribe(SubscribeOptions[string]{})
+	defer sub.Close()
+	require.NoError(t, json.Unmarshal([]byte(`{"new":2}`), sm))
+	require.Equal(t, Event[string, int]{Op: OpClear, Key: "old", OldValue: 1, Existed: true}, receive(t, sub.Events()))
+	require.Equal(t, Event[string, int]{Op: OpSet, Key: "new", NewValue: 2}, receive(t, sub.Events()))
+}
+
+func TestSafeMap_Gob(t *testing.T) {
+	type state struct {
+		Counters *SafeMap[string, int]
+	}
+	in := state{Counters: NewSafeMapFromMap(map[string]int{"a": 1, "b": 2})}
+	var buf bytes.Buffer
+	require.NoError(t, gob.NewEncoder(&buf).Encode(in))
+
+	var out state
+	require.NoError(t, gob.NewDecoder(&buf).Decode(&out))
+	require.Equal(t, in.Counters.Export(), out.Counters.Export())
+}
+
+func TestSafeMap_Binary(t *testing.T) {
+	sm := NewSafeMapFromMap(map[int]string{1: "one", 2: "two"})
+	data, err := sm.MarshalBinary()
+	require.NoError(t, err)
+
+	decoded := NewSafeMapFromMap(map[int]string{3: "three"})
+	require.NoError(t, decoded.UnmarshalBinary(data))
+	require.Equal(t, sm.Export(), decoded.Export())
+
+	require.Error(t, decoded.UnmarshalBinary([]byte("garbage")))
+	require.Equal(t, sm.Export(), decoded.Export())
+}
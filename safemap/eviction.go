@@ -0,0 +1,180 @@
+package safemap
+
+import (
+	"container/list"
+)
+
+// EvictionPolicy decides which key a BoundedMap evicts when it is full.
+// Implementations are not required to be thread-safe; BoundedMap only calls
+// them while holding its lock.
+type EvictionPolicy[K comparable] interface {
+	// Add records that the key was inserted.
+	Add(k K)
+	// Access records that the key was read or updated.
+	Access(k K)
+	// Remove forgets the key.
+	Remove(k K)
+	// Victim returns the key that should be evicted next, which must be a key
+	// added and not removed since. The boolean result is false if the policy
+	// tracks no keys. If it reports no key or one the map does not hold, the
+	// BoundedMap stops evicting and may exceed its capacity.
+	Victim() (K, bool)
+	// Clear forgets all keys.
+	Clear()
+}
+
+// LRUPolicy evicts the least recently used key.
+type LRUPolicy[K comparable] struct {
+	order *list.List // front is the most recently used key
+	items map[K]*list.Element
+}
+
+// NewLRUPolicy creates a new LRUPolicy.
+func NewLRUPolicy[K comparable]() *LRUPolicy[K] {
+	return &LRUPolicy[K]{
+		order: list.New(),
+		items: make(map[K]*list.Element),
+	}
+}
+
+// Add records that the key was inserted.
+func (p *LRUPolicy[K]) Add(k K) {
+	if e, ok := p.items[k]; ok {
+		p.order.MoveToFront(e)
+		return
+	}
+	p.items[k] = p.order.PushFront(k)
+}
+
+// Access records that the key was read or updated.
+func (p *LRUPolicy[K]) Access(k K) {
+	if e, ok := p.items[k]; ok {
+		p.order.MoveToFront(e)
+	}
+}
+
+// Remove forgets the key.
+func (p *LRUPolicy[K]) Remove(k K) {
+	if e, ok := p.items[k]; ok {
+		p.order.Remove(e)
+		delete(p.items, k)
+	}
+}
+
+// Victim returns the least recently used key.
+func (p *LRUPolicy[K]) Victim() (K, bool) {
+	e := p.order.Back()
+	if e == nil {
+		var zero K
+		return zero, false
+	}
+	return e.Value.(K), true
+}
+
+// Clear forgets all keys.
+func (p *LRUPolicy[K]) Clear() {
+	p.order.Init()
+	p.items = make(map[K]*list.Element)
+}
+
+// LFUPolicy evicts the least frequently used key. Ties are broken by evicting
+// the least recently used of the least frequently used keys.
+// All operations run in constant time.
+type LFUPolicy[K comparable] struct {
+	buckets *list.List // of *lfuBucket, ordered by ascending frequency
+	items   map[K]*lfuItem[K]
+}
+
+// lfuBucket holds all keys that have been accessed freq times.
+type lfuBucket struct {
+	freq  int
+	items *list.List // front is the most recently used key
+}
+
+// lfuItem tracks the position of a key in the LFU buckets.
+type lfuItem[K comparable] struct {
+	bucket *list.Element
+	elem   *list.Element
+}
+
+// NewLFUPolicy creates a new LFUPolicy.
+func NewLFUPolicy[K comparable]() *LFUPolicy[K] {
+	return &LFUPolicy[K]{
+		buckets: list.New(),
+		items:   make(map[K]*lfuItem[K]),
+	}
+}
+
+// Add records that the key was inserted. New keys start with a frequency of one.
+func (p *LFUPolicy[K]) Add(k K) {
+	if _, ok := p.items[k]; ok {
+		p.Access(k)
+		return
+	}
+	front := p.buckets.Front()
+	if front == nil || front.Value.(*lfuBucket).freq != 1 {
+		front = p.buckets.PushFront(&lfuBucket{freq: 1, items: list.New()})
+	}
+	p.items[k] = &lfuItem[K]{
+		bucket: front,
+		elem:   front.Value.(*lfuBucket).items.PushFront(k),
+	}
+}
+
+// Access increments the frequency of the key.
+func (p *LFUPolicy[K]) Access(k K) {
+	item, ok := p.items[k]
+	if !ok {
+		return
+	}
+	cur := item.bucket
+	freq := cur.Value.(*lfuBucket).freq
+	next := cur.Next()
+	if next == nil || next.Value.(*lfuBucket).freq != freq+1 {
+		next = p.buckets.InsertAfter(&lfuBucket{freq: freq + 1, items: list.New()}, cur)
+	}
+	p.unlink(item)
+	item.bucket = next
+	item.elem = next.Value.(*lfuBucket).items.PushFront(k)
+}
+
+// Remove forgets the key.
+func (p *LFUPolicy[K]) Remove(k K) {
+	if item, ok := p.items[k]; ok {
+		p.unlink(item)
+		delete(p.items, k)
+	}
+}
+
+// Victim returns the least frequently used key.
+func (p *LFUPolicy[K]) Victim() (K, bool) {
+	front := p.buckets.Front()
+	if front == nil {
+		var zero K
+		return zero, false
+	}
+	return front.Value.(*lfuBucket).items.Back().Value.(K), true
+}
+
+// Clear forgets all keys.
+func (p *LFUPolicy[K]) Clear() {
+	p.buckets.Init()
+	p.items = make(map[K]*lfuItem[K])
+}
+
+// Frequency returns the access frequency of the key, or 0 if it is not tracked.
+func (p *LFUPolicy[K]) Frequency(k K) int {
+	if item, ok := p.items[k]; ok {
+		return item.bucket.Value.(*lfuBucket).freq
+	}
+	return 0
+}
+
+// unlink removes the item from its bucket and drops the bucket if it is empty.
+func (p *LFUPolicy[K]) unlink(item *lfuItem[K]) {
+	b := item.bucket.Value.(*lfuBucket)
+	b.items.Remove(item.elem)
+	if b.items.Len() == 0 {
+		p.buckets.Remove(item.bucket)
+	}
+}
@@ -0,0 +1,76 @@
+package safemap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRUPolicy(t *testing.T) {
+	p := NewLRUPolicy[int]()
+	_, ok := p.Victim()
+	require.False(t, ok)
+
+	p.Add(1)
+	p.Add(2)
+	p.Add(3)
+	v, ok := p.Victim()
+	require.True(t, ok)
+	require.Equal(t, 1, v)
+
+	p.Access(1)
+	v, _ = p.Victim()
+	require.Equal(t, 2, v)
+
+	p.Remove(2)
+	v, _ = p.Victim()
+	require.Equal(t, 3, v)
+
+	p.Clear()
+	_, ok = p.Victim()
+	require.False(t, ok)
+}
+
+func TestLFUPolicy(t *testing.T) {
+	p := NewLFUPolicy[string]()
+	_, ok := p.Victim()
+	require.False(t, ok)
+
+	p.Add("a")
+	p.Add("b")
+	p.Add("c")
+	p.Access("a")
+	p.Access("a")
+	p.Access("b")
+	require.Equal(t, 3, p.Frequency("a"))
+	require.Equal(t, 2, p.Frequency("b"))
+	require.Equal(t, 1, p.Frequency("c"))
+	require.Equal(t, 0, p.Frequency("d"))
+
+	v, ok := p.Victim()
+	require.True(t, ok)
+	require.Equal(t, "c", v)
+
+	p.Remove("c")
+	v, _ = p.Victim()
+	require.Equal(t, "b", v)
+
+	p.Access("b")
+	p.Access("b")
+	v, _ = p.Victim()
+	require.Equal(t, "a", v)
+
+	p.Clear()
+	_, ok = p.Victim()
+	require.False(t, ok)
+}
+
+func TestLFUPolicy_TieBreaksByRecency(t *testing.T) {
+	p := NewLFUPolicy[int]()
+	p.Add(1)
+	p.Add(2)
+	p.Access(1)
+	p.Access(2)
+	v, _ := p.Victim()
+	require.Equal(t, 1, v)
+}
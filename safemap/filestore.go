@@ -0,0 +1,149 @@
+package safemap
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var _ Store[string, int] = (*FileStore[string, int])(nil)
+
+// FileStore is a Store that keeps all key-value pairs in a single JSON file,
+// using the same encoding as SafeMap.MarshalJSON.
+// Put and Delete change an in-memory copy; Flush rewrites the file by writing
+// a temporary file and renaming it over the old one, so a crash never leaves
+// a partially written file behind. The rewrite keeps the permissions of an
+// existing file; a new file is created with mode 0644.
+type FileStore[K comparable, V any] struct {
+	mu     sync.Mutex
+	path   string
+	m      map[K]V
+	loaded bool
+	dirty  bool
+}
+
+// NewFileStore creates a new FileStore backed by the file at path.
+// The file is created by the first Flush if it does not exist.
+func NewFileStore[K comparable, V any](path string) *FileStore[K, V] {
+	return &FileStore[K, V]{path: path}
+}
+
+// Load returns all key-value pairs stored in the file.
+func (fs *FileStore[K, V]) Load(ctx context.Context) (map[K]V, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fs.load(); err != nil {
+		return nil, err
+	}
+	return maps.Clone(fs.m), nil
+}
+
+// Put stores the value of the key. It is written to the file by Flush.
+func (fs *FileStore[K, V]) Put(ctx context.Context, k K, v V) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fs.load(); err != nil {
+		return err
+	}
+	fs.m[k] = v
+	fs.dirty = true
+	return nil
+}
+
+// Delete removes the key. It is removed from the file by Flush.
+func (fs *FileStore[K, V]) Delete(ctx context.Context, k K) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fs.load(); err != nil {
+		return err
+	}
+	if _, ok := fs.m[k]; ok {
+		delete(fs.m, k)
+		fs.dirty = true
+	}
+	return nil
+}
+
+// Flush atomically rewrites the file if anything changed since the last Flush.
+func (fs *FileStore[K, V]) Flush(ctx context.Context) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if !fs.dirty {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := marshalMapJSON(fs.m)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(fs.path, data); err != nil {
+		return err
+	}
+	fs.dirty = false
+	return nil
+}
+
+// load reads the file the first time the store is used. A missing file is
+// an empty store. The caller must hold fs.mu.
+func (fs *FileStore[K, V]) load() error {
+	if fs.loaded {
+		return nil
+	}
+	data, err := os.ReadFile(fs.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		fs.m = make(map[K]V)
+	case err != nil:
+		return err
+	default:
+		if fs.m, err = unmarshalMapJSON[K, V](data); err != nil {
+			return err
+		}
+	}
+	fs.loaded = true
+	return nil
+}
+
+// defaultFileMode is the mode of a store file that did not exist before.
+const defaultFileMode os.FileMode = 0o644
+
+// writeFileAtomic writes data to a temporary file in the same directory as
+// path, syncs it and renames it to path. The file keeps the permissions of
+// the file it replaces, or gets defaultFileMode if there is none.
+func writeFileAtomic(path string, data []byte) (err error) {
+	mode := defaultFileMode
+	switch fi, err := os.Stat(path); {
+	case err == nil:
+		mode = fi.Mode().Perm()
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	if err = tmp.Chmod(mode); err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,154 @@
+package safemap
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	fs := NewFileStore[string, int](path)
+	m, err := fs.Load(ctx)
+	require.NoError(t, err)
+	require.Empty(t, m)
+
+	require.NoError(t, fs.Put(ctx, "a", 1))
+	require.NoError(t, fs.Put(ctx, "b", 2))
+	require.NoError(t, fs.Delete(ctx, "b"))
+	require.NoError(t, fs.Delete(ctx, "missing"))
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err), "nothing is written before Flush")
+
+	require.NoError(t, fs.Flush(ctx))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"a":1}`, string(data))
+
+	m, err = NewFileStore[string, int](path).Load(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"a": 1}, m)
+}
+
+func TestFileStore_LoadReturnsCopy(t *testing.T) {
+	ctx := context.Background()
+	fs := NewFileStore[string, int](filepath.Join(t.TempDir(), "state.json"))
+	require.NoError(t, fs.Put(ctx, "a", 1))
+
+	m, err := fs.Load(ctx)
+	require.NoError(t, err)
+	m["b"] = 2
+	m, err = fs.Load(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"a": 1}, m)
+}
+
+func TestFileStore_FlushOnlyWhenDirty(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state.json")
+	fs := NewFileStore[string, int](path)
+
+	require.NoError(t, fs.Flush(ctx))
+	_, err := os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+
+	require.NoError(t, fs.Put(ctx, "a", 1))
+	require.NoError(t, fs.Flush(ctx))
+	require.NoError(t, os.Remove(path))
+	require.NoError(t, fs.Flush(ctx))
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err), "a clean store is not rewritten")
+
+	// deleting a missing key does not make the store dirty
+	require.NoError(t, fs.Delete(ctx, "missing"))
+	require.NoError(t, fs.Flush(ctx))
+	_, err = os.Stat(path)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestFileStore_FlushError(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	fs := NewFileStore[string, int](filepath.Join(dir, "missing", "state.json"))
+	require.NoError(t, fs.Put(ctx, "a", 1))
+	require.Error(t, fs.Flush(ctx))
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	path := filepath.Join(dir, "state.json")
+	fs = NewFileStore[string, int](path)
+	require.NoError(t, fs.Put(ctx, "a", 1))
+	require.ErrorIs(t, fs.Flush(cancelled), context.Canceled)
+
+	// the change is still pending and written by the next Flush
+	require.NoError(t, fs.Flush(ctx))
+	m, err := NewFileStore[string, int](path).Load(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"a": 1}, m)
+}
+
+func TestFileStore_AtomicRewrite(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	fs := NewFileStore[string, int](path)
+	for i := range 3 {
+		require.NoError(t, fs.Put(ctx, "a", i))
+		require.NoError(t, fs.Flush(ctx))
+	}
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "state.json", entries[0].Name())
+}
+
+func TestFileStore_PreservesMode(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state.json")
+	fs := NewFileStore[string, int](path)
+	require.NoError(t, fs.Put(ctx, "a", 1))
+	require.NoError(t, fs.Flush(ctx))
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, defaultFileMode, fi.Mode().Perm())
+
+	require.NoError(t, os.Chmod(path, 0o640))
+	require.NoError(t, fs.Put(ctx, "a", 2))
+	require.NoError(t, fs.Flush(ctx))
+	fi, err = os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o640), fi.Mode().Perm())
+}
+
+func TestFileStore_NonStringKeys(t *testing.T) {
+	ctx := context.Background()
+	type point struct{ X, Y int }
+	path := filepath.Join(t.TempDir(), "points.json")
+
+	fs := NewFileStore[point, string](path)
+	require.NoError(t, fs.Put(ctx, point{1, 2}, "a"))
+	require.NoError(t, fs.Put(ctx, point{3, 4}, "b"))
+	require.NoError(t, fs.Flush(ctx))
+
+	m, err := NewFileStore[point, string](path).Load(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[point]string{{1, 2}: "a", {3, 4}: "b"}, m)
+}
+
+func TestFileStore_Corrupt(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "state.json")
+	require.NoError(t, os.WriteFile(path, []byte("{not json"), 0o600))
+
+	fs := NewFileStore[string, int](path)
+	_, err := fs.Load(ctx)
+	require.Error(t, err)
+	require.Error(t, fs.Put(ctx, "a", 1))
+}
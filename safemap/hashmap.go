@@ -0,0 +1,225 @@
+package safemap
+
+import (
+	"fmt"
+	"iter"
+	"strings"
+	"sync"
+)
+
+// HashMap is a thread-safe map whose keys can be of any type, including
+// slices, maps and structs containing them. Keys are compared with the equal
+// function given to NewHashMap and bucketed by the hash function, which must
+// return the same value for equal keys. Keys must not be modified while they
+// are in the map.
+type HashMap[K any, V any] struct {
+	mu      sync.RWMutex
+	hash    func(K) uint64
+	equal   func(a, b K) bool
+	buckets map[uint64][]Entry[K, V]
+	size    int
+}
+
+// NewHashMap creates a new HashMap with the given hash and equality functions.
+func NewHashMap[K any, V any](hash func(K) uint64, equal func(a, b K) bool) *HashMap[K, V] {
+	return &HashMap[K, V]{
+		hash:    hash,
+		equal:   equal,
+		buckets: make(map[uint64][]Entry[K, V]),
+	}
+}
+
+// Get returns the value associated with the key.
+func (hm *HashMap[K, V]) Get(k K) ValueResult[V] {
+	hm.mu.RLock()
+	defer hm.mu.RUnlock()
+	bucket := hm.buckets[hm.hash(k)]
+	if i := hm.index(bucket, k); i >= 0 {
+		return ValueResult[V]{Value: bucket[i].Value, Found: true}
+	}
+	return ValueResult[V]{}
+}
+
+// Contains returns true if the key exists.
+func (hm *HashMap[K, V]) Contains(k K) bool {
+	return hm.Get(k).Found
+}
+
+// Set sets the value associated with the key. An existing key keeps the
+// key it was first stored with.
+func (hm *HashMap[K, V]) Set(k K, v V) {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	h := hm.hash(k)
+	bucket := hm.buckets[h]
+	if i := hm.index(bucket, k); i >= 0 {
+		bucket[i].Value = v
+		return
+	}
+	hm.buckets[h] = append(bucket, Entry[K, V]{Key: k, Value: v})
+	hm.size++
+}
+
+// SetNX sets the value associated with the key if the key does not exist.
+// It returns true if the value was set.
+func (hm *HashMap[K, V]) SetNX(k K, v V) bool {
+	_, computed := hm.GetOrCompute(k, func() V { return v })
+	return computed
+}
+
+// GetOrCompute returns the value associated with the key. If the key does not
+// exist, the value returned by fn is stored and returned. The boolean result is
+// true if fn was called.
+func (hm *HashMap[K, V]) GetOrCompute(k K, fn func() V) (V, bool) {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	h := hm.hash(k)
+	bucket := hm.buckets[h]
+	if i := hm.index(bucket, k); i >= 0 {
+		return bucket[i].Value, false
+	}
+	v := fn()
+	hm.buckets[h] = append(bucket, Entry[K, V]{Key: k, Value: v})
+	hm.size++
+	return v, true
+}
+
+// Delete deletes the key-value pair associated with the key.
+func (hm *HashMap[K, V]) Delete(k K) {
+	hm.Pop(k)
+}
+
+// Pop deletes the key-value pair associated with the key and returns the value.
+func (hm *HashMap[K, V]) Pop(k K) (V, bool) {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	h := hm.hash(k)
+	bucket := hm.buckets[h]
+	i := hm.index(bucket, k)
+	if i < 0 {
+		var zero V
+		return zero, false
+	}
+	v := bucket[i].Value
+	if len(bucket) == 1 {
+		delete(hm.buckets, h)
+	} else {
+		last := len(bucket) - 1
+		bucket[i] = bucket[last]
+		bucket[last] = Entry[K, V]{}
+		hm.buckets[h] = bucket[:last]
+	}
+	hm.size--
+	return v, true
+}
+
+// Len returns the number of key-value pairs.
+func (hm *HashMap[K, V]) Len() int {
+	hm.mu.RLock()
+	defer hm.mu.RUnlock()
+	return hm.size
+}
+
+// IsEmpty returns true if the map is empty.
+func (hm *HashMap[K, V]) IsEmpty() bool {
+	return hm.Len() == 0
+}
+
+// Clear deletes all key-value pairs.
+func (hm *HashMap[K, V]) Clear() {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	hm.buckets = make(map[uint64][]Entry[K, V])
+	hm.size = 0
+}
+
+// GetKeys returns a slice of all keys.
+func (hm *HashMap[K, V]) GetKeys() []K {
+	hm.mu.RLock()
+	defer hm.mu.RUnlock()
+	keys := make([]K, 0, hm.size)
+	for _, bucket := range hm.buckets {
+		for _, e := range bucket {
+			keys = append(keys, e.Key)
+		}
+	}
+	return keys
+}
+
+// GetValues returns a slice of all values.
+func (hm *HashMap[K, V]) GetValues() []V {
+	hm.mu.RLock()
+	defer hm.mu.RUnlock()
+	values := make([]V, 0, hm.size)
+	for _, bucket := range hm.buckets {
+		for _, e := range bucket {
+			values = append(values, e.Value)
+		}
+	}
+	return values
+}
+
+// Export returns the key-value pairs of the map, in no particular order.
+func (hm *HashMap[K, V]) Export() []Entry[K, V] {
+	hm.mu.RLock()
+	defer hm.mu.RUnlock()
+	return hm.exportLocked()
+}
+
+// All returns an iterator over the key-value pairs of the map.
+func (hm *HashMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for _, e := range hm.Export() {
+			if !yield(e.Key, e.Value) {
+				return
+			}
+		}
+	}
+}
+
+// Copy returns a new HashMap with the same key-value pairs and functions.
+func (hm *HashMap[K, V]) Copy() *HashMap[K, V] {
+	hm.mu.RLock()
+	defer hm.mu.RUnlock()
+	c := NewHashMap[K, V](hm.hash, hm.equal)
+	for h, bucket := range hm.buckets {
+		c.buckets[h] = append([]Entry[K, V](nil), bucket...)
+	}
+	c.size = hm.size
+	return c
+}
+
+// String returns a string representation of the HashMap.
+func (hm *HashMap[K, V]) String() string {
+	hm.mu.RLock()
+	defer hm.mu.RUnlock()
+	var b strings.Builder
+	b.WriteString("map[")
+	for i, e := range hm.exportLocked() {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%v:%v", e.Key, e.Value)
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// index returns the position of the key in bucket, or -1 if it is not there.
+func (hm *HashMap[K, V]) index(bucket []Entry[K, V], k K) int {
+	for i, e := range bucket {
+		if hm.equal(e.Key, k) {
+			return i
+		}
+	}
+	return -1
+}
+
+// exportLocked returns the key-value pairs. The caller must hold the lock.
+func (hm *HashMap[K, V]) exportLocked() []Entry[K, V] {
+	entries := make([]Entry[K, V], 0, hm.size)
+	for _, bucket := range hm.buckets {
+		entries = append(entries, bucket...)
+	}
+	return entries
+}
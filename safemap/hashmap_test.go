@@ -0,0 +1,122 @@
+package safemap
+
+import (
+	"hash/maphash"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var hashSeed = maphash.MakeSeed()
+
+func foldHash(s string) uint64 {
+	return maphash.String(hashSeed, strings.ToLower(s))
+}
+
+func pathHash(path []string) uint64 {
+	var h maphash.Hash
+	h.SetSeed(hashSeed)
+	for _, p := range path {
+		h.WriteString(p)
+		h.WriteByte(0)
+	}
+	return h.Sum64()
+}
+
+func TestHashMap_CompositeKeys(t *testing.T) {
+	hm := NewHashMap[[]string, int](pathHash, slices.Equal[[]string])
+	hm.Set([]string{"usr", "bin"}, 1)
+	hm.Set([]string{"usr", "lib"}, 2)
+	hm.Set([]string{"usr", "bin"}, 3)
+
+	require.Equal(t, 2, hm.Len())
+	require.Equal(t, ValueResult[int]{Value: 3, Found: true}, hm.Get([]string{"usr", "bin"}))
+	require.False(t, hm.Get([]string{"usr"}).Found)
+	require.False(t, hm.Contains([]string{"usrbin"}))
+
+	v, ok := hm.Pop([]string{"usr", "lib"})
+	require.True(t, ok)
+	require.Equal(t, 2, v)
+	_, ok = hm.Pop([]string{"usr", "lib"})
+	require.False(t, ok)
+	require.Equal(t, 1, hm.Len())
+}
+
+func TestHashMap_CaseInsensitive(t *testing.T) {
+	hm := NewHashMap[string, string](foldHash, strings.EqualFold)
+	require.True(t, hm.SetNX("Content-Type", "text/plain"))
+	require.False(t, hm.SetNX("content-type", "text/html"))
+	hm.Set("CONTENT-TYPE", "application/json")
+
+	require.Equal(t, []string{"Content-Type"}, hm.GetKeys())
+	require.Equal(t, []string{"application/json"}, hm.GetValues())
+	require.Equal(t, "map[Content-Type:application/json]", hm.String())
+
+	v, computed := hm.GetOrCompute("content-TYPE", func() string { return "x" })
+	require.False(t, computed)
+	require.Equal(t, "application/json", v)
+
+	hm.Delete("content-type")
+	require.True(t, hm.IsEmpty())
+}
+
+func TestHashMap_Collisions(t *testing.T) {
+	hm := NewHashMap[int, string](func(int) uint64 { return 7 }, func(a, b int) bool { return a == b })
+	for i := range 5 {
+		hm.Set(i, strconv.Itoa(i))
+	}
+	require.Len(t, hm.buckets, 1)
+	hm.Delete(0)
+	hm.Delete(4)
+	require.ElementsMatch(t, []Entry[int, string]{{1, "1"}, {2, "2"}, {3, "3"}}, hm.Export())
+
+	c := hm.Copy()
+	c.Set(9, "9")
+	require.Equal(t, 3, hm.Len())
+	require.Equal(t, 4, c.Len())
+
+	hm.Clear()
+	require.True(t, hm.IsEmpty())
+	require.False(t, hm.Contains(1))
+}
+
+func TestHashMap_All(t *testing.T) {
+	hm := NewHashMap[string, int](foldHash, strings.EqualFold)
+	hm.Set("a", 1)
+	hm.Set("b", 2)
+	got := make(map[string]int)
+	for k, v := range hm.All() {
+		got[k] = v
+		hm.Set(k+k, v) // the body may modify the map
+	}
+	require.Equal(t, map[string]int{"a": 1, "b": 2}, got)
+	require.Equal(t, 4, hm.Len())
+}
+
+func TestHashMap_Concurrent(t *testing.T) {
+	hm := NewHashMap[string, int](foldHash, strings.EqualFold)
+	keys := []string{"a", "B", "c", "A", "b", "C"}
+
+	var wg sync.WaitGroup
+	for g := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				k := keys[(i+g)%len(keys)]
+				hm.Set(k, i)
+				hm.Get(k)
+				if i%3 == 0 {
+					hm.Delete(k)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	require.LessOrEqual(t, hm.Len(), 3)
+	require.Len(t, hm.Export(), hm.Len())
+}
@@ -0,0 +1,39 @@
+package safemap
+
+import (
+	"iter"
+)
+
+// All returns an iterator over the key-value pairs of the map.
+//
+// The iterator ranges over a Snapshot taken when iteration starts, so nothing
+// is copied and starting an iteration is O(1); like after any snapshot, the
+// first write to each segment of the map copies that segment.
+func (sm *SafeMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		sm.Snapshot().Range(yield)
+	}
+}
+
+// Keys returns an iterator over the keys of the map. See All for its semantics.
+func (sm *SafeMap[K, V]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		sm.Snapshot().Range(func(k K, _ V) bool {
+			return yield(k)
+		})
+	}
+}
+
+// Values returns an iterator over the values of the map. See All for its semantics.
+func (sm *SafeMap[K, V]) Values() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		sm.Snapshot().Range(func(_ K, v V) bool {
+			return yield(v)
+		})
+	}
+}
+
+// All returns an iterator over the key-value pairs of the snapshot.
+func (s *Snapshot[K, V]) All() iter.Seq2[K, V] {
+	return s.Range
+}
@@ -0,0 +1,109 @@
+package safemap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSafeMap_All(t *testing.T) {
+	sm := NewSafeMapFromMap(map[string]int{"a": 1, "b": 2, "c": 3})
+	got := make(map[string]int)
+	for k, v := range sm.All() {
+		got[k] = v
+	}
+	require.Equal(t, sm.Export(), got)
+}
+
+func TestSafeMap_AllBreak(t *testing.T) {
+	sm := NewSafeMapFromMap(map[string]int{"a": 1, "b": 2, "c": 3})
+	n := 0
+	for range sm.All() {
+		n++
+		break
+	}
+	require.Equal(t, 1, n)
+}
+
+func TestSafeMap_AllMutateDuringIteration(t *testing.T) {
+	sm := NewSafeMapFromMap(map[int]int{1: 1, 2: 2, 3: 3})
+	n := 0
+	for k, v := range sm.All() {
+		sm.Delete(k)
+		sm.Set(k+10, v)
+		n++
+	}
+	// the loop sees the map as of the start of the iteration
+	require.Equal(t, 3, n)
+	require.Equal(t, map[int]int{11: 1, 12: 2, 13: 3}, sm.Export())
+}
+
+func TestSafeMap_AllDoesNotCopy(t *testing.T) {
+	m := make(map[int]int)
+	for i := 0; i < 10*segmentSize; i++ {
+		m[i] = i
+	}
+	sm := NewSafeMapFromMap(m)
+
+	// the loop ranges over a snapshot of the map's own data rather than a copy
+	for range sm.All() {
+		require.True(t, sm.shared.Load())
+		break
+	}
+	allocs := testing.AllocsPerRun(10, func() {
+		for range sm.All() {
+		}
+		for range sm.Keys() {
+		}
+		for range sm.Values() {
+		}
+	})
+	require.Less(t, allocs, 20.0)
+}
+
+func TestSafeMap_AllSkipsExpired(t *testing.T) {
+	clock := newFakeClock()
+	sm := newTTLMap(clock)
+	sm.SetWithTTL("a", 1, time.Second)
+	sm.Set("b", 2)
+	clock.Advance(time.Second)
+
+	got := make(map[string]int)
+	for k, v := range sm.All() {
+		got[k] = v
+	}
+	require.Equal(t, map[string]int{"b": 2}, got)
+}
+
+func TestSafeMap_KeysValues(t *testing.T) {
+	sm := NewSafeMapFromMap(map[string]int{"a": 1, "b": 2})
+	var keys []string
+	for k := range sm.Keys() {
+		keys = append(keys, k)
+	}
+	var values []int
+	for v := range sm.Values() {
+		values = append(values, v)
+	}
+	require.ElementsMatch(t, []string{"a", "b"}, keys)
+	require.ElementsMatch(t, []int{1, 2}, values)
+
+	for range sm.Keys() {
+		break
+	}
+	for range sm.Values() {
+		break
+	}
+}
+
+func TestSnapshot_All(t *testing.T) {
+	sm := NewSafeMapFromMap(map[string]int{"a": 1})
+	snap := sm.Snapshot()
+	sm.Set("b", 2)
+	got := make(map[string]int)
+	for k, v := range snap.All() {
+		got[k] = v
+	}
+	require.Equal(t, map[string]int{"a": 1}, got)
+}
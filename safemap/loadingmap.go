@@ -0,0 +1,324 @@
+package safemap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// minFailureSweep is the number of errors cached between two sweeps of the
+// expired errors of a LoadingMap with few live errors.
+const minFailureSweep = 64
+
+// ErrNotFound is returned by LoadingMap.Get for a key that the batch loader
+// did not return.
+var ErrNotFound = errors.New("key not found by loader")
+
+// Loader loads the value of a single key.
+type Loader[K comparable, V any] func(ctx context.Context, k K) (V, error)
+
+// BatchLoader loads the values of several keys at once. Keys missing from the
+// returned map are treated as not found.
+type BatchLoader[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+// LoadingMapOptions configures a LoadingMap.
+type LoadingMapOptions[K comparable, V any] struct {
+	// Cache is the map loaded values are stored in. A new SafeMap is created
+	// if it is nil. The cache can be read, written and watched directly.
+	Cache *SafeMap[K, V]
+	// TTL is how long a loaded value stays cached. If it is not positive,
+	// loaded values do not expire.
+	TTL time.Duration
+	// NegativeTTL is how long a load error is cached. If it is not positive,
+	// errors are not cached and every Get of a failing key calls the loader.
+	// Expired errors are swept as new ones are cached.
+	NegativeTTL time.Duration
+	// BatchLoader is used by GetAll to load all missing keys in one call.
+	// If it is nil, GetAll loads missing keys one by one.
+	BatchLoader BatchLoader[K, V]
+}
+
+// LoadingMap is a read-through cache on top of a SafeMap. A Get that misses
+// the cache calls the loader and stores the result. Concurrent misses for the
+// same key share a single loader call.
+//
+// Loads run with the context of the caller that started them, detached from
+// its cancellation: a caller that gives up does not fail the load for other
+// callers waiting on the same key.
+type LoadingMap[K comparable, V any] struct {
+	cache    *SafeMap[K, V]
+	failures *SafeMap[K, error]
+	loader   Loader[K, V]
+	batch    BatchLoader[K, V]
+	ttl      time.Duration
+	negTTL   time.Duration
+
+	mu    sync.Mutex
+	calls map[K]*loadCall[V]
+	// failureWrites counts the errors cached since failures was last swept;
+	// failures is swept again when it reaches failureSweepAt
+	failureWrites  int
+	failureSweepAt int
+}
+
+// loadCall is an in-flight load of one key.
+type loadCall[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// NewLoadingMap creates a new LoadingMap that loads missing keys with loader.
+func NewLoadingMap[K comparable, V any](loader Loader[K, V], opts LoadingMapOptions[K, V]) *LoadingMap[K, V] {
+	cache := opts.Cache
+	if cache == nil {
+		cache = NewSafeMap[K, V]()
+	}
+	return &LoadingMap[K, V]{
+		cache:    cache,
+		failures: NewSafeMap[K, error](),
+		loader:   loader,
+		batch:    opts.BatchLoader,
+		ttl:      opts.TTL,
+		negTTL:   opts.NegativeTTL,
+		calls:    make(map[K]*loadCall[V]),
+	}
+}
+
+// Cache returns the SafeMap that holds the loaded values.
+func (lm *LoadingMap[K, V]) Cache() *SafeMap[K, V] {
+	return lm.cache
+}
+
+// SetClock replaces the function used to read the current time for both the
+// cache and the cached errors. It is intended for tests.
+func (lm *LoadingMap[K, V]) SetClock(clock func() time.Time) {
+	lm.cache.SetClock(clock)
+	lm.failures.SetClock(clock)
+}
+
+// Get returns the value of the key, loading it if it is not cached.
+// It returns the load error, which may have been cached, or the context's
+// error if ctx is done before the load finishes.
+func (lm *LoadingMap[K, V]) Get(ctx context.Context, k K) (V, error) {
+	if v, ok, err := lm.cached(k); ok {
+		return v, err
+	}
+
+	lm.mu.Lock()
+	call, ok := lm.calls[k]
+	if !ok {
+		// a load may have finished since the cache was checked
+		if v, ok, err := lm.cached(k); ok {
+			lm.mu.Unlock()
+			return v, err
+		}
+		call = &loadCall[V]{done: make(chan struct{})}
+		lm.calls[k] = call
+		go lm.load(context.WithoutCancel(ctx), k, call)
+	}
+	lm.mu.Unlock()
+	return call.wait(ctx)
+}
+
+// GetAll returns the values of the keys, loading the ones that are not cached.
+// With a BatchLoader, all missing keys that are not already being loaded are
+// loaded in one call. Keys that are not found are left out of the result.
+// On error GetAll returns the values it has together with the first error.
+func (lm *LoadingMap[K, V]) GetAll(ctx context.Context, keys []K) (map[K]V, error) {
+	result := make(map[K]V, len(keys))
+	var firstErr error
+	collect := func(k K, v V, err error) {
+		switch {
+		case err == nil:
+			result[k] = v
+		case errors.Is(err, ErrNotFound):
+		case firstErr == nil:
+			firstErr = err
+		}
+	}
+
+	seen := make(map[K]struct{}, len(keys))
+	var missing []K
+	for _, k := range keys {
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		if v, ok, err := lm.cached(k); ok {
+			collect(k, v, err)
+			continue
+		}
+		missing = append(missing, k)
+	}
+
+	if lm.batch == nil {
+		for _, k := range missing {
+			v, err := lm.Get(ctx, k)
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return result, ctxErr
+			}
+			collect(k, v, err)
+		}
+		return result, firstErr
+	}
+
+	calls := make(map[K]*loadCall[V], len(missing))
+	var toLoad []K
+	lm.mu.Lock()
+	for _, k := range missing {
+		if call, ok := lm.calls[k]; ok {
+			calls[k] = call
+			continue
+		}
+		call := &loadCall[V]{done: make(chan struct{})}
+		lm.calls[k] = call
+		calls[k] = call
+		toLoad = append(toLoad, k)
+	}
+	lm.mu.Unlock()
+	if len(toLoad) > 0 {
+		go lm.loadBatch(context.WithoutCancel(ctx), toLoad, calls)
+	}
+
+	for _, k := range missing {
+		v, err := calls[k].wait(ctx)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return result, ctxErr
+		}
+		collect(k, v, err)
+	}
+	return result, firstErr
+}
+
+// Set stores the value of the key in the cache, replacing any cached error.
+// A load of the key in flight is superseded: its result is not cached, though
+// callers already waiting for it still receive it.
+func (lm *LoadingMap[K, V]) Set(k K, v V) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	delete(lm.calls, k)
+	lm.failures.Delete(k)
+	lm.cache.SetWithTTL(k, v, lm.ttl)
+}
+
+// Invalidate removes the key and any cached error, so the next Get loads it again.
+// A load already in flight is not cancelled, but its result is not cached.
+func (lm *LoadingMap[K, V]) Invalidate(k K) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	delete(lm.calls, k)
+	lm.failures.Delete(k)
+	lm.cache.Delete(k)
+}
+
+// Len returns the number of cached values.
+func (lm *LoadingMap[K, V]) Len() int {
+	return lm.cache.Len()
+}
+
+// cached returns the cached value or error of the key. The boolean is false
+// if neither is cached.
+func (lm *LoadingMap[K, V]) cached(k K) (V, bool, error) {
+	if r := lm.cache.Get(k); r.Found {
+		return r.Value, true, nil
+	}
+	var zero V
+	if r := lm.failures.Get(k); r.Found {
+		return zero, true, r.Value
+	}
+	return zero, false, nil
+}
+
+// load runs the loader for one key and completes the call.
+func (lm *LoadingMap[K, V]) load(ctx context.Context, k K, call *loadCall[V]) {
+	v, err := lm.safeLoad(ctx, k)
+	lm.finish(k, call, v, err)
+}
+
+// safeLoad calls the loader, turning a panic into an error.
+func (lm *LoadingMap[K, V]) safeLoad(ctx context.Context, k K) (v V, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("safemap: loader panicked: %v", r)
+		}
+	}()
+	return lm.loader(ctx, k)
+}
+
+// safeLoadBatch calls the batch loader, turning a panic into an error.
+func (lm *LoadingMap[K, V]) safeLoadBatch(ctx context.Context, keys []K) (values map[K]V, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("safemap: batch loader panicked: %v", r)
+		}
+	}()
+	return lm.batch(ctx, keys)
+}
+
+// loadBatch runs the batch loader for keys and completes their calls.
+func (lm *LoadingMap[K, V]) loadBatch(ctx context.Context, keys []K, calls map[K]*loadCall[V]) {
+	values, err := lm.safeLoadBatch(ctx, keys)
+	for _, k := range keys {
+		if err != nil {
+			var zero V
+			lm.finish(k, calls[k], zero, err)
+		} else if v, ok := values[k]; ok {
+			lm.finish(k, calls[k], v, nil)
+		} else {
+			var zero V
+			lm.finish(k, calls[k], zero, ErrNotFound)
+		}
+	}
+}
+
+// finish caches the result of a load and wakes up the callers waiting for it.
+// The result is cached only if the call is still current, that is, no Set or
+// Invalidate of the key happened while it was loading. The result is cached
+// and the call removed under lm.mu, so no Get can miss both.
+func (lm *LoadingMap[K, V]) finish(k K, call *loadCall[V], v V, err error) {
+	lm.mu.Lock()
+	if lm.calls[k] == call {
+		if err == nil {
+			lm.cache.SetWithTTL(k, v, lm.ttl)
+		} else if lm.negTTL > 0 {
+			lm.failures.SetWithTTL(k, err, lm.negTTL)
+			lm.sweepFailures()
+		}
+		delete(lm.calls, k)
+	}
+	lm.mu.Unlock()
+
+	call.value, call.err = v, err
+	close(call.done)
+}
+
+// sweepFailures removes the expired cached errors once enough errors have been
+// cached since the last sweep. Nothing else removes an expired error of a key
+// that is never loaded or set again, so without it failing loads of ever new
+// keys would grow the map without bound. A sweep costs time proportional to
+// the errors cached before it, so the cost is constant per error.
+// The caller must hold lm.mu.
+func (lm *LoadingMap[K, V]) sweepFailures() {
+	lm.failureWrites++
+	if lm.failureWrites < lm.failureSweepAt {
+		return
+	}
+	lm.failures.Sweep()
+	lm.failureWrites = 0
+	lm.failureSweepAt = lm.failures.Len() + minFailureSweep
+}
+
+// wait returns the result of the call once it completes, or the context's
+// error if ctx is done first.
+func (c *loadCall[V]) wait(ctx context.Context) (V, error) {
+	select {
+	case <-c.done:
+		return c.value, c.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
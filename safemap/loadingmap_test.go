@@ -0,0 +1,297 @@
+package safemap
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadingMap_Get(t *testing.T) {
+	var calls atomic.Int32
+	lm := NewLoadingMap(func(_ context.Context, k string) (int, error) {
+		calls.Add(1)
+		return len(k), nil
+	}, LoadingMapOptions[string, int]{})
+
+	ctx := context.Background()
+	v, err := lm.Get(ctx, "abc")
+	require.NoError(t, err)
+	require.Equal(t, 3, v)
+	v, err = lm.Get(ctx, "abc")
+	require.NoError(t, err)
+	require.Equal(t, 3, v)
+	require.Equal(t, int32(1), calls.Load())
+	require.Equal(t, 3, lm.Cache().Get("abc").Value)
+
+	lm.Set("abc", 10)
+	v, _ = lm.Get(ctx, "abc")
+	require.Equal(t, 10, v)
+
+	lm.Invalidate("abc")
+	v, _ = lm.Get(ctx, "abc")
+	require.Equal(t, 3, v)
+	require.Equal(t, int32(2), calls.Load())
+	require.Equal(t, 1, lm.Len())
+}
+
+func TestLoadingMap_Coalescing(t *testing.T) {
+	var calls atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	lm := NewLoadingMap(func(_ context.Context, k int) (int, error) {
+		calls.Add(1)
+		close(started)
+		<-release
+		return k * 2, nil
+	}, LoadingMapOptions[int, int]{})
+
+	// the load is blocked until release, so every Get made before that joins it
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := lm.Get(context.Background(), 21)
+			require.NoError(t, err)
+			require.Equal(t, 42, v)
+		}()
+	}
+	<-started
+	close(release)
+	wg.Wait()
+	require.Equal(t, int32(1), calls.Load())
+}
+
+func TestLoadingMap_CancelledCallerDoesNotFailOthers(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	lm := NewLoadingMap(func(ctx context.Context, k string) (string, error) {
+		close(started)
+		<-release
+		return k, ctx.Err()
+	}, LoadingMapOptions[string, string]{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := lm.Get(ctx, "k")
+		first <- err
+	}()
+	<-started
+	second := make(chan string)
+	go func() {
+		v, err := lm.Get(context.Background(), "k")
+		require.NoError(t, err)
+		second <- v
+	}()
+
+	cancel()
+	require.ErrorIs(t, <-first, context.Canceled)
+	close(release)
+	require.Equal(t, "k", <-second)
+}
+
+func TestLoadingMap_SetDuringLoad(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		modify func(lm *LoadingMap[string, int])
+		want   int
+		loads  int32
+	}{
+		{"Set", func(lm *LoadingMap[string, int]) { lm.Set("a", 99) }, 99, 1},
+		{"Invalidate", func(lm *LoadingMap[string, int]) { lm.Invalidate("a") }, 2, 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls atomic.Int32
+			started := make(chan struct{})
+			release := make(chan struct{})
+			lm := NewLoadingMap(func(_ context.Context, k string) (int, error) {
+				if calls.Add(1) == 1 {
+					close(started)
+					<-release
+				}
+				return int(calls.Load()), nil
+			}, LoadingMapOptions[string, int]{})
+
+			ctx := context.Background()
+			first := make(chan int)
+			go func() {
+				v, _ := lm.Get(ctx, "a")
+				first <- v
+			}()
+			<-started
+			tc.modify(lm)
+			close(release)
+
+			// the caller that started the stale load still gets its result
+			require.Equal(t, 1, <-first)
+			v, err := lm.Get(ctx, "a")
+			require.NoError(t, err)
+			require.Equal(t, tc.want, v)
+			require.Equal(t, tc.loads, calls.Load())
+		})
+	}
+}
+
+func TestLoadingMap_Errors(t *testing.T) {
+	errBackend := errors.New("backend down")
+	var calls atomic.Int32
+	lm := NewLoadingMap(func(_ context.Context, k string) (int, error) {
+		calls.Add(1)
+		return 0, errBackend
+	}, LoadingMapOptions[string, int]{})
+
+	ctx := context.Background()
+	_, err := lm.Get(ctx, "a")
+	require.ErrorIs(t, err, errBackend)
+	_, err = lm.Get(ctx, "a")
+	require.ErrorIs(t, err, errBackend)
+	require.Equal(t, int32(2), calls.Load())
+	require.Equal(t, 0, lm.Len())
+}
+
+func TestLoadingMap_NegativeTTL(t *testing.T) {
+	clock := newFakeClock()
+	errBackend := errors.New("backend down")
+	var failing atomic.Bool
+	failing.Store(true)
+	var calls atomic.Int32
+	lm := NewLoadingMap(func(_ context.Context, k string) (int, error) {
+		calls.Add(1)
+		if failing.Load() {
+			return 0, errBackend
+		}
+		return 1, nil
+	}, LoadingMapOptions[string, int]{TTL: time.Minute, NegativeTTL: time.Second})
+	lm.SetClock(clock.Now)
+
+	ctx := context.Background()
+	_, err := lm.Get(ctx, "a")
+	require.ErrorIs(t, err, errBackend)
+	failing.Store(false)
+	_, err = lm.Get(ctx, "a")
+	require.ErrorIs(t, err, errBackend)
+	require.Equal(t, int32(1), calls.Load())
+
+	clock.Advance(time.Second)
+	v, err := lm.Get(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, 1, v)
+
+	ttl, ok := lm.Cache().TTL("a")
+	require.True(t, ok)
+	require.Equal(t, time.Minute, ttl)
+	clock.Advance(time.Minute)
+	_, _ = lm.Get(ctx, "a")
+	require.Equal(t, int32(3), calls.Load())
+}
+
+func TestLoadingMap_NegativeTTLSweep(t *testing.T) {
+	clock := newFakeClock()
+	errBackend := errors.New("backend down")
+	lm := NewLoadingMap(func(_ context.Context, k int) (int, error) {
+		return 0, errBackend
+	}, LoadingMapOptions[int, int]{NegativeTTL: time.Second})
+	lm.SetClock(clock.Now)
+
+	ctx := context.Background()
+	for k := range 10 * minFailureSweep {
+		_, err := lm.Get(ctx, k)
+		require.ErrorIs(t, err, errBackend)
+		clock.Advance(time.Second)
+	}
+
+	// every error but the last has expired, and expired errors are swept
+	lm.failures.RLock()
+	defer lm.failures.RUnlock()
+	require.LessOrEqual(t, lm.failures.data.len(), minFailureSweep+1)
+}
+
+func TestLoadingMap_Panic(t *testing.T) {
+	lm := NewLoadingMap(func(_ context.Context, k string) (int, error) {
+		panic("boom")
+	}, LoadingMapOptions[string, int]{})
+	_, err := lm.Get(context.Background(), "a")
+	require.ErrorContains(t, err, "boom")
+}
+
+func TestLoadingMap_ExistingCache(t *testing.T) {
+	cache := NewSafeMapFromMap(map[string]int{"warm": 1})
+	lm := NewLoadingMap(func(_ context.Context, k string) (int, error) {
+		return 2, nil
+	}, LoadingMapOptions[string, int]{Cache: cache})
+
+	v, _ := lm.Get(context.Background(), "warm")
+	require.Equal(t, 1, v)
+	v, _ = lm.Get(context.Background(), "cold")
+	require.Equal(t, 2, v)
+	require.Equal(t, map[string]int{"warm": 1, "cold": 2}, cache.Export())
+}
+
+func TestLoadingMap_GetAll(t *testing.T) {
+	var batches [][]int
+	var mu sync.Mutex
+	var singleCalls atomic.Int32
+	lm := NewLoadingMap(func(_ context.Context, k int) (int, error) {
+		singleCalls.Add(1)
+		return 0, nil
+	}, LoadingMapOptions[int, int]{
+		NegativeTTL: time.Minute,
+		BatchLoader: func(_ context.Context, keys []int) (map[int]int, error) {
+			mu.Lock()
+			batches = append(batches, slices.Sorted(slices.Values(keys)))
+			mu.Unlock()
+			values := make(map[int]int)
+			for _, k := range keys {
+				if k != 4 {
+					values[k] = k * 10
+				}
+			}
+			return values, nil
+		},
+	})
+	lm.Set(1, 100)
+
+	ctx := context.Background()
+	values, err := lm.GetAll(ctx, []int{1, 2, 3, 4, 2})
+	require.NoError(t, err)
+	require.Equal(t, map[int]int{1: 100, 2: 20, 3: 30}, values)
+	require.Equal(t, [][]int{{2, 3, 4}}, batches)
+
+	// the missing key is cached as not found
+	_, err = lm.Get(ctx, 4)
+	require.ErrorIs(t, err, ErrNotFound)
+	require.Equal(t, int32(0), singleCalls.Load())
+}
+
+func TestLoadingMap_GetAllWithoutBatchLoader(t *testing.T) {
+	errOdd := errors.New("odd")
+	lm := NewLoadingMap(func(_ context.Context, k int) (int, error) {
+		if k%2 == 1 {
+			return 0, errOdd
+		}
+		return k, nil
+	}, LoadingMapOptions[int, int]{})
+
+	values, err := lm.GetAll(context.Background(), []int{2, 3, 4})
+	require.ErrorIs(t, err, errOdd)
+	require.Equal(t, map[int]int{2: 2, 4: 4}, values)
+}
+
+func TestLoadingMap_GetAllBatchError(t *testing.T) {
+	errBatch := errors.New("batch failed")
+	lm := NewLoadingMap(nil, LoadingMapOptions[int, int]{
+		BatchLoader: func(_ context.Context, keys []int) (map[int]int, error) {
+			return nil, errBatch
+		},
+	})
+	values, err := lm.GetAll(context.Background(), []int{1, 2})
+	require.ErrorIs(t, err, errBatch)
+	require.Empty(t, values)
+}
@@ -0,0 +1,303 @@
+package safemap
+
+import (
+	"fmt"
+	"iter"
+	"sync"
+
+	"gothreadsafe/safeset"
+)
+
+// MultiMap is a thread-safe map from keys to sets of values.
+// A key exists as long as it has at least one value; removing its last value
+// removes the key. A reverse index makes KeysFor as cheap as Get.
+// All operations, including creating a key's value set, run under one lock.
+// View returns a live, set-like view of a key's values and GetSet a snapshot
+// of them as a safeset.Set.
+type MultiMap[K comparable, V comparable] struct {
+	mu      sync.RWMutex
+	m       map[K]map[V]struct{}
+	reverse map[V]map[K]struct{}
+	entries int
+}
+
+// NewMultiMap creates a new MultiMap.
+func NewMultiMap[K comparable, V comparable]() *MultiMap[K, V] {
+	return &MultiMap[K, V]{
+		m:       make(map[K]map[V]struct{}),
+		reverse: make(map[V]map[K]struct{}),
+	}
+}
+
+// Put adds the value to the key's set of values.
+// It returns true if the entry was added and false if it already existed.
+func (mm *MultiMap[K, V]) Put(k K, v V) bool {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	return mm.put(k, v)
+}
+
+// PutAll adds the values to the key's set of values and returns how many were added.
+func (mm *MultiMap[K, V]) PutAll(k K, values ...V) int {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	added := 0
+	for _, v := range values {
+		if mm.put(k, v) {
+			added++
+		}
+	}
+	return added
+}
+
+// Remove removes the value from the key's set of values.
+// It returns true if the entry existed.
+func (mm *MultiMap[K, V]) Remove(k K, v V) bool {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	if _, ok := mm.m[k][v]; !ok {
+		return false
+	}
+	mm.remove(k, v)
+	return true
+}
+
+// RemoveAll removes the key with all of its values and returns the values.
+func (mm *MultiMap[K, V]) RemoveAll(k K) []V {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	values := setKeys(mm.m[k])
+	for _, v := range values {
+		mm.remove(k, v)
+	}
+	return values
+}
+
+// Get returns the values of the key as a slice, in no particular order.
+func (mm *MultiMap[K, V]) Get(k K) []V {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+	return setKeys(mm.m[k])
+}
+
+// GetSet returns a snapshot of the values of the key as a new Set.
+// Later changes to the MultiMap are not reflected in it, and changes to it do
+// not affect the MultiMap; use View for a live view.
+func (mm *MultiMap[K, V]) GetSet(k K) *safeset.Set[V] {
+	return safeset.NewSetWithValues(mm.Get(k)...)
+}
+
+// View returns a live view of the values of the key. See ValueSet.
+func (mm *MultiMap[K, V]) View(k K) *ValueSet[K, V] {
+	return &ValueSet[K, V]{mm: mm, key: k}
+}
+
+// KeysFor returns the keys that have the value, in no particular order.
+func (mm *MultiMap[K, V]) KeysFor(v V) []K {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+	return setKeys(mm.reverse[v])
+}
+
+// KeysForSet returns a snapshot of the keys that have the value as a new Set.
+func (mm *MultiMap[K, V]) KeysForSet(v V) *safeset.Set[K] {
+	return safeset.NewSetWithValues(mm.KeysFor(v)...)
+}
+
+// ContainsKey returns true if the key has at least one value.
+func (mm *MultiMap[K, V]) ContainsKey(k K) bool {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+	_, ok := mm.m[k]
+	return ok
+}
+
+// ContainsEntry returns true if the value is in the key's set of values.
+func (mm *MultiMap[K, V]) ContainsEntry(k K, v V) bool {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+	_, ok := mm.m[k][v]
+	return ok
+}
+
+// Count returns the number of values of the key.
+func (mm *MultiMap[K, V]) Count(k K) int {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+	return len(mm.m[k])
+}
+
+// Len returns the number of key-value entries.
+func (mm *MultiMap[K, V]) Len() int {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+	return mm.entries
+}
+
+// KeyLen returns the number of keys.
+func (mm *MultiMap[K, V]) KeyLen() int {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+	return len(mm.m)
+}
+
+// IsEmpty returns true if the map is empty.
+func (mm *MultiMap[K, V]) IsEmpty() bool {
+	return mm.Len() == 0
+}
+
+// Clear removes all entries.
+func (mm *MultiMap[K, V]) Clear() {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	mm.m = make(map[K]map[V]struct{})
+	mm.reverse = make(map[V]map[K]struct{})
+	mm.entries = 0
+}
+
+// GetKeys returns the keys of the map as a slice.
+func (mm *MultiMap[K, V]) GetKeys() []K {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+	keys := make([]K, 0, len(mm.m))
+	for k := range mm.m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Export returns a new map from each key to a slice of its values.
+func (mm *MultiMap[K, V]) Export() map[K][]V {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
+	exported := make(map[K][]V, len(mm.m))
+	for k, values := range mm.m {
+		exported[k] = setKeys(values)
+	}
+	return exported
+}
+
+// String returns a string representation of the MultiMap.
+func (mm *MultiMap[K, V]) String() string {
+	return fmt.Sprintf("%v", mm.Export())
+}
+
+// ValueSet is a live view of the values of one key of a MultiMap, with the
+// methods of a safeset.Set. It holds no data of its own: every method works on
+// the MultiMap under its lock, so changes through the view keep the reverse
+// index up to date, adding a value creates the key and removing the last
+// value removes it.
+type ValueSet[K comparable, V comparable] struct {
+	mm  *MultiMap[K, V]
+	key K
+}
+
+// Key returns the key whose values the view shows.
+func (vs *ValueSet[K, V]) Key() K {
+	return vs.key
+}
+
+// Add adds the value to the key's set of values.
+func (vs *ValueSet[K, V]) Add(v V) {
+	vs.mm.Put(vs.key, v)
+}
+
+// AddWithCheck adds the value and returns true if it was already present.
+func (vs *ValueSet[K, V]) AddWithCheck(v V) (existed bool) {
+	return !vs.mm.Put(vs.key, v)
+}
+
+// Remove removes the value from the key's set of values.
+func (vs *ValueSet[K, V]) Remove(v V) {
+	vs.mm.Remove(vs.key, v)
+}
+
+// Contains checks if the value is in the key's set of values.
+func (vs *ValueSet[K, V]) Contains(v V) bool {
+	return vs.mm.ContainsEntry(vs.key, v)
+}
+
+// Size returns the number of values of the key.
+func (vs *ValueSet[K, V]) Size() int {
+	return vs.mm.Count(vs.key)
+}
+
+// IsEmpty returns true if the key has no values.
+func (vs *ValueSet[K, V]) IsEmpty() bool {
+	return vs.Size() == 0
+}
+
+// Clear removes the key with all of its values.
+func (vs *ValueSet[K, V]) Clear() {
+	vs.mm.RemoveAll(vs.key)
+}
+
+// ToSlice returns the values of the key as a slice, in no particular order.
+func (vs *ValueSet[K, V]) ToSlice() []V {
+	return vs.mm.Get(vs.key)
+}
+
+// ToSet returns a snapshot of the values of the key as a new Set.
+func (vs *ValueSet[K, V]) ToSet() *safeset.Set[V] {
+	return vs.mm.GetSet(vs.key)
+}
+
+// All returns an iterator over the values of the key, in no particular order.
+func (vs *ValueSet[K, V]) All() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for _, v := range vs.ToSlice() {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// String returns a string representation of the values of the key.
+func (vs *ValueSet[K, V]) String() string {
+	return vs.ToSet().String()
+}
+
+// put adds an entry. The caller must hold the write lock.
+func (mm *MultiMap[K, V]) put(k K, v V) bool {
+	values, ok := mm.m[k]
+	if !ok {
+		values = make(map[V]struct{})
+		mm.m[k] = values
+	}
+	if _, ok := values[v]; ok {
+		return false
+	}
+	values[v] = struct{}{}
+	keys, ok := mm.reverse[v]
+	if !ok {
+		keys = make(map[K]struct{})
+		mm.reverse[v] = keys
+	}
+	keys[k] = struct{}{}
+	mm.entries++
+	return true
+}
+
+// remove removes an existing entry and drops empty sets.
+// The caller must hold the write lock.
+func (mm *MultiMap[K, V]) remove(k K, v V) {
+	delete(mm.m[k], v)
+	if len(mm.m[k]) == 0 {
+		delete(mm.m, k)
+	}
+	delete(mm.reverse[v], k)
+	if len(mm.reverse[v]) == 0 {
+		delete(mm.reverse, v)
+	}
+	mm.entries--
+}
+
+// setKeys returns the members of a set as a slice.
+func setKeys[T comparable](set map[T]struct{}) []T {
+	members := make([]T, 0, len(set))
+	for member := range set {
+		members = append(members, member)
+	}
+	return members
+}
@@ -0,0 +1,126 @@
+package safemap
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+
+	"gothreadsafe/safeset"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiMap_PutGet(t *testing.T) {
+	mm := NewMultiMap[string, string]()
+	require.True(t, mm.IsEmpty())
+	require.True(t, mm.Put("alice", "s1"))
+	require.False(t, mm.Put("alice", "s1"))
+	require.Equal(t, 2, mm.PutAll("alice", "s2", "s3", "s2"))
+	require.True(t, mm.Put("bob", "s1"))
+
+	require.ElementsMatch(t, []string{"s1", "s2", "s3"}, mm.Get("alice"))
+	require.Empty(t, mm.Get("carol"))
+	require.True(t, mm.GetSet("alice").Equal(mm.GetSet("alice")))
+	require.Equal(t, 3, mm.GetSet("alice").Size())
+
+	require.True(t, mm.ContainsKey("bob"))
+	require.True(t, mm.ContainsEntry("alice", "s3"))
+	require.False(t, mm.ContainsEntry("bob", "s3"))
+	require.ElementsMatch(t, []string{"alice", "bob"}, mm.KeysFor("s1"))
+	require.Equal(t, []string{"alice"}, mm.KeysFor("s2"))
+
+	require.Equal(t, 3, mm.Count("alice"))
+	require.Equal(t, 4, mm.Len())
+	require.Equal(t, 2, mm.KeyLen())
+	require.ElementsMatch(t, []string{"alice", "bob"}, mm.GetKeys())
+}
+
+func TestMultiMap_Remove(t *testing.T) {
+	mm := NewMultiMap[int, string]()
+	mm.PutAll(1, "a", "b")
+	mm.Put(2, "a")
+
+	require.True(t, mm.Remove(1, "a"))
+	require.False(t, mm.Remove(1, "a"))
+	require.False(t, mm.Remove(3, "a"))
+	require.Equal(t, []int{2}, mm.KeysFor("a"))
+
+	// removing the last value removes the key
+	require.True(t, mm.Remove(1, "b"))
+	require.False(t, mm.ContainsKey(1))
+	require.Empty(t, mm.KeysFor("b"))
+	require.Equal(t, map[int][]string{2: {"a"}}, mm.Export())
+
+	mm.PutAll(3, "x", "y")
+	require.ElementsMatch(t, []string{"x", "y"}, mm.RemoveAll(3))
+	require.Empty(t, mm.RemoveAll(3))
+	require.False(t, mm.ContainsKey(3))
+	require.Equal(t, 1, mm.Len())
+	require.Equal(t, "map[2:[a]]", mm.String())
+
+	mm.Clear()
+	require.True(t, mm.IsEmpty())
+	require.Empty(t, mm.KeysFor("a"))
+}
+
+func TestMultiMap_Sets(t *testing.T) {
+	mm := NewMultiMap[string, string]()
+	mm.PutAll("alice", "s1", "s2")
+	mm.Put("bob", "s1")
+
+	snap := mm.GetSet("alice")
+	view := mm.View("alice")
+	require.Equal(t, "alice", view.Key())
+	require.True(t, view.Contains("s1"))
+	require.Equal(t, 2, view.Size())
+
+	// writes through the view reach the map and its reverse index
+	view.Add("s3")
+	require.True(t, view.AddWithCheck("s3"))
+	view.Remove("s1")
+	require.ElementsMatch(t, []string{"s2", "s3"}, mm.Get("alice"))
+	require.Equal(t, []string{"bob"}, mm.KeysFor("s1"))
+	require.True(t, mm.KeysForSet("s3").Equal(safeset.NewSetWithValues("alice")))
+
+	// writes to the map show through the view but not in the snapshot
+	mm.Put("alice", "s4")
+	require.ElementsMatch(t, []string{"s2", "s3", "s4"}, slices.Collect(view.All()))
+	require.ElementsMatch(t, []string{"s1", "s2"}, snap.ToSlice())
+	require.True(t, view.ToSet().Equal(safeset.NewSetWithValues("s2", "s3", "s4")))
+
+	// emptying the view removes the key, and adding to it creates the key again
+	view.Clear()
+	require.True(t, view.IsEmpty())
+	require.False(t, mm.ContainsKey("alice"))
+	mm.View("carol").Add("s5")
+	require.Equal(t, []string{"s5"}, mm.Get("carol"))
+	require.Equal(t, "{s5}", mm.View("carol").String())
+}
+
+func TestMultiMap_Concurrent(t *testing.T) {
+	mm := NewMultiMap[string, int]()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				user := fmt.Sprintf("user%d", i%10)
+				mm.Put(user, g*100+i)
+				if i%2 == 1 {
+					mm.Remove(user, g*100+i)
+				}
+				mm.Get(user)
+			}
+		}(g)
+	}
+	wg.Wait()
+	require.Equal(t, 400, mm.Len())
+	require.Equal(t, 5, mm.KeyLen())
+	for _, k := range mm.GetKeys() {
+		for _, v := range mm.Get(k) {
+			require.Equal(t, []string{k}, mm.KeysFor(v))
+		}
+	}
+}
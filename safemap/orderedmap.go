@@ -0,0 +1,316 @@
+package safemap
+
+import (
+	"container/list"
+	"fmt"
+	"iter"
+	"strings"
+	"sync"
+)
+
+// OrderedMap is a thread-safe map that remembers the order in which keys were
+// inserted. Every method that returns keys or values, the string
+// representation and the JSON encoding follow insertion order.
+//
+// Updating an existing key keeps its position unless SetMoveOnUpdate is
+// enabled. Get, Set, Delete and Pop are O(1).
+type OrderedMap[K comparable, V any] struct {
+	mu           sync.RWMutex
+	m            map[K]*list.Element // values are Entry[K, V]
+	order        *list.List
+	moveOnUpdate bool
+}
+
+// NewOrderedMap creates a new OrderedMap.
+func NewOrderedMap[K comparable, V any]() *OrderedMap[K, V] {
+	return &OrderedMap[K, V]{
+		m:     make(map[K]*list.Element),
+		order: list.New(),
+	}
+}
+
+// NewOrderedMapFromEntries creates a new OrderedMap from key-value pairs,
+// inserted in the given order.
+func NewOrderedMapFromEntries[K comparable, V any](entries []Entry[K, V]) *OrderedMap[K, V] {
+	om := NewOrderedMap[K, V]()
+	for _, e := range entries {
+		om.set(e.Key, e.Value)
+	}
+	return om
+}
+
+// SetMoveOnUpdate sets whether Set moves an existing key to the end of the
+// order, as if it had been deleted and inserted again. It is disabled by default.
+func (om *OrderedMap[K, V]) SetMoveOnUpdate(move bool) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	om.moveOnUpdate = move
+}
+
+// Get returns the value associated with the key.
+func (om *OrderedMap[K, V]) Get(k K) ValueResult[V] {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+	if el, ok := om.m[k]; ok {
+		return ValueResult[V]{Value: el.Value.(Entry[K, V]).Value, Found: true}
+	}
+	var zero V
+	return ValueResult[V]{Value: zero, Found: false}
+}
+
+// Contains returns true if the key exists.
+func (om *OrderedMap[K, V]) Contains(k K) bool {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+	_, ok := om.m[k]
+	return ok
+}
+
+// Set sets the value associated with the key. A new key is added at the end.
+func (om *OrderedMap[K, V]) Set(k K, v V) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	om.set(k, v)
+}
+
+// SetNX sets the value associated with the key if the key does not exist.
+func (om *OrderedMap[K, V]) SetNX(k K, v V) bool {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	if _, ok := om.m[k]; ok {
+		return false
+	}
+	om.m[k] = om.order.PushBack(Entry[K, V]{Key: k, Value: v})
+	return true
+}
+
+// Delete deletes the key-value pair associated with the key.
+func (om *OrderedMap[K, V]) Delete(k K) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	if el, ok := om.m[k]; ok {
+		om.order.Remove(el)
+		delete(om.m, k)
+	}
+}
+
+// Pop deletes the key-value pair associated with the key and returns the value.
+func (om *OrderedMap[K, V]) Pop(k K) (V, bool) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	if el, ok := om.m[k]; ok {
+		om.order.Remove(el)
+		delete(om.m, k)
+		return el.Value.(Entry[K, V]).Value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// MoveToEnd moves the key to the end of the order.
+// It returns false if the key does not exist.
+func (om *OrderedMap[K, V]) MoveToEnd(k K) bool {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	el, ok := om.m[k]
+	if ok {
+		om.order.MoveToBack(el)
+	}
+	return ok
+}
+
+// MoveToFront moves the key to the front of the order.
+// It returns false if the key does not exist.
+func (om *OrderedMap[K, V]) MoveToFront(k K) bool {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	el, ok := om.m[k]
+	if ok {
+		om.order.MoveToFront(el)
+	}
+	return ok
+}
+
+// Oldest returns the first key in the order and its value.
+// The boolean is false if the map is empty.
+func (om *OrderedMap[K, V]) Oldest() (K, V, bool) {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+	return elementEntry[K, V](om.order.Front())
+}
+
+// Newest returns the last key in the order and its value.
+// The boolean is false if the map is empty.
+func (om *OrderedMap[K, V]) Newest() (K, V, bool) {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+	return elementEntry[K, V](om.order.Back())
+}
+
+// Len returns the number of key-value pairs.
+func (om *OrderedMap[K, V]) Len() int {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+	return len(om.m)
+}
+
+// IsEmpty returns true if the map is empty.
+func (om *OrderedMap[K, V]) IsEmpty() bool {
+	return om.Len() == 0
+}
+
+// Clear deletes all key-value pairs.
+func (om *OrderedMap[K, V]) Clear() {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	om.m = make(map[K]*list.Element)
+	om.order.Init()
+}
+
+// GetKeys returns the keys of the map as a slice, in insertion order.
+func (om *OrderedMap[K, V]) GetKeys() []K {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+	keys := make([]K, 0, len(om.m))
+	for el := om.order.Front(); el != nil; el = el.Next() {
+		keys = append(keys, el.Value.(Entry[K, V]).Key)
+	}
+	return keys
+}
+
+// GetValues returns the values of the map as a slice, in insertion order.
+func (om *OrderedMap[K, V]) GetValues() []V {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+	values := make([]V, 0, len(om.m))
+	for el := om.order.Front(); el != nil; el = el.Next() {
+		values = append(values, el.Value.(Entry[K, V]).Value)
+	}
+	return values
+}
+
+// GetKeyValuePairs returns the key-value pairs of the map as a slice of
+// alternating keys and values, in insertion order.
+func (om *OrderedMap[K, V]) GetKeyValuePairs() []any {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+	keysValues := make([]any, 0, len(om.m)*2)
+	for el := om.order.Front(); el != nil; el = el.Next() {
+		e := el.Value.(Entry[K, V])
+		keysValues = append(keysValues, e.Key, e.Value)
+	}
+	return keysValues
+}
+
+// GetKeysValues returns the keys and values of the map as separate slices,
+// in insertion order.
+func (om *OrderedMap[K, V]) GetKeysValues() ([]K, []V) {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+	keys := make([]K, 0, len(om.m))
+	values := make([]V, 0, len(om.m))
+	for el := om.order.Front(); el != nil; el = el.Next() {
+		e := el.Value.(Entry[K, V])
+		keys = append(keys, e.Key)
+		values = append(values, e.Value)
+	}
+	return keys, values
+}
+
+// Export returns the key-value pairs of the map as a slice, in insertion order.
+func (om *OrderedMap[K, V]) Export() []Entry[K, V] {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+	return om.exportLocked()
+}
+
+// All returns an iterator over the key-value pairs of the map in insertion order.
+func (om *OrderedMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for _, e := range om.Export() {
+			if !yield(e.Key, e.Value) {
+				return
+			}
+		}
+	}
+}
+
+// Copy returns a new OrderedMap with the same key-value pairs in the same order.
+func (om *OrderedMap[K, V]) Copy() *OrderedMap[K, V] {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+	c := NewOrderedMapFromEntries(om.exportLocked())
+	c.moveOnUpdate = om.moveOnUpdate
+	return c
+}
+
+// String returns a string representation of the OrderedMap.
+func (om *OrderedMap[K, V]) String() string {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+	var b strings.Builder
+	b.WriteString("map[")
+	for el := om.order.Front(); el != nil; el = el.Next() {
+		if el != om.order.Front() {
+			b.WriteByte(' ')
+		}
+		e := el.Value.(Entry[K, V])
+		fmt.Fprintf(&b, "%v:%v", e.Key, e.Value)
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// MarshalJSON implements json.Marshaler. It uses the same encodings as
+// SafeMap, with object members or pairs in insertion order.
+func (om *OrderedMap[K, V]) MarshalJSON() ([]byte, error) {
+	return marshalEntriesJSON(om.Export())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It accepts both encodings
+// produced by MarshalJSON and atomically replaces the contents of the map,
+// inserting keys in the order they appear in the input.
+func (om *OrderedMap[K, V]) UnmarshalJSON(data []byte) error {
+	entries, err := unmarshalEntriesJSON[K, V](data)
+	if err != nil || entries == nil {
+		return err
+	}
+	decoded := NewOrderedMapFromEntries(entries)
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	om.m, om.order = decoded.m, decoded.order
+	return nil
+}
+
+// set sets the value associated with the key. The caller must hold the write lock.
+func (om *OrderedMap[K, V]) set(k K, v V) {
+	if el, ok := om.m[k]; ok {
+		el.Value = Entry[K, V]{Key: k, Value: v}
+		if om.moveOnUpdate {
+			om.order.MoveToBack(el)
+		}
+		return
+	}
+	om.m[k] = om.order.PushBack(Entry[K, V]{Key: k, Value: v})
+}
+
+// exportLocked returns the key-value pairs in order. The caller must hold the lock.
+func (om *OrderedMap[K, V]) exportLocked() []Entry[K, V] {
+	entries := make([]Entry[K, V], 0, len(om.m))
+	for el := om.order.Front(); el != nil; el = el.Next() {
+		entries = append(entries, el.Value.(Entry[K, V]))
+	}
+	return entries
+}
+
+// elementEntry returns the key and value stored in el, or zero values and
+// false if el is nil.
+func elementEntry[K comparable, V any](el *list.Element) (K, V, bool) {
+	if el == nil {
+		var k K
+		var v V
+		return k, v, false
+	}
+	e := el.Value.(Entry[K, V])
+	return e.Key, e.Value, true
+}
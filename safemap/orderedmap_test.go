@@ -0,0 +1,166 @@
+package safemap
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderedMap_InsertionOrder(t *testing.T) {
+	om := NewOrderedMap[string, int]()
+	for i, k := range []string{"zulu", "alpha", "mike", "bravo"} {
+		om.Set(k, i)
+	}
+	om.Set("alpha", 10)
+	require.False(t, om.SetNX("mike", 20))
+	require.True(t, om.SetNX("echo", 4))
+
+	require.Equal(t, []string{"zulu", "alpha", "mike", "bravo", "echo"}, om.GetKeys())
+	require.Equal(t, []int{0, 10, 2, 3, 4}, om.GetValues())
+	keys, values := om.GetKeysValues()
+	require.Equal(t, om.GetKeys(), keys)
+	require.Equal(t, om.GetValues(), values)
+	require.Equal(t, []any{"zulu", 0, "alpha", 10, "mike", 2, "bravo", 3, "echo", 4}, om.GetKeyValuePairs())
+	require.Equal(t, "map[zulu:0 alpha:10 mike:2 bravo:3 echo:4]", om.String())
+
+	k, v, ok := om.Oldest()
+	require.True(t, ok)
+	require.Equal(t, "zulu", k)
+	require.Equal(t, 0, v)
+	k, _, _ = om.Newest()
+	require.Equal(t, "echo", k)
+}
+
+func TestOrderedMap_DeletePop(t *testing.T) {
+	om := NewOrderedMapFromEntries([]Entry[int, string]{{3, "c"}, {1, "a"}, {2, "b"}})
+	om.Delete(1)
+	om.Delete(42)
+	v, ok := om.Pop(3)
+	require.True(t, ok)
+	require.Equal(t, "c", v)
+	_, ok = om.Pop(3)
+	require.False(t, ok)
+	require.Equal(t, []Entry[int, string]{{2, "b"}}, om.Export())
+
+	// a re-inserted key goes to the end
+	om.Set(3, "c")
+	require.Equal(t, []int{2, 3}, om.GetKeys())
+	require.True(t, om.Contains(2))
+	require.Equal(t, 2, om.Len())
+
+	om.Clear()
+	require.True(t, om.IsEmpty())
+	_, _, ok = om.Oldest()
+	require.False(t, ok)
+}
+
+func TestOrderedMap_MoveOnUpdate(t *testing.T) {
+	om := NewOrderedMapFromEntries([]Entry[string, int]{{"a", 1}, {"b", 2}, {"c", 3}})
+	om.SetMoveOnUpdate(true)
+	om.Set("a", 10)
+	require.Equal(t, []string{"b", "c", "a"}, om.GetKeys())
+	require.Equal(t, 10, om.Get("a").Value)
+
+	require.True(t, om.MoveToFront("c"))
+	require.True(t, om.MoveToEnd("b"))
+	require.False(t, om.MoveToEnd("missing"))
+	require.Equal(t, []string{"c", "a", "b"}, om.GetKeys())
+
+	c := om.Copy()
+	c.Set("c", 0)
+	require.Equal(t, []string{"a", "b", "c"}, c.GetKeys())
+	require.Equal(t, []string{"c", "a", "b"}, om.GetKeys())
+}
+
+func TestOrderedMap_All(t *testing.T) {
+	om := NewOrderedMapFromEntries([]Entry[string, int]{{"x", 1}, {"y", 2}, {"z", 3}})
+	var keys []string
+	for k := range om.All() {
+		keys = append(keys, k)
+		om.Delete(k)
+	}
+	require.Equal(t, []string{"x", "y", "z"}, keys)
+	require.True(t, om.IsEmpty())
+}
+
+func TestOrderedMap_JSON(t *testing.T) {
+	om := NewOrderedMap[string, int]()
+	om.Set("zulu", 1)
+	om.Set("alpha", 2)
+	om.Set("mike", 3)
+	data, err := json.Marshal(om)
+	require.NoError(t, err)
+	require.Equal(t, `{"zulu":1,"alpha":2,"mike":3}`, string(data))
+
+	decoded := NewOrderedMap[string, int]()
+	decoded.Set("old", 0)
+	require.NoError(t, json.Unmarshal([]byte(`{"b":2, "a":1, "c":3}`), decoded))
+	require.Equal(t, []Entry[string, int]{{"b", 2}, {"a", 1}, {"c", 3}}, decoded.Export())
+
+	require.Error(t, json.Unmarshal([]byte(`{"a":"x"}`), decoded))
+	require.Error(t, json.Unmarshal([]byte(`"a"`), decoded))
+	require.Equal(t, []string{"b", "a", "c"}, decoded.GetKeys())
+}
+
+func TestOrderedMap_JSONKeys(t *testing.T) {
+	ints := NewOrderedMapFromEntries([]Entry[int, string]{{10, "ten"}, {-2, "minus two"}})
+	data, err := json.Marshal(ints)
+	require.NoError(t, err)
+	require.Equal(t, `{"10":"ten","-2":"minus two"}`, string(data))
+	decodedInts := NewOrderedMap[int, string]()
+	require.NoError(t, json.Unmarshal(data, decodedInts))
+	require.Equal(t, ints.Export(), decodedInts.Export())
+	require.Error(t, json.Unmarshal([]byte(`{"x":"y"}`), decodedInts))
+
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	times := NewOrderedMapFromEntries([]Entry[time.Time, int]{{day, 1}})
+	data, err = json.Marshal(times)
+	require.NoError(t, err)
+	require.Equal(t, `{"2024-01-02T00:00:00Z":1}`, string(data))
+	decodedTimes := NewOrderedMap[time.Time, int]()
+	require.NoError(t, json.Unmarshal(data, decodedTimes))
+	require.True(t, decodedTimes.Contains(day))
+
+	type point struct{ X, Y int }
+	points := NewOrderedMapFromEntries([]Entry[point, bool]{{point{2, 1}, true}, {point{1, 2}, false}})
+	data, err = json.Marshal(points)
+	require.NoError(t, err)
+	require.Equal(t, `[[{"X":2,"Y":1},true],[{"X":1,"Y":2},false]]`, string(data))
+	decodedPoints := NewOrderedMap[point, bool]()
+	require.NoError(t, json.Unmarshal(data, decodedPoints))
+	require.Equal(t, points.Export(), decodedPoints.Export())
+}
+
+func TestOrderedMap_Concurrent(t *testing.T) {
+	om := NewOrderedMap[int, int]()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				k := g*100 + i
+				om.Set(k, k)
+				if i%2 == 1 {
+					om.Delete(k - 1)
+				}
+				om.GetKeys()
+			}
+		}(g)
+	}
+	wg.Wait()
+	require.Equal(t, 400, om.Len())
+
+	// keys of one goroutine stay in insertion order
+	var own []int
+	for _, k := range om.GetKeys() {
+		if k < 100 {
+			own = append(own, k)
+		}
+	}
+	require.True(t, slices.IsSorted(own))
+}
@@ -0,0 +1,337 @@
+package safemap
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultFlushInterval is the write-behind flush interval used when
+// PersistOptions.FlushInterval is not positive.
+const DefaultFlushInterval = time.Second
+
+// ErrPersistentMapClosed is returned by the mutating methods of a
+// PersistentMap after Close.
+var ErrPersistentMapClosed = errors.New("persistent map is closed")
+
+// Store is durable storage for the contents of a map.
+// Put and Delete may buffer changes; Flush makes all earlier changes durable.
+type Store[K comparable, V any] interface {
+	// Load returns all stored key-value pairs.
+	Load(ctx context.Context) (map[K]V, error)
+	// Put stores the value of the key.
+	Put(ctx context.Context, k K, v V) error
+	// Delete removes the key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, k K) error
+	// Flush makes all earlier Put and Delete calls durable.
+	Flush(ctx context.Context) error
+}
+
+// PersistMode selects when a PersistentMap writes mutations to its store.
+type PersistMode int
+
+const (
+	// WriteThrough writes and flushes every mutation before it is applied to
+	// the map. A mutation that cannot be stored is not applied, and any part
+	// of it the store buffered is undone, so a later flush does not persist it.
+	WriteThrough PersistMode = iota
+	// WriteBehind applies mutations to the map immediately and writes them
+	// to the store in periodic batches. Repeated writes of a key between two
+	// flushes are coalesced into one.
+	WriteBehind
+)
+
+// PersistOptions configures a PersistentMap.
+type PersistOptions struct {
+	// Mode selects write-through or write-behind persistence.
+	Mode PersistMode
+	// FlushInterval is how often write-behind changes are flushed.
+	FlushInterval time.Duration
+	// OnError is called with the error of a failed background flush, and
+	// with the error of a failed undo after a write-through mutation fails.
+	// The changes of a failed flush are retried with the next one.
+	OnError func(error)
+}
+
+// PersistentMap is a SafeMap whose mutations are mirrored to a Store, so its
+// contents survive restarts. Mutations must go through the PersistentMap;
+// writes made directly to the underlying SafeMap are not persisted.
+type PersistentMap[K comparable, V any] struct {
+	sm      *SafeMap[K, V]
+	store   Store[K, V]
+	mode    PersistMode
+	onError func(error)
+
+	mu      sync.Mutex // serializes mutations and guards pending and closed
+	pending map[K]pendingWrite[V]
+	closed  bool
+
+	flushMu sync.Mutex // serializes flushes to the store
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// pendingWrite is a change of one key waiting to be flushed.
+type pendingWrite[V any] struct {
+	value   V
+	deleted bool
+}
+
+// NewPersistentMap loads the contents of store into a new PersistentMap.
+// In write-behind mode it starts a background goroutine; call Close to stop it.
+func NewPersistentMap[K comparable, V any](ctx context.Context, store Store[K, V], opts PersistOptions) (*PersistentMap[K, V], error) {
+	m, err := store.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pm := &PersistentMap[K, V]{
+		sm:      NewSafeMapFromMap(m),
+		store:   store,
+		mode:    opts.Mode,
+		onError: opts.OnError,
+		pending: make(map[K]pendingWrite[V]),
+	}
+	if pm.mode == WriteBehind {
+		interval := opts.FlushInterval
+		if interval <= 0 {
+			interval = DefaultFlushInterval
+		}
+		pm.stop = make(chan struct{})
+		pm.done = make(chan struct{})
+		go pm.flushLoop(interval)
+	}
+	return pm, nil
+}
+
+// Map returns the underlying SafeMap for reads, snapshots and subscriptions.
+func (pm *PersistentMap[K, V]) Map() *SafeMap[K, V] {
+	return pm.sm
+}
+
+// Get returns the value associated with the key.
+func (pm *PersistentMap[K, V]) Get(k K) ValueResult[V] {
+	return pm.sm.Get(k)
+}
+
+// Len returns the number of key-value pairs.
+func (pm *PersistentMap[K, V]) Len() int {
+	return pm.sm.Len()
+}
+
+// Export returns a new map with the same key-value pairs as the PersistentMap.
+func (pm *PersistentMap[K, V]) Export() map[K]V {
+	return pm.sm.Export()
+}
+
+// Set sets the value associated with the key.
+// In write-through mode it returns the store's error and leaves the map unchanged.
+func (pm *PersistentMap[K, V]) Set(k K, v V) error {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	if err := pm.write(k, pendingWrite[V]{value: v}); err != nil {
+		return err
+	}
+	pm.sm.Set(k, v)
+	return nil
+}
+
+// SetNX sets the value associated with the key if the key does not exist.
+// It returns true if the value was set.
+func (pm *PersistentMap[K, V]) SetNX(k K, v V) (bool, error) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	if err := pm.checkOpen(); err != nil {
+		return false, err
+	}
+	if pm.sm.Get(k).Found {
+		return false, nil
+	}
+	if err := pm.write(k, pendingWrite[V]{value: v}); err != nil {
+		return false, err
+	}
+	pm.sm.Set(k, v)
+	return true, nil
+}
+
+// Delete deletes the key-value pair associated with the key.
+func (pm *PersistentMap[K, V]) Delete(k K) error {
+	_, _, err := pm.Pop(k)
+	return err
+}
+
+// Pop deletes the key-value pair associated with the key and returns the value.
+func (pm *PersistentMap[K, V]) Pop(k K) (V, bool, error) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	var zero V
+	if !pm.sm.Get(k).Found {
+		return zero, false, pm.checkOpen()
+	}
+	if err := pm.write(k, pendingWrite[V]{deleted: true}); err != nil {
+		return zero, false, err
+	}
+	v, ok := pm.sm.Pop(k)
+	return v, ok, nil
+}
+
+// Clear deletes all key-value pairs.
+func (pm *PersistentMap[K, V]) Clear() error {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	if err := pm.checkOpen(); err != nil {
+		return err
+	}
+	keys := pm.sm.GetKeys()
+	if pm.mode == WriteThrough {
+		ctx := context.Background()
+		if err := pm.clearStore(ctx, keys); err != nil {
+			pm.resync(ctx, keys)
+			return err
+		}
+	} else {
+		for _, k := range keys {
+			pm.pending[k] = pendingWrite[V]{deleted: true}
+		}
+	}
+	pm.sm.Clear()
+	return nil
+}
+
+// Flush writes all pending write-behind changes to the store and flushes it.
+// In write-through mode there is nothing pending and it only flushes the store.
+func (pm *PersistentMap[K, V]) Flush(ctx context.Context) error {
+	pm.flushMu.Lock()
+	defer pm.flushMu.Unlock()
+
+	pm.mu.Lock()
+	batch := pm.pending
+	pm.pending = make(map[K]pendingWrite[V])
+	pm.mu.Unlock()
+
+	if err := pm.flushBatch(ctx, batch); err != nil {
+		pm.requeue(batch)
+		return err
+	}
+	return nil
+}
+
+// Close flushes pending changes and stops the background flusher.
+// Mutations after Close return ErrPersistentMapClosed; reads keep working.
+func (pm *PersistentMap[K, V]) Close(ctx context.Context) error {
+	pm.mu.Lock()
+	if pm.closed {
+		pm.mu.Unlock()
+		return nil
+	}
+	pm.closed = true
+	pm.mu.Unlock()
+
+	if pm.stop != nil {
+		close(pm.stop)
+		<-pm.done
+	}
+	return pm.Flush(ctx)
+}
+
+// write persists a change in write-through mode or queues it in write-behind
+// mode. The caller must hold pm.mu.
+func (pm *PersistentMap[K, V]) write(k K, w pendingWrite[V]) error {
+	if err := pm.checkOpen(); err != nil {
+		return err
+	}
+	if pm.mode == WriteBehind {
+		pm.pending[k] = w
+		return nil
+	}
+	ctx := context.Background()
+	err := pm.storeWrite(ctx, k, w)
+	if err == nil {
+		err = pm.store.Flush(ctx)
+	}
+	if err != nil {
+		pm.resync(ctx, []K{k})
+	}
+	return err
+}
+
+// storeWrite applies one change to the store without flushing it.
+func (pm *PersistentMap[K, V]) storeWrite(ctx context.Context, k K, w pendingWrite[V]) error {
+	if w.deleted {
+		return pm.store.Delete(ctx, k)
+	}
+	return pm.store.Put(ctx, k, w.value)
+}
+
+// clearStore deletes keys from the store and flushes it.
+func (pm *PersistentMap[K, V]) clearStore(ctx context.Context, keys []K) error {
+	for _, k := range keys {
+		if err := pm.store.Delete(ctx, k); err != nil {
+			return err
+		}
+	}
+	return pm.store.Flush(ctx)
+}
+
+// resync undoes the changes a failed write-through mutation may have left
+// buffered in the store, by writing the map's values of keys back to it, so
+// that a later flush does not persist a mutation that was never applied.
+// Errors are reported to OnError. The caller must hold pm.mu.
+func (pm *PersistentMap[K, V]) resync(ctx context.Context, keys []K) {
+	for _, k := range keys {
+		w := pendingWrite[V]{deleted: true}
+		if r := pm.sm.Get(k); r.Found {
+			w = pendingWrite[V]{value: r.Value}
+		}
+		if err := pm.storeWrite(ctx, k, w); err != nil && pm.onError != nil {
+			pm.onError(err)
+		}
+	}
+}
+
+// checkOpen returns ErrPersistentMapClosed after Close. The caller must hold pm.mu.
+func (pm *PersistentMap[K, V]) checkOpen() error {
+	if pm.closed {
+		return ErrPersistentMapClosed
+	}
+	return nil
+}
+
+// flushBatch writes a batch of changes to the store and flushes it.
+func (pm *PersistentMap[K, V]) flushBatch(ctx context.Context, batch map[K]pendingWrite[V]) error {
+	for k, w := range batch {
+		if err := pm.storeWrite(ctx, k, w); err != nil {
+			return err
+		}
+	}
+	return pm.store.Flush(ctx)
+}
+
+// requeue puts the changes of a failed batch back, unless a key has been
+// written again since.
+func (pm *PersistentMap[K, V]) requeue(batch map[K]pendingWrite[V]) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	for k, w := range batch {
+		if _, newer := pm.pending[k]; !newer {
+			pm.pending[k] = w
+		}
+	}
+}
+
+// flushLoop flushes write-behind changes every interval until Close.
+func (pm *PersistentMap[K, V]) flushLoop(interval time.Duration) {
+	defer close(pm.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-pm.stop:
+			return
+		case <-ticker.C:
+			if err := pm.Flush(context.Background()); err != nil && pm.onError != nil {
+				pm.onError(err)
+			}
+		}
+	}
+}
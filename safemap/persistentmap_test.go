@@ -0,0 +1,292 @@
+package safemap
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// memStore is an in-memory Store that records its calls and can be made to fail.
+type memStore struct {
+	mu        sync.Mutex
+	m         map[string]int
+	durable   map[string]int // contents as of the last successful Flush
+	puts      int
+	flushes   int
+	fail      error
+	failFlush error
+	failKey   string // Put and Delete of this key fail with fail
+}
+
+func newMemStore(m map[string]int) *memStore {
+	return &memStore{m: m, durable: maps.Clone(m)}
+}
+
+func (s *memStore) Load(ctx context.Context) (map[string]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]int, len(s.m))
+	for k, v := range s.m {
+		out[k] = v
+	}
+	return out, nil
+}
+
+func (s *memStore) Put(ctx context.Context, k string, v int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure(k); err != nil {
+		return err
+	}
+	s.m[k] = v
+	s.puts++
+	return nil
+}
+
+func (s *memStore) Delete(ctx context.Context, k string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.failure(k); err != nil {
+		return err
+	}
+	delete(s.m, k)
+	return nil
+}
+
+func (s *memStore) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushes++
+	if s.fail != nil {
+		return s.fail
+	}
+	if s.failFlush != nil {
+		return s.failFlush
+	}
+	s.durable = maps.Clone(s.m)
+	return nil
+}
+
+func (s *memStore) failure(k string) error {
+	if s.failKey != "" && k != s.failKey {
+		return nil
+	}
+	return s.fail
+}
+
+func (s *memStore) setFail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fail = err
+}
+
+func (s *memStore) setFailFlush(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failFlush = err
+}
+
+func (s *memStore) setFailKey(k string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failKey, s.fail = k, err
+}
+
+func (s *memStore) durableSnapshot() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return maps.Clone(s.durable)
+}
+
+func (s *memStore) snapshot() (map[string]int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]int, len(s.m))
+	for k, v := range s.m {
+		out[k] = v
+	}
+	return out, s.puts
+}
+
+func TestPersistentMap_WriteThrough(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore(map[string]int{"a": 1})
+	pm, err := NewPersistentMap[string, int](ctx, store, PersistOptions{})
+	require.NoError(t, err)
+	require.Equal(t, 1, pm.Get("a").Value)
+
+	require.NoError(t, pm.Set("b", 2))
+	ok, err := pm.SetNX("b", 3)
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, err = pm.SetNX("c", 3)
+	require.NoError(t, err)
+	require.True(t, ok)
+	v, ok, err := pm.Pop("a")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 1, v)
+	require.NoError(t, pm.Delete("missing"))
+
+	stored, _ := store.snapshot()
+	require.Equal(t, map[string]int{"b": 2, "c": 3}, stored)
+	require.Equal(t, stored, pm.Export())
+
+	require.NoError(t, pm.Clear())
+	stored, _ = store.snapshot()
+	require.Empty(t, stored)
+	require.Equal(t, 0, pm.Len())
+}
+
+func TestPersistentMap_WriteThroughError(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore(map[string]int{"a": 1})
+	pm, err := NewPersistentMap[string, int](ctx, store, PersistOptions{Mode: WriteThrough})
+	require.NoError(t, err)
+
+	errDisk := errors.New("disk full")
+	store.setFail(errDisk)
+	require.ErrorIs(t, pm.Set("b", 2), errDisk)
+	require.ErrorIs(t, pm.Delete("a"), errDisk)
+	require.ErrorIs(t, pm.Clear(), errDisk)
+	require.Equal(t, map[string]int{"a": 1}, pm.Export())
+}
+
+func TestPersistentMap_WriteBehind(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore(map[string]int{})
+	pm, err := NewPersistentMap[string, int](ctx, store, PersistOptions{Mode: WriteBehind, FlushInterval: time.Hour})
+	require.NoError(t, err)
+	defer pm.Close(ctx)
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, pm.Set("counter", i))
+	}
+	require.NoError(t, pm.Set("tmp", 1))
+	require.NoError(t, pm.Delete("tmp"))
+	require.Equal(t, 9, pm.Get("counter").Value)
+
+	stored, puts := store.snapshot()
+	require.Empty(t, stored)
+	require.Zero(t, puts)
+
+	require.NoError(t, pm.Flush(ctx))
+	stored, puts = store.snapshot()
+	require.Equal(t, map[string]int{"counter": 9}, stored)
+	require.Equal(t, 1, puts)
+}
+
+func TestPersistentMap_WriteBehindBackground(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore(map[string]int{})
+	pm, err := NewPersistentMap[string, int](ctx, store, PersistOptions{Mode: WriteBehind, FlushInterval: 5 * time.Millisecond})
+	require.NoError(t, err)
+	defer pm.Close(ctx)
+
+	require.NoError(t, pm.Set("a", 1))
+	require.Eventually(t, func() bool {
+		stored, _ := store.snapshot()
+		return stored["a"] == 1
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestPersistentMap_WriteBehindRetry(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore(map[string]int{})
+	pm, err := NewPersistentMap[string, int](ctx, store, PersistOptions{Mode: WriteBehind, FlushInterval: time.Hour})
+	require.NoError(t, err)
+
+	errDisk := errors.New("disk full")
+	store.setFail(errDisk)
+	require.NoError(t, pm.Set("a", 1))
+	require.NoError(t, pm.Set("b", 1))
+	require.ErrorIs(t, pm.Flush(ctx), errDisk)
+
+	// a newer write wins over the requeued one
+	require.NoError(t, pm.Set("b", 2))
+	store.setFail(nil)
+	require.NoError(t, pm.Close(ctx))
+	stored, _ := store.snapshot()
+	require.Equal(t, map[string]int{"a": 1, "b": 2}, stored)
+
+	require.ErrorIs(t, pm.Set("c", 3), ErrPersistentMapClosed)
+	require.NoError(t, pm.Close(ctx))
+}
+
+func TestPersistentMap_SetNXClosed(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore(map[string]int{"a": 1})
+	pm, err := NewPersistentMap[string, int](ctx, store, PersistOptions{})
+	require.NoError(t, err)
+	require.NoError(t, pm.Close(ctx))
+
+	ok, err := pm.SetNX("a", 2)
+	require.ErrorIs(t, err, ErrPersistentMapClosed)
+	require.False(t, ok)
+	ok, err = pm.SetNX("b", 2)
+	require.ErrorIs(t, err, ErrPersistentMapClosed)
+	require.False(t, ok)
+}
+
+func TestPersistentMap_WriteThroughFlushError(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore(map[string]int{"a": 1})
+	pm, err := NewPersistentMap[string, int](ctx, store, PersistOptions{})
+	require.NoError(t, err)
+
+	errDisk := errors.New("disk full")
+	store.setFailFlush(errDisk)
+	require.ErrorIs(t, pm.Set("x", 1), errDisk)
+	require.ErrorIs(t, pm.Set("a", 5), errDisk)
+	require.ErrorIs(t, pm.Delete("a"), errDisk)
+	require.Equal(t, map[string]int{"a": 1}, pm.Export())
+
+	// the failed mutations are not left buffered in the store
+	store.setFailFlush(nil)
+	require.NoError(t, pm.Set("y", 2))
+	require.Equal(t, map[string]int{"a": 1, "y": 2}, store.durableSnapshot())
+}
+
+func TestPersistentMap_WriteThroughClearError(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore(map[string]int{"a": 1, "b": 2, "c": 3})
+	pm, err := NewPersistentMap[string, int](ctx, store, PersistOptions{})
+	require.NoError(t, err)
+
+	// some keys may be deleted before the failing one
+	errDisk := errors.New("disk full")
+	store.setFailKey("b", errDisk)
+	require.ErrorIs(t, pm.Clear(), errDisk)
+	require.Equal(t, 3, pm.Len())
+
+	store.setFailKey("", nil)
+	store.setFailFlush(errDisk)
+	require.ErrorIs(t, pm.Clear(), errDisk)
+	require.Equal(t, 3, pm.Len())
+
+	store.setFailFlush(nil)
+	require.NoError(t, pm.Set("d", 4))
+	require.Equal(t, map[string]int{"a": 1, "b": 2, "c": 3, "d": 4}, store.durableSnapshot())
+}
+
+func TestPersistentMap_FileStoreRestart(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "sessions.json")
+
+	pm, err := NewPersistentMap[int, string](ctx, NewFileStore[int, string](path), PersistOptions{Mode: WriteBehind})
+	require.NoError(t, err)
+	require.NoError(t, pm.Set(1, "alice"))
+	require.NoError(t, pm.Set(2, "bob"))
+	require.NoError(t, pm.Delete(1))
+	require.NoError(t, pm.Close(ctx))
+
+	restarted, err := NewPersistentMap[int, string](ctx, NewFileStore[int, string](path), PersistOptions{})
+	require.NoError(t, err)
+	require.Equal(t, map[int]string{2: "bob"}, restarted.Export())
+}
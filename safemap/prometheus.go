@@ -0,0 +1,133 @@
+package safemap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// PrometheusOptions describes how a counter map is written in the Prometheus
+// text exposition format.
+type PrometheusOptions struct {
+	// Name is the metric name. It is required.
+	Name string
+	// Help is the optional HELP text of the metric.
+	Help string
+	// Label is the name of the label that holds the key. It defaults to "key".
+	Label string
+	// Type is the metric type: "counter" (the default), "gauge" or "untyped".
+	Type string
+}
+
+// WritePrometheus writes a snapshot of the counters to w in the Prometheus
+// text exposition format, one sample per key labelled with the key formatted
+// by %v. Samples are sorted by label value.
+//
+// It returns an error, and writes nothing, if the metric or label name is not
+// a valid Prometheus name, if the type is not one of those listed in
+// PrometheusOptions, or if two distinct keys format as the same label
+// value, since that would produce duplicate series.
+func (cm *CounterMap[K]) WritePrometheus(w io.Writer, opts PrometheusOptions) error {
+	return writePrometheus(w, opts, cm.Snapshot(), func(v int64) string {
+		return strconv.FormatInt(v, 10)
+	})
+}
+
+// WritePrometheus writes a snapshot of the counters to w in the Prometheus
+// text exposition format. See CounterMap.WritePrometheus.
+func (fm *FloatCounterMap[K]) WritePrometheus(w io.Writer, opts PrometheusOptions) error {
+	return writePrometheus(w, opts, fm.Snapshot(), formatPrometheusFloat)
+}
+
+// writePrometheus writes the samples of one metric family.
+func writePrometheus[K comparable, N int64 | float64](w io.Writer, opts PrometheusOptions, snapshot map[K]N, format func(N) string) error {
+	if opts.Name == "" {
+		return errors.New("safemap: Prometheus metric name is required")
+	}
+	if !validPrometheusName(opts.Name, true) {
+		return fmt.Errorf("safemap: invalid Prometheus metric name %q", opts.Name)
+	}
+	if opts.Label == "" {
+		opts.Label = "key"
+	}
+	if !validPrometheusName(opts.Label, false) {
+		return fmt.Errorf("safemap: invalid Prometheus label name %q", opts.Label)
+	}
+	switch opts.Type {
+	case "":
+		opts.Type = "counter"
+	case "counter", "gauge", "untyped":
+	default:
+		return fmt.Errorf("safemap: invalid Prometheus metric type %q", opts.Type)
+	}
+
+	type sample struct {
+		key   K
+		label string
+		value N
+	}
+	samples := make([]sample, 0, len(snapshot))
+	for k, v := range snapshot {
+		samples = append(samples, sample{key: k, label: fmt.Sprintf("%v", k), value: v})
+	}
+	slices.SortFunc(samples, func(a, b sample) int {
+		return strings.Compare(a.label, b.label)
+	})
+	for i := 1; i < len(samples); i++ {
+		if samples[i].label == samples[i-1].label {
+			return fmt.Errorf("safemap: keys %#v and %#v have the same Prometheus label value %q",
+				samples[i-1].key, samples[i].key, samples[i].label)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	if opts.Help != "" {
+		fmt.Fprintf(bw, "# HELP %s %s\n", opts.Name, helpEscaper.Replace(opts.Help))
+	}
+	fmt.Fprintf(bw, "# TYPE %s %s\n", opts.Name, opts.Type)
+	for _, s := range samples {
+		fmt.Fprintf(bw, "%s{%s=\"%s\"} %s\n", opts.Name, opts.Label, labelEscaper.Replace(s.label), format(s.value))
+	}
+	return bw.Flush()
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// validPrometheusName returns true if name matches [a-zA-Z_:][a-zA-Z0-9_:]*,
+// the syntax of metric names, or [a-zA-Z_][a-zA-Z0-9_]* if colons is false,
+// the syntax of label names.
+func validPrometheusName(name string, colons bool) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+		case c == ':' && colons:
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// formatPrometheusFloat formats a sample value, spelling infinities as Prometheus does.
+func formatPrometheusFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
@@ -4,12 +4,29 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // SafeMap is a thread-safe map.
 type SafeMap[K comparable, V any] struct {
 	sync.RWMutex
-	m map[K]V
+	// data holds the key-value pairs and the deadlines of keys that were
+	// given a TTL.
+	data    segmentedMap[K, V]
+	clock   func() time.Time
+	sweeper *sweeper
+
+	subs map[*Subscription[K, V]]struct{}
+
+	// version is incremented on every mutation.
+	version uint64
+	// shared is set when data is referenced by a snapshot; the next writer
+	// freezes it, so that the segments it then writes to are copied first.
+	shared atomic.Bool
+
+	// id orders lock acquisition in MultiTxn; it is assigned on first use.
+	id atomic.Uint64
 }
 
 // ValueResult is the result of a Get operation on a SafeMap.
@@ -21,30 +38,27 @@ type ValueResult[V any] struct {
 // NewSafeMap creates a new SafeMap.
 func NewSafeMap[K comparable, V any]() *SafeMap[K, V] {
 	sm := new(SafeMap[K, V])
-	sm.m = make(map[K]V)
+	sm.data = newSegmentedMap[K, V]()
 	return sm
 }
 
 // NewSafeMapFromMap creates a new SafeMap from a map.
 func NewSafeMapFromMap[K comparable, V any](m map[K]V) *SafeMap[K, V] {
 	sm := new(SafeMap[K, V])
-	sm.m = make(map[K]V)
-	for k, v := range m {
-		sm.m[k] = v
-	}
+	sm.data = segmentedMapFrom(m)
 	return sm
 }
 
 // NewSafeMapFromKeysValues creates a new SafeMap from keys and values.
 func NewSafeMapFromKeysValues[K comparable, V any](keys []K, values []V) (*SafeMap[K, V], error) {
 	sm := new(SafeMap[K, V])
-	sm.m = make(map[K]V)
+	sm.data = newSegmentedMap[K, V]()
 	if len(keys) != len(values) {
 		return sm, errors.New("keys and values must have the same length")
 	}
 
 	for i := 0; i < len(keys); i++ {
-		sm.m[keys[i]] = values[i]
+		sm.data.set(keys[i], values[i])
 	}
 
 	return sm, nil
@@ -53,7 +67,7 @@ func NewSafeMapFromKeysValues[K comparable, V any](keys []K, values []V) (*SafeM
 // NewSafeMapFromKeyValuePairs creates a new SafeMap from key-value pairs.
 func NewSafeMapFromKeyValuePairs[K comparable, V any](keysValues []any) (*SafeMap[K, V], error) {
 	sm := new(SafeMap[K, V])
-	sm.m = make(map[K]V)
+	sm.data = newSegmentedMap[K, V]()
 	// check if the length of keysValues is even
 	if len(keysValues)%2 != 0 {
 		return sm, errors.New("keysValues must have an even length")
@@ -69,7 +83,7 @@ func NewSafeMapFromKeyValuePairs[K comparable, V any](keysValues []any) (*SafeMa
 			return sm, fmt.Errorf("problem with value: %v of type: %T", keysValues[i+1], keysValues[i+1])
 		}
 
-		sm.m[key] = value
+		sm.data.set(key, value)
 	}
 
 	return sm, nil
@@ -81,7 +95,7 @@ func (sm *SafeMap[K, V]) Get(k K) ValueResult[V] {
 	defer sm.RUnlock()
 	var val V
 
-	if val, ok := sm.m[k]; ok {
+	if val, ok := sm.data.get(k); ok && !sm.expiredNow(k) {
 		return ValueResult[V]{Value: val, Found: true}
 	}
 
@@ -89,18 +103,26 @@ func (sm *SafeMap[K, V]) Get(k K) ValueResult[V] {
 }
 
 // Set sets the value associated with the key.
+// Any TTL previously set on the key is removed.
 func (sm *SafeMap[K, V]) Set(k K, v V) {
 	sm.Lock()
 	defer sm.Unlock()
-	sm.m[k] = v
+	sm.dropExpired(k)
+	old, existed := sm.data.get(k)
+	sm.store(k, v)
+	sm.data.clearDeadline(k)
+	sm.publishSet(k, old, existed, v)
 }
 
 // SetNX sets the value associated with the key if the key does not exist.
 func (sm *SafeMap[K, V]) SetNX(k K, v V) bool {
 	sm.Lock()
 	defer sm.Unlock()
-	if _, ok := sm.m[k]; !ok {
-		sm.m[k] = v
+	sm.dropExpired(k)
+	if _, ok := sm.data.get(k); !ok {
+		sm.store(k, v)
+		var zero V
+		sm.publishSet(k, zero, false, v)
 		return true
 	}
 	return false
@@ -110,49 +132,162 @@ func (sm *SafeMap[K, V]) SetNX(k K, v V) bool {
 func (sm *SafeMap[K, V]) Delete(k K) {
 	sm.Lock()
 	defer sm.Unlock()
-	delete(sm.m, k)
+	sm.dropExpired(k)
+	if old, existed := sm.data.get(k); existed {
+		sm.remove(k)
+		sm.publishRemove(OpDelete, k, old)
+	}
 }
 
 // Pop deletes the key-value pair associated with the key and returns the value.
 func (sm *SafeMap[K, V]) Pop(k K) (V, bool) {
 	sm.Lock()
 	defer sm.Unlock()
-	v, ok := sm.m[k]
-	delete(sm.m, k)
+	sm.dropExpired(k)
+	v, ok := sm.data.get(k)
+	if ok {
+		sm.remove(k)
+		sm.publishRemove(OpPop, k, v)
+	}
 	return v, ok
 }
 
+// Compute atomically computes a new value for the key from its current value.
+// fn receives the current value and whether the key exists; it returns the new
+// value and whether the key should be kept. If keep is false, the key is deleted.
+// Compute returns the resulting value and whether the key is present afterwards.
+// The remaining TTL of an existing key is preserved.
+//
+// fn runs while the map is locked, so it must not call methods on the map, or it deadlocks.
+func (sm *SafeMap[K, V]) Compute(k K, fn func(old V, found bool) (v V, keep bool)) (V, bool) {
+	sm.Lock()
+	defer sm.Unlock()
+	sm.dropExpired(k)
+	old, found := sm.data.get(k)
+	v, keep := fn(old, found)
+	if !keep {
+		if found {
+			sm.remove(k)
+			sm.publishRemove(OpDelete, k, old)
+		}
+		var zero V
+		return zero, false
+	}
+	sm.store(k, v)
+	sm.publishSet(k, old, found, v)
+	return v, true
+}
+
+// Update atomically replaces the value of an existing key with the result of fn.
+// If the key does not exist, fn is not called. Update returns the new value and
+// whether the key was updated. The remaining TTL of the key is preserved.
+//
+// fn runs while the map is locked, so it must not call methods on the map, or it deadlocks.
+func (sm *SafeMap[K, V]) Update(k K, fn func(old V) V) (V, bool) {
+	sm.Lock()
+	defer sm.Unlock()
+	sm.dropExpired(k)
+	old, found := sm.data.get(k)
+	if !found {
+		var zero V
+		return zero, false
+	}
+	v := fn(old)
+	sm.store(k, v)
+	sm.publishSet(k, old, true, v)
+	return v, true
+}
+
+// GetOrCompute returns the value associated with the key. If the key does not
+// exist, the value returned by fn is stored and returned. The boolean result is
+// true if fn was called.
+//
+// fn runs while the map is locked, so it must not call methods on the map, or it deadlocks.
+func (sm *SafeMap[K, V]) GetOrCompute(k K, fn func() V) (V, bool) {
+	sm.Lock()
+	defer sm.Unlock()
+	sm.dropExpired(k)
+	if v, found := sm.data.get(k); found {
+		return v, false
+	}
+	v := fn()
+	sm.store(k, v)
+	var zero V
+	sm.publishSet(k, zero, false, v)
+	return v, true
+}
+
+// CompareAndSwap sets the value associated with the key to new if the key
+// exists and its current value is equal to old according to eq.
+// It returns true if the value was swapped. The remaining TTL of the key is preserved.
+//
+// eq runs while the map is locked, so it must not call methods on the map, or it deadlocks.
+func (sm *SafeMap[K, V]) CompareAndSwap(k K, old, new V, eq func(a, b V) bool) bool {
+	sm.Lock()
+	defer sm.Unlock()
+	sm.dropExpired(k)
+	cur, found := sm.data.get(k)
+	if !found || !eq(cur, old) {
+		return false
+	}
+	sm.store(k, new)
+	sm.publishSet(k, cur, true, new)
+	return true
+}
+
 // Len returns the number of key-value pairs.
 func (sm *SafeMap[K, V]) Len() int {
-	return len(sm.m)
+	sm.RLock()
+	defer sm.RUnlock()
+	return sm.lenLocked()
 }
 
 // IsEmpty returns true if the map is empty.
 func (sm *SafeMap[K, V]) IsEmpty() bool {
-	return len(sm.m) == 0
+	return sm.Len() == 0
 }
 
 // Clear deletes all key-value pairs.
 func (sm *SafeMap[K, V]) Clear() {
 	sm.Lock()
 	defer sm.Unlock()
-	sm.m = make(map[K]V)
+	sm.clearLocked()
+}
+
+// clearLocked deletes all key-value pairs. The caller must hold the write lock.
+func (sm *SafeMap[K, V]) clearLocked() {
+	if len(sm.subs) > 0 {
+		now := sm.now()
+		for k, v := range sm.data.all() {
+			if !sm.expired(k, now) {
+				sm.publishRemove(OpClear, k, v)
+			}
+		}
+	}
+	sm.data = newSegmentedMap[K, V]()
+	sm.shared.Store(false)
+	sm.version++
 }
 
-// GetMap returns the underlying map.
-// Attention: the returned map is not thread-safe.
+// GetMap returns a copy of the underlying map.
+// The copy is never shared with the SafeMap or its snapshots, so the caller
+// may read and modify it freely. Expired keys are not included.
 func (sm *SafeMap[K, V]) GetMap() map[K]V {
 	sm.RLock()
 	defer sm.RUnlock()
-	return sm.m
+	return sm.exportLocked()
 }
 
 // GetKeys returns the keys of the map as a slice.
 func (sm *SafeMap[K, V]) GetKeys() []K {
 	sm.RLock()
 	defer sm.RUnlock()
-	keys := make([]K, 0, len(sm.m))
-	for k := range sm.m {
+	now := sm.now()
+	keys := make([]K, 0, sm.data.len())
+	for k := range sm.data.all() {
+		if sm.expired(k, now) {
+			continue
+		}
 		keys = append(keys, k)
 	}
 	return keys
@@ -162,8 +297,12 @@ func (sm *SafeMap[K, V]) GetKeys() []K {
 func (sm *SafeMap[K, V]) GetValues() []V {
 	sm.RLock()
 	defer sm.RUnlock()
-	values := make([]V, 0, len(sm.m))
-	for _, v := range sm.m {
+	now := sm.now()
+	values := make([]V, 0, sm.data.len())
+	for k, v := range sm.data.all() {
+		if sm.expired(k, now) {
+			continue
+		}
 		values = append(values, v)
 	}
 	return values
@@ -173,8 +312,12 @@ func (sm *SafeMap[K, V]) GetValues() []V {
 func (sm *SafeMap[K, V]) GetKeyValuePairs() []any {
 	sm.RLock()
 	defer sm.RUnlock()
-	keysValues := make([]any, 0, len(sm.m)*2)
-	for k, v := range sm.m {
+	now := sm.now()
+	keysValues := make([]any, 0, sm.data.len()*2)
+	for k, v := range sm.data.all() {
+		if sm.expired(k, now) {
+			continue
+		}
 		keysValues = append(keysValues, k)
 		keysValues = append(keysValues, v)
 	}
@@ -186,9 +329,13 @@ func (sm *SafeMap[K, V]) GetKeyValuePairs() []any {
 func (sm *SafeMap[K, V]) GetKeysValues() ([]K, []V) {
 	sm.RLock()
 	defer sm.RUnlock()
-	keys := make([]K, 0, len(sm.m))
-	values := make([]V, 0, len(sm.m))
-	for k, v := range sm.m {
+	now := sm.now()
+	keys := make([]K, 0, sm.data.len())
+	values := make([]V, 0, sm.data.len())
+	for k, v := range sm.data.all() {
+		if sm.expired(k, now) {
+			continue
+		}
 		keys = append(keys, k)
 		values = append(values, v)
 	}
@@ -196,12 +343,21 @@ func (sm *SafeMap[K, V]) GetKeysValues() ([]K, []V) {
 }
 
 // Copy returns a new SafeMap with the same key-value pairs.
+// Keys with a TTL keep the same deadline in the copy.
 func (sm *SafeMap[K, V]) Copy() *SafeMap[K, V] {
 	sm.RLock()
 	defer sm.RUnlock()
+	now := sm.now()
 	newSm := NewSafeMap[K, V]()
-	for k, v := range sm.m {
-		newSm.Set(k, v)
+	newSm.clock = sm.clock
+	for k, v := range sm.data.all() {
+		if sm.expired(k, now) {
+			continue
+		}
+		newSm.data.set(k, v)
+		if d, ok := sm.data.deadline(k); ok {
+			newSm.data.setDeadline(k, d)
+		}
 	}
 	return newSm
 }
@@ -210,16 +366,66 @@ func (sm *SafeMap[K, V]) Copy() *SafeMap[K, V] {
 func (sm *SafeMap[K, V]) Export() map[K]V {
 	sm.RLock()
 	defer sm.RUnlock()
-	m := make(map[K]V)
-	for k, v := range sm.m {
-		m[k] = v
-	}
-	return m
+	return sm.exportLocked()
 }
 
 // String returns a string representation of the SafeMap.
 func (sm *SafeMap[K, V]) String() string {
 	sm.RLock()
 	defer sm.RUnlock()
-	return fmt.Sprintf("%v", sm.m)
+	return fmt.Sprintf("%v", sm.exportLocked())
+}
+
+// beginWrite prepares the map for a mutation. If a snapshot references the
+// current data, it is frozen first, so the segments written to afterwards are
+// copied and the snapshot stays unchanged. The caller must hold the write lock.
+func (sm *SafeMap[K, V]) beginWrite() {
+	if sm.shared.Load() {
+		sm.data.freeze()
+		sm.shared.Store(false)
+	}
+	sm.version++
+}
+
+// store sets the value associated with the key, keeping its TTL.
+// The caller must hold the write lock.
+func (sm *SafeMap[K, V]) store(k K, v V) {
+	sm.beginWrite()
+	sm.data.set(k, v)
+}
+
+// remove deletes the key and its TTL. The caller must hold the write lock.
+func (sm *SafeMap[K, V]) remove(k K) {
+	sm.beginWrite()
+	sm.data.delete(k)
+}
+
+// exportLocked returns a copy of the live key-value pairs.
+// The caller must hold at least the read lock.
+func (sm *SafeMap[K, V]) exportLocked() map[K]V {
+	now := sm.now()
+	m := make(map[K]V, sm.data.len())
+	for k, v := range sm.data.all() {
+		if sm.expired(k, now) {
+			continue
+		}
+		m[k] = v
+	}
+	return m
+}
+
+// lenLocked returns the number of live key-value pairs.
+// The caller must hold at least the read lock.
+func (sm *SafeMap[K, V]) lenLocked() int {
+	n := sm.data.len()
+	if sm.data.expiring() == 0 {
+		return n
+	}
+	now := sm.now()
+	for _, deadline := range sm.data.deadlines() {
+		if !now.Before(deadline) {
+			n--
+		}
+	}
+	return n
 }
@@ -1,6 +1,7 @@
 package safemap
 
 import (
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -70,6 +71,93 @@ func TestSafeMap_SetNX(t *testing.T) {
 	require.Equal(t, "one", sm.Get(1).Value)
 }
 
+func TestSafeMap_Compute(t *testing.T) {
+	sm := NewSafeMap[string, int]()
+
+	v, ok := sm.Compute("a", func(old int, found bool) (int, bool) {
+		require.False(t, found)
+		return old + 1, true
+	})
+	require.True(t, ok)
+	require.Equal(t, 1, v)
+
+	v, ok = sm.Compute("a", func(old int, found bool) (int, bool) {
+		require.True(t, found)
+		return old + 1, true
+	})
+	require.True(t, ok)
+	require.Equal(t, 2, v)
+	require.Equal(t, 2, sm.Get("a").Value)
+
+	v, ok = sm.Compute("a", func(old int, found bool) (int, bool) {
+		return 0, false
+	})
+	require.False(t, ok)
+	require.Equal(t, 0, v)
+	require.False(t, sm.Get("a").Found)
+}
+
+func TestSafeMap_ComputeConcurrent(t *testing.T) {
+	sm := NewSafeMap[string, int]()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				sm.Compute("counter", func(old int, _ bool) (int, bool) {
+					return old + 1, true
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, 5000, sm.Get("counter").Value)
+}
+
+func TestSafeMap_Update(t *testing.T) {
+	sm := NewSafeMap[string, int]()
+	called := false
+	v, ok := sm.Update("a", func(old int) int {
+		called = true
+		return old + 1
+	})
+	require.False(t, ok)
+	require.False(t, called)
+	require.Equal(t, 0, v)
+	require.False(t, sm.Get("a").Found)
+
+	sm.Set("a", 10)
+	v, ok = sm.Update("a", func(old int) int { return old * 2 })
+	require.True(t, ok)
+	require.Equal(t, 20, v)
+	require.Equal(t, 20, sm.Get("a").Value)
+}
+
+func TestSafeMap_GetOrCompute(t *testing.T) {
+	sm := NewSafeMap[string, int]()
+	v, computed := sm.GetOrCompute("a", func() int { return 1 })
+	require.True(t, computed)
+	require.Equal(t, 1, v)
+
+	v, computed = sm.GetOrCompute("a", func() int { return 2 })
+	require.False(t, computed)
+	require.Equal(t, 1, v)
+}
+
+func TestSafeMap_CompareAndSwap(t *testing.T) {
+	eq := func(a, b string) bool { return a == b }
+	sm := NewSafeMap[int, string]()
+	require.False(t, sm.CompareAndSwap(1, "", "one", eq))
+	require.False(t, sm.Get(1).Found)
+
+	sm.Set(1, "one")
+	require.False(t, sm.CompareAndSwap(1, "two", "three", eq))
+	require.Equal(t, "one", sm.Get(1).Value)
+	require.True(t, sm.CompareAndSwap(1, "one", "uno", eq))
+	require.Equal(t, "uno", sm.Get(1).Value)
+}
+
 func TestSafeMap_Get(t *testing.T) {
 	sm := NewSafeMap[int, string]()
 	sm.Set(1, "one")
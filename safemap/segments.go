@@ -0,0 +1,211 @@
+package safemap
+
+import (
+	"iter"
+	"maps"
+	"slices"
+	"time"
+)
+
+// segmentSize is the average number of keys per segment above which a
+// segmentedMap doubles its number of segments.
+const segmentSize = 128
+
+// segmentedMap is the storage of a SafeMap: a hash map split into segments by
+// the top bits of the key hash, with the TTL deadlines of the keys stored next
+// to their values.
+//
+// It makes snapshots cheap. A snapshot is a copy of the segmentedMap value,
+// after which the owner calls freeze. From then on the segments and the
+// segment table are shared, and a write copies only the table and the one
+// segment it touches, so it costs O(n/segmentSize + segmentSize) instead of
+// O(n). Later writes to the same segment in the same generation copy nothing.
+//
+// A map with a single segment never hashes its keys.
+type segmentedMap[K comparable, V any] struct {
+	segs    []*segment[K, V]
+	bits    uint // len(segs) == 1<<bits
+	n       int  // number of keys
+	nexpiry int  // number of keys with a deadline
+	hash    func(K) uint64
+
+	// gen is the current write generation. Segments and a segment table
+	// allocated in an older generation may be shared with a snapshot.
+	gen     uint64
+	segsGen uint64
+}
+
+// segment holds the keys whose hashes share the same top bits.
+type segment[K comparable, V any] struct {
+	m      map[K]V
+	expiry map[K]time.Time
+	gen    uint64
+}
+
+// newSegmentedMap returns an empty segmentedMap with a single segment.
+func newSegmentedMap[K comparable, V any]() segmentedMap[K, V] {
+	return segmentedMap[K, V]{segs: []*segment[K, V]{{m: make(map[K]V)}}}
+}
+
+// segmentedMapFrom returns a segmentedMap holding the key-value pairs of m.
+func segmentedMapFrom[K comparable, V any](m map[K]V) segmentedMap[K, V] {
+	c := newSegmentedMap[K, V]()
+	for k, v := range m {
+		c.set(k, v)
+	}
+	return c
+}
+
+// index returns the index of the segment that holds the key.
+func (c *segmentedMap[K, V]) index(k K) int {
+	if c.bits == 0 {
+		return 0
+	}
+	return int(c.hash(k) >> (64 - c.bits))
+}
+
+// get returns the value associated with the key.
+func (c *segmentedMap[K, V]) get(k K) (V, bool) {
+	v, ok := c.segs[c.index(k)].m[k]
+	return v, ok
+}
+
+// deadline returns the TTL deadline of the key.
+func (c *segmentedMap[K, V]) deadline(k K) (time.Time, bool) {
+	if c.nexpiry == 0 {
+		return time.Time{}, false
+	}
+	d, ok := c.segs[c.index(k)].expiry[k]
+	return d, ok
+}
+
+// len returns the number of keys, expired or not.
+func (c *segmentedMap[K, V]) len() int {
+	return c.n
+}
+
+// expiring returns the number of keys that have a deadline.
+func (c *segmentedMap[K, V]) expiring() int {
+	return c.nexpiry
+}
+
+// all returns an iterator over the key-value pairs, expired or not.
+func (c *segmentedMap[K, V]) all() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for _, seg := range c.segs {
+			for k, v := range seg.m {
+				if !yield(k, v) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// deadlines returns an iterator over the keys that have a deadline.
+func (c *segmentedMap[K, V]) deadlines() iter.Seq2[K, time.Time] {
+	return func(yield func(K, time.Time) bool) {
+		if c.nexpiry == 0 {
+			return
+		}
+		for _, seg := range c.segs {
+			for k, d := range seg.expiry {
+				if !yield(k, d) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// freeze makes the current segments read-only, so that a copy of c taken
+// before the call stays unchanged by later writes.
+func (c *segmentedMap[K, V]) freeze() {
+	c.gen++
+}
+
+// writable returns the segment that holds the key, copying it and the segment
+// table first if they belong to an older generation.
+func (c *segmentedMap[K, V]) writable(k K) *segment[K, V] {
+	if c.segsGen != c.gen {
+		c.segs = slices.Clone(c.segs)
+		c.segsGen = c.gen
+	}
+	i := c.index(k)
+	seg := c.segs[i]
+	if seg.gen != c.gen {
+		seg = &segment[K, V]{m: maps.Clone(seg.m), expiry: maps.Clone(seg.expiry), gen: c.gen}
+		c.segs[i] = seg
+	}
+	return seg
+}
+
+// set sets the value associated with the key, keeping its deadline.
+func (c *segmentedMap[K, V]) set(k K, v V) {
+	seg := c.writable(k)
+	if _, ok := seg.m[k]; !ok {
+		c.n++
+	}
+	seg.m[k] = v
+	if c.n > segmentSize<<c.bits {
+		c.grow()
+	}
+}
+
+// setDeadline sets the deadline of an existing key.
+func (c *segmentedMap[K, V]) setDeadline(k K, d time.Time) {
+	seg := c.writable(k)
+	if seg.expiry == nil {
+		seg.expiry = make(map[K]time.Time)
+	}
+	if _, ok := seg.expiry[k]; !ok {
+		c.nexpiry++
+	}
+	seg.expiry[k] = d
+}
+
+// clearDeadline removes the deadline of the key, if any.
+func (c *segmentedMap[K, V]) clearDeadline(k K) {
+	if _, ok := c.deadline(k); !ok {
+		return
+	}
+	delete(c.writable(k).expiry, k)
+	c.nexpiry--
+}
+
+// delete removes the key and its deadline, if any.
+func (c *segmentedMap[K, V]) delete(k K) {
+	if _, ok := c.get(k); !ok {
+		return
+	}
+	c.clearDeadline(k)
+	delete(c.writable(k).m, k)
+	c.n--
+}
+
+// grow doubles the number of segments, splitting every segment in two by the
+// next bit of the key hash. The new segments belong to the current generation.
+func (c *segmentedMap[K, V]) grow() {
+	if c.hash == nil {
+		c.hash = newDefaultHasher[K]()
+	}
+	c.bits++
+	segs := make([]*segment[K, V], 1<<c.bits)
+	for i := range segs {
+		segs[i] = &segment[K, V]{m: make(map[K]V, segmentSize), gen: c.gen}
+	}
+	for _, seg := range c.segs {
+		for k, v := range seg.m {
+			dst := segs[c.index(k)]
+			dst.m[k] = v
+			if d, ok := seg.expiry[k]; ok {
+				if dst.expiry == nil {
+					dst.expiry = make(map[K]time.Time)
+				}
+				dst.expiry[k] = d
+			}
+		}
+	}
+	c.segs = segs
+	c.segsGen = c.gen
+}
@@ -0,0 +1,70 @@
+package safemap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSegmentedMap_Grow(t *testing.T) {
+	c := newSegmentedMap[int, int]()
+	deadline := time.Unix(1000, 0)
+	for i := 0; i < 10*segmentSize; i++ {
+		c.set(i, i)
+		if i%3 == 0 {
+			c.setDeadline(i, deadline)
+		}
+	}
+	require.Greater(t, len(c.segs), 1)
+	require.Equal(t, 10*segmentSize, c.len())
+
+	// keys and deadlines survive the splits
+	for i := 0; i < 10*segmentSize; i++ {
+		v, ok := c.get(i)
+		require.True(t, ok)
+		require.Equal(t, i, v)
+		_, ok = c.deadline(i)
+		require.Equal(t, i%3 == 0, ok)
+	}
+
+	c.delete(0)
+	c.delete(1)
+	c.delete(-1)
+	require.Equal(t, 10*segmentSize-2, c.len())
+	require.Equal(t, (10*segmentSize+2)/3-1, c.expiring())
+}
+
+func TestSegmentedMap_WriteCopiesOneSegment(t *testing.T) {
+	sm := NewSafeMap[int, int]()
+	for i := 0; i < 20*segmentSize; i++ {
+		sm.Set(i, i)
+	}
+	snap := sm.Snapshot()
+	sm.Set(0, -1)
+	sm.Set(1, -1)
+
+	// the segments that were not written to are still shared with the snapshot
+	shared := 0
+	for i, seg := range sm.data.segs {
+		if seg == snap.data.segs[i] {
+			shared++
+		}
+	}
+	require.GreaterOrEqual(t, shared, len(sm.data.segs)-2)
+	require.Equal(t, 0, snap.Get(0).Value)
+	require.Equal(t, 1, snap.Get(1).Value)
+	require.Equal(t, -1, sm.Get(0).Value)
+}
+
+func TestSegmentedMap_SnapshotSurvivesGrow(t *testing.T) {
+	sm := NewSafeMap[int, int]()
+	sm.Set(0, 0)
+	snap := sm.Snapshot()
+	for i := 1; i < 5*segmentSize; i++ {
+		sm.Set(i, i)
+	}
+	require.Equal(t, 1, snap.Len())
+	require.Equal(t, map[int]int{0: 0}, snap.Export())
+	require.Equal(t, 5*segmentSize, sm.Len())
+}
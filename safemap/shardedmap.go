@@ -0,0 +1,356 @@
+package safemap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/maphash"
+	"math"
+	"reflect"
+)
+
+// DefaultShardCount is the number of shards used when NewShardedMap is called
+// with a non-positive shard count.
+const DefaultShardCount = 32
+
+// ShardedMap is a thread-safe map that spreads its keys across a fixed number
+// of independently locked shards, so that writers touching unrelated keys do
+// not contend on a single lock.
+type ShardedMap[K comparable, V any] struct {
+	shards []*SafeMap[K, V]
+	hash   func(K) uint64
+}
+
+// NewShardedMap creates a new ShardedMap with the given number of shards.
+// If shards is not positive, DefaultShardCount is used.
+func NewShardedMap[K comparable, V any](shards int) *ShardedMap[K, V] {
+	return NewShardedMapWithHasher[K, V](shards, nil)
+}
+
+// NewShardedMapWithHasher creates a new ShardedMap that uses hash to pick the
+// shard of a key. If shards is not positive, DefaultShardCount is used.
+// If hash is nil, the default hasher of NewShardedMap is used.
+func NewShardedMapWithHasher[K comparable, V any](shards int, hash func(K) uint64) *ShardedMap[K, V] {
+	if shards <= 0 {
+		shards = DefaultShardCount
+	}
+	if hash == nil {
+		hash = newDefaultHasher[K]()
+	}
+	sm := &ShardedMap[K, V]{
+		shards: make([]*SafeMap[K, V], shards),
+		hash:   hash,
+	}
+	for i := range sm.shards {
+		sm.shards[i] = NewSafeMap[K, V]()
+	}
+	return sm
+}
+
+// NewShardedMapFromMap creates a new ShardedMap from a map.
+func NewShardedMapFromMap[K comparable, V any](shards int, m map[K]V) *ShardedMap[K, V] {
+	sm := NewShardedMap[K, V](shards)
+	for k, v := range m {
+		sm.shardFor(k).data.set(k, v)
+	}
+	return sm
+}
+
+// newDefaultHasher returns a hash function for K that agrees with ==: equal
+// keys always hash the same. Strings and numbers are hashed directly; any other
+// key type is hashed by walking its value with reflection, see hashValue.
+func newDefaultHasher[K comparable]() func(K) uint64 {
+	seed := maphash.MakeSeed()
+	return func(k K) uint64 {
+		switch key := any(k).(type) {
+		case string:
+			return maphash.String(seed, key)
+		case int:
+			return mixUint64(uint64(key))
+		case int8:
+			return mixUint64(uint64(key))
+		case int16:
+			return mixUint64(uint64(key))
+		case int32:
+			return mixUint64(uint64(key))
+		case int64:
+			return mixUint64(uint64(key))
+		case uint:
+			return mixUint64(uint64(key))
+		case uint8:
+			return mixUint64(uint64(key))
+		case uint16:
+			return mixUint64(uint64(key))
+		case uint32:
+			return mixUint64(uint64(key))
+		case uint64:
+			return mixUint64(key)
+		case uintptr:
+			return mixUint64(uint64(key))
+		case float32:
+			return mixUint64(math.Float64bits(normalizeZero(float64(key))))
+		case float64:
+			return mixUint64(math.Float64bits(normalizeZero(key)))
+		case bool:
+			if key {
+				return 1
+			}
+			return 0
+		default:
+			var h maphash.Hash
+			h.SetSeed(seed)
+			hashValue(&h, reflect.ValueOf(&k).Elem())
+			return h.Sum64()
+		}
+	}
+}
+
+// hashValue writes a comparable value to h so that values equal under ==
+// produce the same bytes. Pointers, channels and unsafe pointers are hashed by
+// address, interfaces by dynamic type and value, and arrays and structs field
+// by field, skipping blank fields as == does.
+func hashValue(h *maphash.Hash, v reflect.Value) {
+	var buf [8]byte
+	writeUint := func(x uint64) {
+		binary.LittleEndian.PutUint64(buf[:], x)
+		h.Write(buf[:])
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			h.WriteByte(1)
+		} else {
+			h.WriteByte(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeUint(uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		writeUint(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		writeUint(math.Float64bits(normalizeZero(v.Float())))
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		writeUint(math.Float64bits(normalizeZero(real(c))))
+		writeUint(math.Float64bits(normalizeZero(imag(c))))
+	case reflect.String:
+		h.WriteString(v.String())
+	case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		writeUint(uint64(v.Pointer()))
+	case reflect.Array:
+		for i := range v.Len() {
+			hashValue(h, v.Index(i))
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := range v.NumField() {
+			if t.Field(i).Name != "_" {
+				hashValue(h, v.Field(i))
+			}
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			h.WriteByte(0)
+			return
+		}
+		h.WriteByte(1)
+		h.WriteString(v.Elem().Type().String())
+		hashValue(h, v.Elem())
+	default:
+		// not comparable, so it cannot be a map key
+		panic("safemap: cannot hash value of type " + v.Type().String())
+	}
+}
+
+// normalizeZero turns -0 into +0, which == treats as equal.
+func normalizeZero(f float64) float64 {
+	if f == 0 {
+		return 0
+	}
+	return f
+}
+
+// mixUint64 scrambles the bits of x so that sequential integers spread evenly
+// across shards (splitmix64 finalizer).
+func mixUint64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// shardFor returns the shard responsible for the key.
+func (sm *ShardedMap[K, V]) shardFor(k K) *SafeMap[K, V] {
+	return sm.shards[sm.hash(k)%uint64(len(sm.shards))]
+}
+
+// rLockAll read-locks every shard in index order.
+func (sm *ShardedMap[K, V]) rLockAll() {
+	for _, shard := range sm.shards {
+		shard.RLock()
+	}
+}
+
+// rUnlockAll releases the read locks taken by rLockAll.
+func (sm *ShardedMap[K, V]) rUnlockAll() {
+	for i := len(sm.shards) - 1; i >= 0; i-- {
+		sm.shards[i].RUnlock()
+	}
+}
+
+// lockAll write-locks every shard in index order.
+func (sm *ShardedMap[K, V]) lockAll() {
+	for _, shard := range sm.shards {
+		shard.Lock()
+	}
+}
+
+// unlockAll releases the write locks taken by lockAll.
+func (sm *ShardedMap[K, V]) unlockAll() {
+	for i := len(sm.shards) - 1; i >= 0; i-- {
+		sm.shards[i].Unlock()
+	}
+}
+
+// ShardCount returns the number of shards.
+func (sm *ShardedMap[K, V]) ShardCount() int {
+	return len(sm.shards)
+}
+
+// Get returns the value associated with the key.
+func (sm *ShardedMap[K, V]) Get(k K) ValueResult[V] {
+	return sm.shardFor(k).Get(k)
+}
+
+// Set sets the value associated with the key.
+func (sm *ShardedMap[K, V]) Set(k K, v V) {
+	sm.shardFor(k).Set(k, v)
+}
+
+// SetNX sets the value associated with the key if the key does not exist.
+func (sm *ShardedMap[K, V]) SetNX(k K, v V) bool {
+	return sm.shardFor(k).SetNX(k, v)
+}
+
+// Delete deletes the key-value pair associated with the key.
+func (sm *ShardedMap[K, V]) Delete(k K) {
+	sm.shardFor(k).Delete(k)
+}
+
+// Pop deletes the key-value pair associated with the key and returns the value.
+func (sm *ShardedMap[K, V]) Pop(k K) (V, bool) {
+	return sm.shardFor(k).Pop(k)
+}
+
+// Compute atomically computes a new value for the key. See SafeMap.Compute.
+func (sm *ShardedMap[K, V]) Compute(k K, fn func(old V, found bool) (v V, keep bool)) (V, bool) {
+	return sm.shardFor(k).Compute(k, fn)
+}
+
+// Update atomically replaces the value of an existing key. See SafeMap.Update.
+func (sm *ShardedMap[K, V]) Update(k K, fn func(old V) V) (V, bool) {
+	return sm.shardFor(k).Update(k, fn)
+}
+
+// GetOrCompute returns the value associated with the key, storing the result
+// of fn if the key does not exist. See SafeMap.GetOrCompute.
+func (sm *ShardedMap[K, V]) GetOrCompute(k K, fn func() V) (V, bool) {
+	return sm.shardFor(k).GetOrCompute(k, fn)
+}
+
+// CompareAndSwap swaps the value associated with the key if it equals old.
+// See SafeMap.CompareAndSwap.
+func (sm *ShardedMap[K, V]) CompareAndSwap(k K, old, new V, eq func(a, b V) bool) bool {
+	return sm.shardFor(k).CompareAndSwap(k, old, new, eq)
+}
+
+// Len returns the number of key-value pairs across all shards.
+func (sm *ShardedMap[K, V]) Len() int {
+	sm.rLockAll()
+	defer sm.rUnlockAll()
+	return sm.lenLocked()
+}
+
+// IsEmpty returns true if the map is empty.
+func (sm *ShardedMap[K, V]) IsEmpty() bool {
+	return sm.Len() == 0
+}
+
+// Clear deletes all key-value pairs.
+// All shards are locked for the duration of the call, so no reader observes a
+// partially cleared map.
+func (sm *ShardedMap[K, V]) Clear() {
+	sm.lockAll()
+	defer sm.unlockAll()
+	for _, shard := range sm.shards {
+		shard.clearLocked()
+	}
+}
+
+// GetKeys returns the keys of the map as a slice.
+func (sm *ShardedMap[K, V]) GetKeys() []K {
+	sm.rLockAll()
+	defer sm.rUnlockAll()
+	keys := make([]K, 0, sm.lenLocked())
+	for _, shard := range sm.shards {
+		for k := range shard.data.all() {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// GetValues returns the values of the map as a slice.
+func (sm *ShardedMap[K, V]) GetValues() []V {
+	sm.rLockAll()
+	defer sm.rUnlockAll()
+	values := make([]V, 0, sm.lenLocked())
+	for _, shard := range sm.shards {
+		for _, v := range shard.data.all() {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// Copy returns a new ShardedMap with the same shard count and key-value pairs.
+func (sm *ShardedMap[K, V]) Copy() *ShardedMap[K, V] {
+	sm.rLockAll()
+	defer sm.rUnlockAll()
+	newSm := NewShardedMapWithHasher[K, V](len(sm.shards), sm.hash)
+	for i, shard := range sm.shards {
+		for k, v := range shard.data.all() {
+			newSm.shards[i].data.set(k, v)
+		}
+	}
+	return newSm
+}
+
+// Export returns a new map with the same key-value pairs as the ShardedMap.
+// All shards are read-locked at once, so the result is a consistent view.
+func (sm *ShardedMap[K, V]) Export() map[K]V {
+	sm.rLockAll()
+	defer sm.rUnlockAll()
+	m := make(map[K]V, sm.lenLocked())
+	for _, shard := range sm.shards {
+		for k, v := range shard.data.all() {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// String returns a string representation of the ShardedMap.
+func (sm *ShardedMap[K, V]) String() string {
+	return fmt.Sprintf("%v", sm.Export())
+}
+
+// lenLocked returns the number of key-value pairs. The caller must hold the
+// locks of all shards.
+func (sm *ShardedMap[K, V]) lenLocked() int {
+	n := 0
+	for _, shard := range sm.shards {
+		n += shard.data.len()
+	}
+	return n
+}
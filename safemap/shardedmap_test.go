@@ -0,0 +1,292 @@
+package safemap
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewShardedMap(t *testing.T) {
+	sm := NewShardedMap[int, string](8)
+	require.NotNil(t, sm)
+	require.Equal(t, 8, sm.ShardCount())
+	require.True(t, sm.IsEmpty())
+
+	sm = NewShardedMap[int, string](0)
+	require.Equal(t, DefaultShardCount, sm.ShardCount())
+}
+
+func TestNewShardedMapFromMap(t *testing.T) {
+	m := map[string]int{
+		"one":   1,
+		"two":   2,
+		"three": 3,
+	}
+	sm := NewShardedMapFromMap(4, m)
+	require.Equal(t, 3, sm.Len())
+	require.Equal(t, m, sm.Export())
+}
+
+func TestNewShardedMapWithHasher(t *testing.T) {
+	sm := NewShardedMapWithHasher[int, string](4, func(k int) uint64 { return 0 })
+	sm.Set(1, "one")
+	sm.Set(2, "two")
+	require.Equal(t, 2, sm.shards[0].data.len())
+	require.Equal(t, "two", sm.Get(2).Value)
+
+	// a nil hasher falls back to the default one
+	sm = NewShardedMapWithHasher[int, string](4, nil)
+	for i := range 100 {
+		sm.Set(i, "v")
+	}
+	require.Equal(t, 100, sm.Len())
+	require.True(t, sm.Get(42).Found)
+}
+
+func TestShardedMap_SetGet(t *testing.T) {
+	sm := NewShardedMap[int, string](4)
+	sm.Set(1, "one")
+	result := sm.Get(1)
+	require.True(t, result.Found)
+	require.Equal(t, "one", result.Value)
+
+	result = sm.Get(2)
+	require.False(t, result.Found)
+	require.Equal(t, "", result.Value)
+}
+
+func TestShardedMap_SetNX(t *testing.T) {
+	sm := NewShardedMap[int, string](4)
+	require.True(t, sm.SetNX(1, "one"))
+	require.False(t, sm.SetNX(1, "two"))
+	require.Equal(t, 1, sm.Len())
+	require.Equal(t, "one", sm.Get(1).Value)
+}
+
+func TestShardedMap_Delete(t *testing.T) {
+	sm := NewShardedMap[int, string](4)
+	sm.Set(1, "one")
+	sm.Delete(1)
+	require.Equal(t, 0, sm.Len())
+	require.False(t, sm.Get(1).Found)
+}
+
+func TestShardedMap_Pop(t *testing.T) {
+	sm := NewShardedMap[int, string](4)
+	sm.Set(1, "one")
+	v, ok := sm.Pop(1)
+	require.True(t, ok)
+	require.Equal(t, "one", v)
+	v, ok = sm.Pop(1)
+	require.False(t, ok)
+	require.Equal(t, "", v)
+}
+
+func TestShardedMap_Compute(t *testing.T) {
+	sm := NewShardedMap[string, int](4)
+	for i := 0; i < 3; i++ {
+		sm.Compute("a", func(old int, _ bool) (int, bool) { return old + 1, true })
+	}
+	require.Equal(t, 3, sm.Get("a").Value)
+
+	v, ok := sm.Update("a", func(old int) int { return old * 10 })
+	require.True(t, ok)
+	require.Equal(t, 30, v)
+
+	v, computed := sm.GetOrCompute("b", func() int { return 7 })
+	require.True(t, computed)
+	require.Equal(t, 7, v)
+
+	require.True(t, sm.CompareAndSwap("b", 7, 8, func(a, b int) bool { return a == b }))
+	require.Equal(t, 8, sm.Get("b").Value)
+}
+
+func TestShardedMap_Clear(t *testing.T) {
+	sm := NewShardedMap[int, int](4)
+	for i := 0; i < 100; i++ {
+		sm.Set(i, i)
+	}
+	require.Equal(t, 100, sm.Len())
+	sm.Clear()
+	require.True(t, sm.IsEmpty())
+}
+
+func TestShardedMap_KeysValues(t *testing.T) {
+	sm := NewShardedMap[int, string](4)
+	sm.Set(1, "one")
+	sm.Set(2, "two")
+	sm.Set(3, "three")
+	require.ElementsMatch(t, []int{1, 2, 3}, sm.GetKeys())
+	require.ElementsMatch(t, []string{"one", "two", "three"}, sm.GetValues())
+}
+
+func TestShardedMap_Copy(t *testing.T) {
+	sm := NewShardedMap[string, int](4)
+	sm.Set("a", 1)
+	sm.Set("b", 2)
+	cp := sm.Copy()
+	require.Equal(t, sm.Export(), cp.Export())
+
+	cp.Set("c", 3)
+	require.False(t, sm.Get("c").Found)
+	require.True(t, cp.Get("c").Found)
+}
+
+func TestShardedMap_StructKeys(t *testing.T) {
+	type point struct {
+		x, y int
+	}
+	sm := NewShardedMap[point, string](4)
+	sm.Set(point{1, 2}, "a")
+	sm.Set(point{2, 1}, "b")
+	require.Equal(t, "a", sm.Get(point{1, 2}).Value)
+	require.Equal(t, "b", sm.Get(point{2, 1}).Value)
+	require.Equal(t, 2, sm.Len())
+}
+
+func TestShardedMap_PointerKeys(t *testing.T) {
+	type node struct {
+		X int
+	}
+	sm := NewShardedMap[*node, int](16)
+	nodes := make([]*node, 20)
+	for i := range nodes {
+		nodes[i] = &node{X: i}
+		sm.Set(nodes[i], i)
+	}
+	// pointer keys are identified by address, not by what they point to
+	for i, n := range nodes {
+		n.X = -1
+		require.True(t, sm.Get(n).Found)
+		require.Equal(t, i, sm.Get(n).Value)
+	}
+	require.False(t, sm.Get(&node{X: -1}).Found)
+}
+
+func TestShardedMap_FloatZeroKeys(t *testing.T) {
+	negZero := math.Copysign(0, -1)
+	sm := NewShardedMap[float64, string](16)
+	sm.Set(0.0, "zero")
+	require.Equal(t, "zero", sm.Get(negZero).Value)
+
+	sm32 := NewShardedMap[float32, string](16)
+	sm32.Set(float32(negZero), "zero")
+	require.Equal(t, "zero", sm32.Get(0).Value)
+
+	type key struct {
+		f float64
+		c complex128
+	}
+	sk := NewShardedMap[key, string](16)
+	sk.Set(key{0, complex(0, 0)}, "zero")
+	require.Equal(t, "zero", sk.Get(key{negZero, complex(negZero, negZero)}).Value)
+}
+
+func TestShardedMap_InterfaceKeys(t *testing.T) {
+	type id int
+	ch := make(chan int)
+	sm := NewShardedMap[any, string](16)
+	sm.Set(id(1), "id")
+	sm.Set(1, "int")
+	sm.Set(ch, "chan")
+	sm.Set([2]any{"a", 1.5}, "array")
+	sm.Set(nil, "nil")
+
+	require.Equal(t, "id", sm.Get(id(1)).Value)
+	require.Equal(t, "int", sm.Get(1).Value)
+	require.Equal(t, "chan", sm.Get(ch).Value)
+	require.Equal(t, "array", sm.Get([2]any{"a", 1.5}).Value)
+	require.Equal(t, "nil", sm.Get(nil).Value)
+	require.Equal(t, 5, sm.Len())
+}
+
+func TestShardedMap_String(t *testing.T) {
+	sm := NewShardedMap[int, string](4)
+	sm.Set(1, "one")
+	sm.Set(2, "two")
+	require.Equal(t, "map[1:one 2:two]", sm.String())
+}
+
+func TestShardedMap_Concurrent(t *testing.T) {
+	sm := NewShardedMap[int, int](8)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				sm.Set(g*1000+i, i)
+				sm.Get(g*1000 + i)
+				if i%10 == 0 {
+					sm.Export()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	require.Equal(t, 8000, sm.Len())
+}
+
+const benchKeys = 1 << 12
+
+func benchmarkMixed(b *testing.B, get func(string) bool, set func(string, int)) {
+	keys := make([]string, benchKeys)
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+		set(keys[i], i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			k := keys[i%benchKeys]
+			if i%4 == 0 {
+				set(k, i)
+			} else {
+				get(k)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkSafeMap_Mixed(b *testing.B) {
+	sm := NewSafeMap[string, int]()
+	benchmarkMixed(b,
+		func(k string) bool { return sm.Get(k).Found },
+		func(k string, v int) { sm.Set(k, v) },
+	)
+}
+
+func BenchmarkShardedMap_Mixed(b *testing.B) {
+	sm := NewShardedMap[string, int](DefaultShardCount)
+	benchmarkMixed(b,
+		func(k string) bool { return sm.Get(k).Found },
+		func(k string, v int) { sm.Set(k, v) },
+	)
+}
+
+func BenchmarkSafeMap_WriteHeavy(b *testing.B) {
+	sm := NewSafeMap[int, int]()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			sm.Set(i%benchKeys, i)
+			i++
+		}
+	})
+}
+
+func BenchmarkShardedMap_WriteHeavy(b *testing.B) {
+	sm := NewShardedMap[int, int](DefaultShardCount)
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			sm.Set(i%benchKeys, i)
+			i++
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package safemap
+
+import (
+	"fmt"
+	"time"
+)
+
+// Snapshot is an immutable, point-in-time view of a SafeMap.
+// It can be read from many goroutines without locking and never blocks
+// writers to the map it was taken from.
+type Snapshot[K comparable, V any] struct {
+	data    segmentedMap[K, V]
+	at      time.Time
+	version uint64
+}
+
+// Snapshot returns a read-only view of the current contents of the map.
+//
+// Taking a snapshot is O(1): the snapshot shares its data with the map, which
+// is split into segments of about 128 keys. A write after the snapshot copies
+// only the segment it changes and the table of segments (copy-on-write), so it
+// costs O(n/128) rather than O(n), and later writes to a segment that was
+// already copied cost nothing extra.
+// Keys whose TTL has elapsed at the time of the call are not visible.
+func (sm *SafeMap[K, V]) Snapshot() *Snapshot[K, V] {
+	sm.RLock()
+	defer sm.RUnlock()
+	sm.shared.Store(true)
+	return &Snapshot[K, V]{
+		data:    sm.data,
+		at:      sm.now(),
+		version: sm.version,
+	}
+}
+
+// Version returns the current version of the map. The version increases with
+// every mutation, so comparing it with Snapshot.Version tells whether the map
+// changed since the snapshot was taken.
+func (sm *SafeMap[K, V]) Version() uint64 {
+	sm.RLock()
+	defer sm.RUnlock()
+	return sm.version
+}
+
+// Version returns the version of the map at the time the snapshot was taken.
+func (s *Snapshot[K, V]) Version() uint64 {
+	return s.version
+}
+
+// live returns true if the key is not expired at the snapshot time.
+func (s *Snapshot[K, V]) live(k K) bool {
+	deadline, ok := s.data.deadline(k)
+	return !ok || s.at.Before(deadline)
+}
+
+// Get returns the value associated with the key.
+func (s *Snapshot[K, V]) Get(k K) ValueResult[V] {
+	if v, ok := s.data.get(k); ok && s.live(k) {
+		return ValueResult[V]{Value: v, Found: true}
+	}
+	var zero V
+	return ValueResult[V]{Value: zero, Found: false}
+}
+
+// Contains returns true if the key exists.
+func (s *Snapshot[K, V]) Contains(k K) bool {
+	return s.Get(k).Found
+}
+
+// Len returns the number of key-value pairs.
+func (s *Snapshot[K, V]) Len() int {
+	n := s.data.len()
+	for _, deadline := range s.data.deadlines() {
+		if !s.at.Before(deadline) {
+			n--
+		}
+	}
+	return n
+}
+
+// IsEmpty returns true if the snapshot is empty.
+func (s *Snapshot[K, V]) IsEmpty() bool {
+	return s.Len() == 0
+}
+
+// Range calls fn for each key-value pair until fn returns false.
+func (s *Snapshot[K, V]) Range(fn func(k K, v V) bool) {
+	for k, v := range s.data.all() {
+		if !s.live(k) {
+			continue
+		}
+		if !fn(k, v) {
+			return
+		}
+	}
+}
+
+// GetKeys returns the keys of the snapshot as a slice.
+func (s *Snapshot[K, V]) GetKeys() []K {
+	keys := make([]K, 0, s.data.len())
+	s.Range(func(k K, _ V) bool {
+		keys = append(keys, k)
+		return true
+	})
+	return keys
+}
+
+// GetValues returns the values of the snapshot as a slice.
+func (s *Snapshot[K, V]) GetValues() []V {
+	values := make([]V, 0, s.data.len())
+	s.Range(func(_ K, v V) bool {
+		values = append(values, v)
+		return true
+	})
+	return values
+}
+
+// Export returns a new map with the same key-value pairs as the snapshot.
+func (s *Snapshot[K, V]) Export() map[K]V {
+	m := make(map[K]V, s.data.len())
+	s.Range(func(k K, v V) bool {
+		m[k] = v
+		return true
+	})
+	return m
+}
+
+// String returns a string representation of the snapshot.
+func (s *Snapshot[K, V]) String() string {
+	return fmt.Sprintf("%v", s.Export())
+}
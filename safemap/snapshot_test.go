@@ -0,0 +1,133 @@
+package safemap
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSafeMap_Snapshot(t *testing.T) {
+	sm := NewSafeMapFromMap(map[string]int{"a": 1, "b": 2})
+	snap := sm.Snapshot()
+
+	sm.Set("a", 10)
+	sm.Set("c", 3)
+	sm.Delete("b")
+
+	require.Equal(t, 2, snap.Len())
+	require.Equal(t, 1, snap.Get("a").Value)
+	require.True(t, snap.Contains("b"))
+	require.False(t, snap.Get("c").Found)
+	require.Equal(t, map[string]int{"a": 1, "b": 2}, snap.Export())
+	require.ElementsMatch(t, []string{"a", "b"}, snap.GetKeys())
+	require.ElementsMatch(t, []int{1, 2}, snap.GetValues())
+	require.Equal(t, "map[a:1 b:2]", snap.String())
+
+	require.Equal(t, map[string]int{"a": 10, "c": 3}, sm.Export())
+}
+
+func TestSafeMap_SnapshotClear(t *testing.T) {
+	sm := NewSafeMapFromMap(map[string]int{"a": 1})
+	snap := sm.Snapshot()
+	sm.Clear()
+	require.True(t, sm.IsEmpty())
+	require.False(t, snap.IsEmpty())
+}
+
+func TestSafeMap_Version(t *testing.T) {
+	sm := NewSafeMap[string, int]()
+	v0 := sm.Version()
+	snap := sm.Snapshot()
+	require.Equal(t, v0, snap.Version())
+
+	sm.Get("a")
+	sm.Delete("missing")
+	require.Equal(t, v0, sm.Version())
+
+	sm.Set("a", 1)
+	v1 := sm.Version()
+	require.Greater(t, v1, v0)
+	require.NotEqual(t, snap.Version(), sm.Version())
+
+	sm.Update("a", func(old int) int { return old + 1 })
+	require.Greater(t, sm.Version(), v1)
+}
+
+func TestSafeMap_SnapshotTTL(t *testing.T) {
+	clock := newFakeClock()
+	sm := newTTLMap(clock)
+	sm.SetWithTTL("a", 1, time.Second)
+	sm.Set("b", 2)
+
+	before := sm.Snapshot()
+	clock.Advance(time.Second)
+	after := sm.Snapshot()
+
+	// a snapshot evaluates TTLs at the time it was taken
+	require.True(t, before.Get("a").Found)
+	require.Equal(t, 2, before.Len())
+	require.False(t, after.Get("a").Found)
+	require.Equal(t, 1, after.Len())
+	require.Equal(t, map[string]int{"b": 2}, after.Export())
+
+	sm.Sweep()
+	require.True(t, before.Get("a").Found)
+}
+
+func TestSafeMap_SnapshotRangeBreak(t *testing.T) {
+	sm := NewSafeMapFromMap(map[int]int{1: 1, 2: 2, 3: 3})
+	n := 0
+	sm.Snapshot().Range(func(int, int) bool {
+		n++
+		return false
+	})
+	require.Equal(t, 1, n)
+}
+
+func TestSafeMap_GetMapIsPrivate(t *testing.T) {
+	sm := NewSafeMapFromMap(map[string]int{"a": 1})
+	snap := sm.Snapshot()
+	m := sm.GetMap()
+	sm.Set("a", 2)
+	require.Equal(t, 1, m["a"])
+	require.Equal(t, 2, sm.Get("a").Value)
+
+	// writes to the copy reach neither the map nor its snapshots
+	m["a"] = 3
+	m["b"] = 4
+	require.Equal(t, 2, sm.Get("a").Value)
+	require.False(t, sm.Get("b").Found)
+	require.Equal(t, map[string]int{"a": 1}, snap.Export())
+}
+
+func TestSafeMap_SnapshotConcurrent(t *testing.T) {
+	sm := NewSafeMap[int, int]()
+	for i := 0; i < 100; i++ {
+		sm.Set(i, i)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			sm.Set(i%100, i)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			snap := sm.Snapshot()
+			require.Equal(t, 100, snap.Len())
+			n := 0
+			snap.Range(func(_, _ int) bool {
+				n++
+				return true
+			})
+			require.Equal(t, 100, n)
+		}
+	}()
+	wg.Wait()
+}
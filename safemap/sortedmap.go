@@ -0,0 +1,475 @@
+package safemap
+
+import (
+	"cmp"
+	"fmt"
+	"iter"
+	"math/rand/v2"
+	"strings"
+	"sync"
+)
+
+const (
+	// sortedMaxLevel bounds the height of the skip list; with a branching
+	// factor of 4 it comfortably indexes billions of keys.
+	sortedMaxLevel = 24
+	// sortedIterBatch is the number of entries an iterator copies per lock acquisition.
+	sortedIterBatch = 64
+)
+
+// Entry is a key-value pair.
+type Entry[K any, V any] struct {
+	Key   K
+	Value V
+}
+
+// SortedMap is a thread-safe map that keeps its keys in order.
+// It is backed by a skip list, so lookups, insertions and deletions are
+// O(log n) and iteration visits keys in ascending order.
+type SortedMap[K any, V any] struct {
+	mu      sync.RWMutex
+	compare func(a, b K) int
+	head    *sortedNode[K, V] // sentinel, its next pointers start every level
+	tail    *sortedNode[K, V]
+	level   int
+	length  int
+}
+
+// sortedNode is an element of the skip list.
+type sortedNode[K any, V any] struct {
+	key   K
+	value V
+	prev  *sortedNode[K, V] // previous node on level 0, nil for the first node
+	next  []*sortedNode[K, V]
+}
+
+// NewSortedMap creates a new SortedMap ordered by the natural order of the keys.
+func NewSortedMap[K cmp.Ordered, V any]() *SortedMap[K, V] {
+	return NewSortedMapFunc[K, V](cmp.Compare[K])
+}
+
+// NewSortedMapFunc creates a new SortedMap ordered by compare, which returns a
+// negative number if a < b, zero if a == b and a positive number if a > b.
+func NewSortedMapFunc[K any, V any](compare func(a, b K) int) *SortedMap[K, V] {
+	return &SortedMap[K, V]{
+		compare: compare,
+		head:    &sortedNode[K, V]{next: make([]*sortedNode[K, V], sortedMaxLevel)},
+		level:   1,
+	}
+}
+
+// Get returns the value associated with the key.
+func (sm *SortedMap[K, V]) Get(k K) ValueResult[V] {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	if n := sm.find(k); n != nil {
+		return ValueResult[V]{Value: n.value, Found: true}
+	}
+	var zero V
+	return ValueResult[V]{Value: zero, Found: false}
+}
+
+// Contains returns true if the key exists.
+func (sm *SortedMap[K, V]) Contains(k K) bool {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.find(k) != nil
+}
+
+// Set sets the value associated with the key.
+func (sm *SortedMap[K, V]) Set(k K, v V) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	var update [sortedMaxLevel]*sortedNode[K, V]
+	if n := sm.search(k, &update); n != nil && sm.compare(n.key, k) == 0 {
+		n.value = v
+		return
+	}
+	sm.insert(k, v, &update)
+}
+
+// SetNX sets the value associated with the key if the key does not exist.
+func (sm *SortedMap[K, V]) SetNX(k K, v V) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	var update [sortedMaxLevel]*sortedNode[K, V]
+	if n := sm.search(k, &update); n != nil && sm.compare(n.key, k) == 0 {
+		return false
+	}
+	sm.insert(k, v, &update)
+	return true
+}
+
+// Delete deletes the key-value pair associated with the key.
+func (sm *SortedMap[K, V]) Delete(k K) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.delete(k)
+}
+
+// Pop deletes the key-value pair associated with the key and returns the value.
+func (sm *SortedMap[K, V]) Pop(k K) (V, bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if n := sm.delete(k); n != nil {
+		return n.value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// Len returns the number of key-value pairs.
+func (sm *SortedMap[K, V]) Len() int {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.length
+}
+
+// IsEmpty returns true if the map is empty.
+func (sm *SortedMap[K, V]) IsEmpty() bool {
+	return sm.Len() == 0
+}
+
+// Clear deletes all key-value pairs.
+func (sm *SortedMap[K, V]) Clear() {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	clear(sm.head.next)
+	sm.tail = nil
+	sm.level = 1
+	sm.length = 0
+}
+
+// Min returns the smallest key and its value.
+// The boolean is false if the map is empty.
+func (sm *SortedMap[K, V]) Min() (K, V, bool) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return entryOf(sm.head.next[0])
+}
+
+// Max returns the largest key and its value.
+// The boolean is false if the map is empty.
+func (sm *SortedMap[K, V]) Max() (K, V, bool) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return entryOf(sm.tail)
+}
+
+// PopMin deletes the smallest key and returns it with its value.
+// The boolean is false if the map is empty.
+func (sm *SortedMap[K, V]) PopMin() (K, V, bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	n := sm.head.next[0]
+	if n != nil {
+		sm.delete(n.key)
+	}
+	return entryOf(n)
+}
+
+// PopMax deletes the largest key and returns it with its value.
+// The boolean is false if the map is empty.
+func (sm *SortedMap[K, V]) PopMax() (K, V, bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	n := sm.tail
+	if n != nil {
+		sm.delete(n.key)
+	}
+	return entryOf(n)
+}
+
+// Floor returns the largest key less than or equal to k and its value.
+// The boolean is false if there is no such key.
+func (sm *SortedMap[K, V]) Floor(k K) (K, V, bool) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return entryOf(sm.floor(k))
+}
+
+// Ceiling returns the smallest key greater than or equal to k and its value.
+// The boolean is false if there is no such key.
+func (sm *SortedMap[K, V]) Ceiling(k K) (K, V, bool) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return entryOf(sm.search(k, nil))
+}
+
+// All returns an iterator over the key-value pairs of the map in ascending key order.
+//
+// Iteration copies a small batch of entries at a time, so it is weakly
+// consistent: every key is visited at most once and in order, and changes
+// made during iteration may or may not be observed.
+func (sm *SortedMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		sm.ascend(nil, nil, yield)
+	}
+}
+
+// Backward returns an iterator over the key-value pairs of the map in
+// descending key order. See All for its semantics.
+func (sm *SortedMap[K, V]) Backward() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		sm.descend(yield)
+	}
+}
+
+// Range returns an iterator over the key-value pairs with keys in the
+// half-open interval [from, to), in ascending key order. See All for its semantics.
+func (sm *SortedMap[K, V]) Range(from, to K) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		sm.ascend(&from, &to, yield)
+	}
+}
+
+// Keys returns an iterator over the keys of the map in ascending order.
+// See All for its semantics.
+func (sm *SortedMap[K, V]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		sm.ascend(nil, nil, func(k K, _ V) bool {
+			return yield(k)
+		})
+	}
+}
+
+// Values returns an iterator over the values of the map in ascending key order.
+// See All for its semantics.
+func (sm *SortedMap[K, V]) Values() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		sm.ascend(nil, nil, func(_ K, v V) bool {
+			return yield(v)
+		})
+	}
+}
+
+// GetKeys returns the keys of the map as a slice, in ascending order.
+func (sm *SortedMap[K, V]) GetKeys() []K {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	keys := make([]K, 0, sm.length)
+	for n := sm.head.next[0]; n != nil; n = n.next[0] {
+		keys = append(keys, n.key)
+	}
+	return keys
+}
+
+// GetValues returns the values of the map as a slice, in ascending key order.
+func (sm *SortedMap[K, V]) GetValues() []V {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	values := make([]V, 0, sm.length)
+	for n := sm.head.next[0]; n != nil; n = n.next[0] {
+		values = append(values, n.value)
+	}
+	return values
+}
+
+// Export returns the key-value pairs of the map as a slice, in ascending key order.
+func (sm *SortedMap[K, V]) Export() []Entry[K, V] {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	entries := make([]Entry[K, V], 0, sm.length)
+	for n := sm.head.next[0]; n != nil; n = n.next[0] {
+		entries = append(entries, Entry[K, V]{Key: n.key, Value: n.value})
+	}
+	return entries
+}
+
+// String returns a string representation of the SortedMap.
+func (sm *SortedMap[K, V]) String() string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	var b strings.Builder
+	b.WriteString("map[")
+	for n := sm.head.next[0]; n != nil; n = n.next[0] {
+		if n != sm.head.next[0] {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%v:%v", n.key, n.value)
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// search returns the first node with a key greater than or equal to k, or nil.
+// If update is not nil, update[i] is set to the last node before k on level i.
+// The caller must hold the lock.
+func (sm *SortedMap[K, V]) search(k K, update *[sortedMaxLevel]*sortedNode[K, V]) *sortedNode[K, V] {
+	x := sm.head
+	for i := sm.level - 1; i >= 0; i-- {
+		for x.next[i] != nil && sm.compare(x.next[i].key, k) < 0 {
+			x = x.next[i]
+		}
+		if update != nil {
+			update[i] = x
+		}
+	}
+	return x.next[0]
+}
+
+// find returns the node with the key k, or nil. The caller must hold the lock.
+func (sm *SortedMap[K, V]) find(k K) *sortedNode[K, V] {
+	if n := sm.search(k, nil); n != nil && sm.compare(n.key, k) == 0 {
+		return n
+	}
+	return nil
+}
+
+// floor returns the last node with a key less than or equal to k, or nil.
+// The caller must hold the lock.
+func (sm *SortedMap[K, V]) floor(k K) *sortedNode[K, V] {
+	n := sm.search(k, nil)
+	if n != nil && sm.compare(n.key, k) == 0 {
+		return n
+	}
+	if n == nil {
+		return sm.tail
+	}
+	return n.prev
+}
+
+// insert links a new node for k after the nodes in update, which must have
+// been filled in by search. The caller must hold the write lock.
+func (sm *SortedMap[K, V]) insert(k K, v V, update *[sortedMaxLevel]*sortedNode[K, V]) {
+	level := randomLevel()
+	for i := sm.level; i < level; i++ {
+		update[i] = sm.head
+	}
+	sm.level = max(sm.level, level)
+
+	n := &sortedNode[K, V]{key: k, value: v, next: make([]*sortedNode[K, V], level)}
+	for i := 0; i < level; i++ {
+		n.next[i] = update[i].next[i]
+		update[i].next[i] = n
+	}
+	if update[0] != sm.head {
+		n.prev = update[0]
+	}
+	if n.next[0] != nil {
+		n.next[0].prev = n
+	} else {
+		sm.tail = n
+	}
+	sm.length++
+}
+
+// delete unlinks the node with the key k and returns it, or nil if the key
+// does not exist. The caller must hold the write lock.
+func (sm *SortedMap[K, V]) delete(k K) *sortedNode[K, V] {
+	var update [sortedMaxLevel]*sortedNode[K, V]
+	n := sm.search(k, &update)
+	if n == nil || sm.compare(n.key, k) != 0 {
+		return nil
+	}
+	for i := range n.next {
+		update[i].next[i] = n.next[i]
+	}
+	if n.next[0] != nil {
+		n.next[0].prev = n.prev
+	} else {
+		sm.tail = n.prev
+	}
+	for sm.level > 1 && sm.head.next[sm.level-1] == nil {
+		sm.level--
+	}
+	sm.length--
+	return n
+}
+
+// ascend yields the entries with keys in [from, to) in ascending order, one
+// batch per read lock. A nil bound is unbounded.
+func (sm *SortedMap[K, V]) ascend(from, to *K, yield func(K, V) bool) {
+	batch := make([]Entry[K, V], 0, sortedIterBatch)
+	var last K
+	for started := false; ; started = true {
+		batch = batch[:0]
+		done := false
+		sm.mu.RLock()
+		var n *sortedNode[K, V]
+		switch {
+		case started:
+			if n = sm.search(last, nil); n != nil && sm.compare(n.key, last) == 0 {
+				n = n.next[0]
+			}
+		case from != nil:
+			n = sm.search(*from, nil)
+		default:
+			n = sm.head.next[0]
+		}
+		for ; len(batch) < sortedIterBatch; n = n.next[0] {
+			if n == nil || (to != nil && sm.compare(n.key, *to) >= 0) {
+				done = true
+				break
+			}
+			batch = append(batch, Entry[K, V]{Key: n.key, Value: n.value})
+		}
+		sm.mu.RUnlock()
+
+		for _, e := range batch {
+			if !yield(e.Key, e.Value) {
+				return
+			}
+		}
+		if done {
+			return
+		}
+		last = batch[len(batch)-1].Key
+	}
+}
+
+// descend yields all entries in descending order, one batch per read lock.
+func (sm *SortedMap[K, V]) descend(yield func(K, V) bool) {
+	batch := make([]Entry[K, V], 0, sortedIterBatch)
+	var last K
+	for started := false; ; started = true {
+		batch = batch[:0]
+		done := false
+		sm.mu.RLock()
+		n := sm.tail
+		if started {
+			if n = sm.search(last, nil); n == nil {
+				n = sm.tail
+			} else {
+				n = n.prev
+			}
+		}
+		for ; len(batch) < sortedIterBatch; n = n.prev {
+			if n == nil {
+				done = true
+				break
+			}
+			batch = append(batch, Entry[K, V]{Key: n.key, Value: n.value})
+		}
+		sm.mu.RUnlock()
+
+		for _, e := range batch {
+			if !yield(e.Key, e.Value) {
+				return
+			}
+		}
+		if done {
+			return
+		}
+		last = batch[len(batch)-1].Key
+	}
+}
+
+// entryOf returns the key and value of n, or zero values and false if n is nil.
+func entryOf[K any, V any](n *sortedNode[K, V]) (K, V, bool) {
+	if n == nil {
+		var k K
+		var v V
+		return k, v, false
+	}
+	return n.key, n.value, true
+}
+
+// randomLevel returns the height of a new skip list node.
+func randomLevel() int {
+	level := 1
+	for level < sortedMaxLevel && rand.IntN(4) == 0 {
+		level++
+	}
+	return level
+}
@@ -0,0 +1,203 @@
+package safemap
+
+import (
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortedMap_GetSetDelete(t *testing.T) {
+	sm := NewSortedMap[string, int]()
+	require.True(t, sm.IsEmpty())
+	require.False(t, sm.Get("a").Found)
+
+	sm.Set("b", 2)
+	sm.Set("a", 1)
+	sm.Set("c", 3)
+	sm.Set("b", 20)
+	require.Equal(t, 3, sm.Len())
+	require.Equal(t, ValueResult[int]{Value: 20, Found: true}, sm.Get("b"))
+	require.True(t, sm.Contains("c"))
+
+	require.False(t, sm.SetNX("a", 100))
+	require.True(t, sm.SetNX("d", 4))
+	require.Equal(t, []string{"a", "b", "c", "d"}, sm.GetKeys())
+	require.Equal(t, []int{1, 20, 3, 4}, sm.GetValues())
+
+	sm.Delete("b")
+	sm.Delete("missing")
+	v, ok := sm.Pop("c")
+	require.True(t, ok)
+	require.Equal(t, 3, v)
+	_, ok = sm.Pop("c")
+	require.False(t, ok)
+	require.Equal(t, []Entry[string, int]{{"a", 1}, {"d", 4}}, sm.Export())
+	require.Equal(t, "map[a:1 d:4]", sm.String())
+
+	sm.Clear()
+	require.True(t, sm.IsEmpty())
+	_, _, ok = sm.Max()
+	require.False(t, ok)
+	sm.Set("z", 26)
+	require.Equal(t, []string{"z"}, sm.GetKeys())
+}
+
+func TestSortedMap_MinMaxPop(t *testing.T) {
+	sm := NewSortedMap[int, string]()
+	_, _, ok := sm.Min()
+	require.False(t, ok)
+	_, _, ok = sm.PopMin()
+	require.False(t, ok)
+
+	for _, k := range []int{5, 1, 9, 3} {
+		sm.Set(k, strings.Repeat("x", k))
+	}
+	k, v, ok := sm.Min()
+	require.True(t, ok)
+	require.Equal(t, 1, k)
+	require.Equal(t, "x", v)
+	k, _, _ = sm.Max()
+	require.Equal(t, 9, k)
+
+	k, _, _ = sm.PopMin()
+	require.Equal(t, 1, k)
+	k, _, _ = sm.PopMax()
+	require.Equal(t, 9, k)
+	require.Equal(t, []int{3, 5}, sm.GetKeys())
+}
+
+func TestSortedMap_FloorCeiling(t *testing.T) {
+	sm := NewSortedMap[int, int]()
+	for _, k := range []int{10, 20, 30} {
+		sm.Set(k, k*10)
+	}
+	tests := []struct {
+		k                    int
+		floor, ceiling       int
+		hasFloor, hasCeiling bool
+	}{
+		{5, 0, 10, false, true},
+		{10, 10, 10, true, true},
+		{15, 10, 20, true, true},
+		{30, 30, 30, true, true},
+		{35, 30, 0, true, false},
+	}
+	for _, tt := range tests {
+		k, v, ok := sm.Floor(tt.k)
+		require.Equal(t, tt.hasFloor, ok, "floor of %d", tt.k)
+		if ok {
+			require.Equal(t, tt.floor, k)
+			require.Equal(t, tt.floor*10, v)
+		}
+		k, _, ok = sm.Ceiling(tt.k)
+		require.Equal(t, tt.hasCeiling, ok, "ceiling of %d", tt.k)
+		if ok {
+			require.Equal(t, tt.ceiling, k)
+		}
+	}
+}
+
+func TestSortedMap_Iterators(t *testing.T) {
+	sm := NewSortedMap[int, int]()
+	for _, k := range rand.Perm(500) {
+		sm.Set(k, -k)
+	}
+
+	var keys []int
+	for k, v := range sm.All() {
+		require.Equal(t, -k, v)
+		keys = append(keys, k)
+	}
+	require.Len(t, keys, 500)
+	require.True(t, slices.IsSorted(keys))
+
+	var backward []int
+	for k := range sm.Backward() {
+		backward = append(backward, k)
+	}
+	slices.Reverse(backward)
+	require.Equal(t, keys, backward)
+
+	var ranged []int
+	for k := range sm.Range(100, 250) {
+		ranged = append(ranged, k)
+	}
+	require.Equal(t, keys[100:250], ranged)
+
+	require.Equal(t, keys, slices.Collect(sm.Keys()))
+	values := slices.Collect(sm.Values())
+	require.Equal(t, 0, values[0])
+	require.Equal(t, -499, values[499])
+
+	for k := range sm.All() {
+		if k == 10 {
+			break
+		}
+	}
+}
+
+func TestSortedMap_IterateAndModify(t *testing.T) {
+	sm := NewSortedMap[int, int]()
+	for i := 0; i < 200; i++ {
+		sm.Set(i, i)
+	}
+	// modifying during iteration does not deadlock and each key is seen once
+	var seen []int
+	for k := range sm.All() {
+		seen = append(seen, k)
+		sm.Delete(k)
+		sm.Set(-1, -1)
+	}
+	require.Len(t, seen, 200)
+	require.True(t, slices.IsSorted(seen))
+	require.Equal(t, []int{-1}, sm.GetKeys())
+}
+
+func TestSortedMap_Func(t *testing.T) {
+	type timestamp struct {
+		sec, nsec int
+	}
+	sm := NewSortedMapFunc[timestamp, string](func(a, b timestamp) int {
+		if a.sec != b.sec {
+			return a.sec - b.sec
+		}
+		return a.nsec - b.nsec
+	})
+	sm.Set(timestamp{2, 0}, "c")
+	sm.Set(timestamp{1, 5}, "b")
+	sm.Set(timestamp{1, 0}, "a")
+
+	var events []string
+	for _, v := range sm.Range(timestamp{1, 0}, timestamp{2, 0}) {
+		events = append(events, v)
+	}
+	require.Equal(t, []string{"a", "b"}, events)
+}
+
+func TestSortedMap_Concurrent(t *testing.T) {
+	sm := NewSortedMap[int, int]()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				k := g*200 + i
+				sm.Set(k, k)
+				if i%2 == 0 {
+					sm.Delete(k)
+				}
+				sm.Floor(k)
+				for range sm.Range(k-10, k) {
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	require.Equal(t, 800, sm.Len())
+	require.True(t, slices.IsSorted(sm.GetKeys()))
+}
@@ -0,0 +1,181 @@
+package safemap
+
+import (
+	"fmt"
+	"time"
+)
+
+// sweeper is a background goroutine that periodically removes expired keys.
+type sweeper struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// SetClock replaces the function used to read the current time when
+// evaluating TTLs. It is intended for tests; a nil clock restores time.Now.
+func (sm *SafeMap[K, V]) SetClock(clock func() time.Time) {
+	sm.Lock()
+	defer sm.Unlock()
+	sm.clock = clock
+}
+
+// now returns the current time according to the map's clock.
+func (sm *SafeMap[K, V]) now() time.Time {
+	if sm.clock != nil {
+		return sm.clock()
+	}
+	return time.Now()
+}
+
+// expired returns true if the key has a TTL that has elapsed at now.
+func (sm *SafeMap[K, V]) expired(k K, now time.Time) bool {
+	deadline, ok := sm.data.deadline(k)
+	return ok && !now.Before(deadline)
+}
+
+// expiredNow returns true if the key has a TTL that has already elapsed.
+// The clock is only read when the key has a TTL.
+func (sm *SafeMap[K, V]) expiredNow(k K) bool {
+	deadline, ok := sm.data.deadline(k)
+	return ok && !sm.now().Before(deadline)
+}
+
+// dropExpired removes the key if its TTL has elapsed.
+// The caller must hold the write lock.
+func (sm *SafeMap[K, V]) dropExpired(k K) {
+	if sm.expiredNow(k) {
+		sm.removeExpired(k)
+	}
+}
+
+// removeExpired removes an expired key and publishes an OpExpire event.
+// The caller must hold the write lock.
+func (sm *SafeMap[K, V]) removeExpired(k K) {
+	old, _ := sm.data.get(k)
+	sm.remove(k)
+	sm.publishRemove(OpExpire, k, old)
+}
+
+// SetWithTTL sets the value associated with the key and makes the key expire
+// after ttl. If ttl is not positive, the key does not expire.
+func (sm *SafeMap[K, V]) SetWithTTL(k K, v V, ttl time.Duration) {
+	sm.Lock()
+	defer sm.Unlock()
+	sm.dropExpired(k)
+	old, existed := sm.data.get(k)
+	sm.store(k, v)
+	sm.setExpiry(k, ttl)
+	sm.publishSet(k, old, existed, v)
+}
+
+// Expire sets the TTL of an existing key. If ttl is not positive, the TTL of
+// the key is removed and the key no longer expires.
+// It returns false if the key does not exist.
+func (sm *SafeMap[K, V]) Expire(k K, ttl time.Duration) bool {
+	sm.Lock()
+	defer sm.Unlock()
+	sm.dropExpired(k)
+	if _, ok := sm.data.get(k); !ok {
+		return false
+	}
+	sm.beginWrite()
+	sm.setExpiry(k, ttl)
+	return true
+}
+
+// TTL returns the remaining time to live of the key.
+// The boolean result is false if the key does not exist or has no TTL.
+func (sm *SafeMap[K, V]) TTL(k K) (time.Duration, bool) {
+	sm.RLock()
+	defer sm.RUnlock()
+	deadline, ok := sm.data.deadline(k)
+	if !ok {
+		return 0, false
+	}
+	remaining := deadline.Sub(sm.now())
+	if remaining <= 0 {
+		return 0, false
+	}
+	return remaining, true
+}
+
+// setExpiry sets or removes the deadline of the key.
+// The caller must hold the write lock and have called beginWrite.
+func (sm *SafeMap[K, V]) setExpiry(k K, ttl time.Duration) {
+	if ttl <= 0 {
+		sm.data.clearDeadline(k)
+		return
+	}
+	sm.data.setDeadline(k, sm.now().Add(ttl))
+}
+
+// Sweep removes all expired keys and returns the number of keys removed.
+// Expired keys are never visible to readers, so calling Sweep is only needed
+// to reclaim memory; StartSweeper calls it periodically.
+func (sm *SafeMap[K, V]) Sweep() int {
+	sm.Lock()
+	defer sm.Unlock()
+	now := sm.now()
+	n := 0
+	for k, deadline := range sm.data.deadlines() {
+		if !now.Before(deadline) {
+			sm.removeExpired(k)
+			n++
+		}
+	}
+	return n
+}
+
+// StartSweeper starts a background goroutine that calls Sweep every interval.
+// A running sweeper is stopped and replaced. Call Close to stop it.
+// It panics if interval is not positive.
+func (sm *SafeMap[K, V]) StartSweeper(interval time.Duration) {
+	if interval <= 0 {
+		panic(fmt.Sprintf("safemap: StartSweeper interval must be positive, got %v", interval))
+	}
+	sw := &sweeper{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	sm.Lock()
+	old := sm.sweeper
+	sm.sweeper = sw
+	sm.Unlock()
+	old.shutdown()
+
+	go func() {
+		defer close(sw.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-sw.stop:
+				return
+			case <-ticker.C:
+				sm.Sweep()
+			}
+		}
+	}()
+}
+
+// Close stops the background sweeper, if any, and waits for it to exit.
+// The map remains usable after Close. It always returns nil.
+func (sm *SafeMap[K, V]) Close() error {
+	sm.Lock()
+	sw := sm.sweeper
+	sm.sweeper = nil
+	sm.Unlock()
+
+	sw.shutdown()
+	return nil
+}
+
+// shutdown stops the sweeper goroutine and waits for it to exit.
+// It is a no-op on a nil sweeper.
+func (sw *sweeper) shutdown() {
+	if sw == nil {
+		return
+	}
+	close(sw.stop)
+	<-sw.done
+}
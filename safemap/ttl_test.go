@@ -0,0 +1,201 @@
+package safemap
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a manually advanced clock for deterministic TTL tests.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTTLMap(clock *fakeClock) *SafeMap[string, int] {
+	sm := NewSafeMap[string, int]()
+	sm.SetClock(clock.Now)
+	return sm
+}
+
+func TestSafeMap_SetWithTTL(t *testing.T) {
+	clock := newFakeClock()
+	sm := newTTLMap(clock)
+	sm.SetWithTTL("a", 1, time.Second)
+	sm.Set("b", 2)
+
+	require.True(t, sm.Get("a").Found)
+	require.Equal(t, 2, sm.Len())
+
+	clock.Advance(time.Second)
+	result := sm.Get("a")
+	require.False(t, result.Found)
+	require.Equal(t, 0, result.Value)
+	require.True(t, sm.Get("b").Found)
+	require.Equal(t, 1, sm.Len())
+	require.Equal(t, []string{"b"}, sm.GetKeys())
+	require.Equal(t, []int{2}, sm.GetValues())
+	require.Equal(t, map[string]int{"b": 2}, sm.Export())
+	require.Equal(t, "map[b:2]", sm.String())
+}
+
+func TestSafeMap_SetWithTTLNonPositive(t *testing.T) {
+	clock := newFakeClock()
+	sm := newTTLMap(clock)
+	sm.SetWithTTL("a", 1, 0)
+	clock.Advance(time.Hour)
+	require.True(t, sm.Get("a").Found)
+	_, ok := sm.TTL("a")
+	require.False(t, ok)
+}
+
+func TestSafeMap_SetClearsTTL(t *testing.T) {
+	clock := newFakeClock()
+	sm := newTTLMap(clock)
+	sm.SetWithTTL("a", 1, time.Second)
+	sm.Set("a", 2)
+	clock.Advance(time.Minute)
+	require.Equal(t, 2, sm.Get("a").Value)
+}
+
+func TestSafeMap_Expire(t *testing.T) {
+	clock := newFakeClock()
+	sm := newTTLMap(clock)
+	require.False(t, sm.Expire("a", time.Second))
+
+	sm.Set("a", 1)
+	require.True(t, sm.Expire("a", time.Second))
+	ttl, ok := sm.TTL("a")
+	require.True(t, ok)
+	require.Equal(t, time.Second, ttl)
+
+	require.True(t, sm.Expire("a", 0))
+	_, ok = sm.TTL("a")
+	require.False(t, ok)
+	clock.Advance(time.Minute)
+	require.True(t, sm.Get("a").Found)
+}
+
+func TestSafeMap_TTL(t *testing.T) {
+	clock := newFakeClock()
+	sm := newTTLMap(clock)
+	_, ok := sm.TTL("missing")
+	require.False(t, ok)
+
+	sm.SetWithTTL("a", 1, 10*time.Second)
+	clock.Advance(4 * time.Second)
+	ttl, ok := sm.TTL("a")
+	require.True(t, ok)
+	require.Equal(t, 6*time.Second, ttl)
+
+	clock.Advance(6 * time.Second)
+	_, ok = sm.TTL("a")
+	require.False(t, ok)
+}
+
+func TestSafeMap_ExpiredKeyIsAbsentForWrites(t *testing.T) {
+	clock := newFakeClock()
+	sm := newTTLMap(clock)
+	sm.SetWithTTL("a", 1, time.Second)
+	sm.SetWithTTL("b", 1, time.Second)
+	sm.SetWithTTL("c", 1, time.Second)
+	clock.Advance(time.Second)
+
+	require.True(t, sm.SetNX("a", 2))
+	require.Equal(t, 2, sm.Get("a").Value)
+	_, ok := sm.TTL("a")
+	require.False(t, ok)
+
+	_, ok = sm.Pop("b")
+	require.False(t, ok)
+
+	sm.Compute("c", func(old int, found bool) (int, bool) {
+		require.False(t, found)
+		return old + 10, true
+	})
+	require.Equal(t, 10, sm.Get("c").Value)
+}
+
+func TestSafeMap_ComputePreservesTTL(t *testing.T) {
+	clock := newFakeClock()
+	sm := newTTLMap(clock)
+	sm.SetWithTTL("a", 1, time.Second)
+	sm.Update("a", func(old int) int { return old + 1 })
+	clock.Advance(time.Second)
+	require.False(t, sm.Get("a").Found)
+}
+
+func TestSafeMap_CopyKeepsTTL(t *testing.T) {
+	clock := newFakeClock()
+	sm := newTTLMap(clock)
+	sm.SetWithTTL("a", 1, time.Second)
+	sm.Set("b", 2)
+	cp := sm.Copy()
+	require.Equal(t, 2, cp.Len())
+	clock.Advance(time.Second)
+	require.Equal(t, map[string]int{"b": 2}, cp.Export())
+}
+
+func TestSafeMap_Sweep(t *testing.T) {
+	clock := newFakeClock()
+	sm := newTTLMap(clock)
+	sm.SetWithTTL("a", 1, time.Second)
+	sm.SetWithTTL("b", 2, time.Minute)
+	sm.Set("c", 3)
+
+	require.Equal(t, 0, sm.Sweep())
+	clock.Advance(time.Second)
+	require.Equal(t, 1, sm.Sweep())
+	require.Len(t, sm.GetMap(), 2)
+	require.Equal(t, 1, sm.data.expiring())
+}
+
+func TestSafeMap_StartSweeper(t *testing.T) {
+	clock := newFakeClock()
+	sm := newTTLMap(clock)
+	sm.SetWithTTL("a", 1, time.Second)
+	clock.Advance(time.Second)
+
+	sm.StartSweeper(time.Millisecond)
+	defer sm.Close()
+	require.Eventually(t, func() bool {
+		sm.RLock()
+		defer sm.RUnlock()
+		return sm.data.len() == 0
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, sm.Close())
+	require.NoError(t, sm.Close())
+}
+
+func TestSafeMap_StartSweeperInvalidInterval(t *testing.T) {
+	sm := NewSafeMap[string, int]()
+	require.PanicsWithValue(t, "safemap: StartSweeper interval must be positive, got 0s", func() {
+		sm.StartSweeper(0)
+	})
+	require.Panics(t, func() { sm.StartSweeper(-time.Second) })
+
+	// no sweeper was started
+	sm.RLock()
+	require.Nil(t, sm.sweeper)
+	sm.RUnlock()
+	require.NoError(t, sm.Close())
+}
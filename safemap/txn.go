@@ -0,0 +1,186 @@
+package safemap
+
+import (
+	"cmp"
+	"slices"
+	"sync/atomic"
+)
+
+// lastMapID is the last identity handed out to a SafeMap for lock ordering.
+var lastMapID atomic.Uint64
+
+// MapTx is a transactional view of a SafeMap, valid only inside the callback
+// passed to Txn or MultiTxn. Reads see the writes made earlier in the same
+// transaction; writes are applied to the map only if the callback succeeds.
+type MapTx[K comparable, V any] struct {
+	sm      *SafeMap[K, V]
+	writes  map[K]V
+	deletes map[K]struct{}
+	order   []K // keys in the order they were first written
+	done    bool
+}
+
+// Txn runs fn with exclusive access to the map. All writes made through tx
+// are applied atomically when fn returns nil; they are discarded if fn returns
+// an error or panics. The error returned by fn is returned by Txn.
+//
+// fn must not call methods on the map itself, only on tx, or it deadlocks.
+func (sm *SafeMap[K, V]) Txn(fn func(tx *MapTx[K, V]) error) error {
+	sm.Lock()
+	defer sm.Unlock()
+	tx := newMapTx(sm)
+	defer tx.finish()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	tx.apply()
+	return nil
+}
+
+// MultiTxn runs fn with exclusive access to all of the given maps.
+// txs[i] is the transaction for maps[i]; a map passed more than once gets the
+// same transaction. The maps are locked in a deterministic global order, so
+// concurrent MultiTxn calls over overlapping maps cannot deadlock.
+// Writes are applied to every map when fn returns nil and discarded otherwise.
+func MultiTxn[K comparable, V any](maps []*SafeMap[K, V], fn func(txs []*MapTx[K, V]) error) error {
+	unique := make([]*SafeMap[K, V], 0, len(maps))
+	for _, sm := range maps {
+		if !slices.Contains(unique, sm) {
+			unique = append(unique, sm)
+		}
+	}
+	slices.SortFunc(unique, func(a, b *SafeMap[K, V]) int {
+		return cmp.Compare(a.identity(), b.identity())
+	})
+
+	byMap := make(map[*SafeMap[K, V]]*MapTx[K, V], len(unique))
+	for _, sm := range unique {
+		sm.Lock()
+		defer sm.Unlock()
+		tx := newMapTx(sm)
+		defer tx.finish()
+		byMap[sm] = tx
+	}
+
+	txs := make([]*MapTx[K, V], len(maps))
+	for i, sm := range maps {
+		txs[i] = byMap[sm]
+	}
+	if err := fn(txs); err != nil {
+		return err
+	}
+	for _, sm := range unique {
+		byMap[sm].apply()
+	}
+	return nil
+}
+
+// identity returns a process-unique, stable number for the map, used to order
+// lock acquisition across maps.
+func (sm *SafeMap[K, V]) identity() uint64 {
+	if id := sm.id.Load(); id != 0 {
+		return id
+	}
+	sm.id.CompareAndSwap(0, lastMapID.Add(1))
+	return sm.id.Load()
+}
+
+// newMapTx creates a transaction on sm. The caller must hold the write lock.
+func newMapTx[K comparable, V any](sm *SafeMap[K, V]) *MapTx[K, V] {
+	return &MapTx[K, V]{
+		sm:      sm,
+		writes:  make(map[K]V),
+		deletes: make(map[K]struct{}),
+	}
+}
+
+// check panics if the transaction is used after it has finished.
+func (tx *MapTx[K, V]) check() {
+	if tx.done {
+		panic("safemap: MapTx used after its transaction finished")
+	}
+}
+
+// touch records the first write of the key.
+func (tx *MapTx[K, V]) touch(k K) {
+	if _, ok := tx.writes[k]; ok {
+		return
+	}
+	if _, ok := tx.deletes[k]; ok {
+		return
+	}
+	tx.order = append(tx.order, k)
+}
+
+// Get returns the value associated with the key as seen by the transaction.
+func (tx *MapTx[K, V]) Get(k K) ValueResult[V] {
+	tx.check()
+	if v, ok := tx.writes[k]; ok {
+		return ValueResult[V]{Value: v, Found: true}
+	}
+	var zero V
+	if _, ok := tx.deletes[k]; ok {
+		return ValueResult[V]{Value: zero, Found: false}
+	}
+	if v, ok := tx.sm.data.get(k); ok && !tx.sm.expiredNow(k) {
+		return ValueResult[V]{Value: v, Found: true}
+	}
+	return ValueResult[V]{Value: zero, Found: false}
+}
+
+// Set sets the value associated with the key.
+func (tx *MapTx[K, V]) Set(k K, v V) {
+	tx.check()
+	tx.touch(k)
+	delete(tx.deletes, k)
+	tx.writes[k] = v
+}
+
+// SetNX sets the value associated with the key if the key does not exist.
+func (tx *MapTx[K, V]) SetNX(k K, v V) bool {
+	if tx.Get(k).Found {
+		return false
+	}
+	tx.Set(k, v)
+	return true
+}
+
+// Delete deletes the key-value pair associated with the key.
+func (tx *MapTx[K, V]) Delete(k K) {
+	tx.check()
+	tx.touch(k)
+	delete(tx.writes, k)
+	tx.deletes[k] = struct{}{}
+}
+
+// Pop deletes the key-value pair associated with the key and returns the value.
+func (tx *MapTx[K, V]) Pop(k K) (V, bool) {
+	result := tx.Get(k)
+	if result.Found {
+		tx.Delete(k)
+	}
+	return result.Value, result.Found
+}
+
+// apply writes the buffered changes to the map in the order the keys were
+// first written. The caller must hold the write lock.
+func (tx *MapTx[K, V]) apply() {
+	sm := tx.sm
+	for _, k := range tx.order {
+		sm.dropExpired(k)
+		old, existed := sm.data.get(k)
+		if v, ok := tx.writes[k]; ok {
+			sm.store(k, v)
+			sm.data.clearDeadline(k)
+			sm.publishSet(k, old, existed, v)
+		} else if existed {
+			sm.remove(k)
+			sm.publishRemove(OpDelete, k, old)
+		}
+	}
+}
+
+// finish invalidates the transaction.
+func (tx *MapTx[K, V]) finish() {
+	tx.done = true
+}
@@ -0,0 +1,162 @@
+package safemap
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSafeMap_Txn(t *testing.T) {
+	sm := NewSafeMapFromMap(map[string]int{"a": 10, "b": 0})
+	err := sm.Txn(func(tx *MapTx[string, int]) error {
+		a := tx.Get("a")
+		require.True(t, a.Found)
+		tx.Set("a", a.Value-5)
+		tx.Set("b", tx.Get("b").Value+5)
+		require.Equal(t, 5, tx.Get("a").Value)
+
+		require.False(t, tx.SetNX("a", 100))
+		require.True(t, tx.SetNX("c", 1))
+		tx.Delete("c")
+		require.False(t, tx.Get("c").Found)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"a": 5, "b": 5}, sm.Export())
+}
+
+func TestSafeMap_TxnPop(t *testing.T) {
+	sm := NewSafeMapFromMap(map[string]int{"a": 1})
+	err := sm.Txn(func(tx *MapTx[string, int]) error {
+		v, ok := tx.Pop("a")
+		require.True(t, ok)
+		require.Equal(t, 1, v)
+		_, ok = tx.Pop("a")
+		require.False(t, ok)
+		tx.Set("b", v)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"b": 1}, sm.Export())
+}
+
+func TestSafeMap_TxnError(t *testing.T) {
+	sm := NewSafeMapFromMap(map[string]int{"a": 1})
+	version := sm.Version()
+	errAbort := errors.New("abort")
+	err := sm.Txn(func(tx *MapTx[string, int]) error {
+		tx.Set("a", 2)
+		tx.Set("b", 3)
+		return errAbort
+	})
+	require.ErrorIs(t, err, errAbort)
+	require.Equal(t, map[string]int{"a": 1}, sm.Export())
+	require.Equal(t, version, sm.Version())
+}
+
+func TestSafeMap_TxnPanic(t *testing.T) {
+	sm := NewSafeMapFromMap(map[string]int{"a": 1})
+	require.Panics(t, func() {
+		_ = sm.Txn(func(tx *MapTx[string, int]) error {
+			tx.Delete("a")
+			panic("boom")
+		})
+	})
+	// the lock was released and the writes discarded
+	require.Equal(t, map[string]int{"a": 1}, sm.Export())
+}
+
+func TestSafeMap_TxnUseAfterFinish(t *testing.T) {
+	sm := NewSafeMap[string, int]()
+	var leaked *MapTx[string, int]
+	require.NoError(t, sm.Txn(func(tx *MapTx[string, int]) error {
+		leaked = tx
+		return nil
+	}))
+	require.Panics(t, func() { leaked.Set("a", 1) })
+}
+
+func TestSafeMap_TxnEvents(t *testing.T) {
+	sm := NewSafeMapFromMap(map[string]int{"a": 1})
+	sub := sm.Subscribe(SubscribeOptions[string]{})
+	defer sub.Close()
+
+	require.NoError(t, sm.Txn(func(tx *MapTx[string, int]) error {
+		tx.Set("b", 2)
+		tx.Delete("a")
+		tx.Set("b", 3)
+		return nil
+	}))
+	require.Equal(t, Event[string, int]{Op: OpSet, Key: "b", NewValue: 3}, receive(t, sub.Events()))
+	require.Equal(t, Event[string, int]{Op: OpDelete, Key: "a", OldValue: 1, Existed: true}, receive(t, sub.Events()))
+	requireNoEvent(t, sub.Events())
+}
+
+func TestMultiTxn(t *testing.T) {
+	from := NewSafeMapFromMap(map[string]int{"x": 1})
+	to := NewSafeMap[string, int]()
+	err := MultiTxn([]*SafeMap[string, int]{from, to}, func(txs []*MapTx[string, int]) error {
+		v, ok := txs[0].Pop("x")
+		require.True(t, ok)
+		txs[1].Set("x", v)
+		return nil
+	})
+	require.NoError(t, err)
+	require.True(t, from.IsEmpty())
+	require.Equal(t, map[string]int{"x": 1}, to.Export())
+
+	err = MultiTxn([]*SafeMap[string, int]{from, to}, func(txs []*MapTx[string, int]) error {
+		txs[0].Set("y", 1)
+		txs[1].Delete("x")
+		return errors.New("abort")
+	})
+	require.Error(t, err)
+	require.True(t, from.IsEmpty())
+	require.Equal(t, map[string]int{"x": 1}, to.Export())
+}
+
+func TestMultiTxn_SameMapTwice(t *testing.T) {
+	sm := NewSafeMapFromMap(map[string]int{"a": 1})
+	err := MultiTxn([]*SafeMap[string, int]{sm, sm}, func(txs []*MapTx[string, int]) error {
+		require.Same(t, txs[0], txs[1])
+		txs[0].Set("b", txs[1].Get("a").Value)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"a": 1, "b": 1}, sm.Export())
+}
+
+func TestMultiTxn_NoDeadlock(t *testing.T) {
+	a := NewSafeMapFromMap(map[string]int{"balance": 1000})
+	b := NewSafeMapFromMap(map[string]int{"balance": 1000})
+
+	transfer := func(from, to *SafeMap[string, int]) {
+		_ = MultiTxn([]*SafeMap[string, int]{from, to}, func(txs []*MapTx[string, int]) error {
+			txs[0].Set("balance", txs[0].Get("balance").Value-1)
+			txs[1].Set("balance", txs[1].Get("balance").Value+1)
+			return nil
+		})
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				transfer(a, b)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				transfer(b, a)
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, 600, a.Get("balance").Value)
+	require.Equal(t, 1400, b.Get("balance").Value)
+}
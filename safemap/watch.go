@@ -0,0 +1,241 @@
+package safemap
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultWatchBuffer is the subscriber buffer size used when
+// SubscribeOptions.Buffer is not positive.
+const DefaultWatchBuffer = 64
+
+// ErrSlowSubscriber is returned by Subscription.Err when the subscription was
+// disconnected because its buffer was full.
+var ErrSlowSubscriber = errors.New("subscriber disconnected: buffer full")
+
+// Op is the kind of mutation described by an Event.
+type Op int
+
+const (
+	// OpSet is a key being inserted or its value replaced.
+	OpSet Op = iota + 1
+	// OpDelete is a key removed by Delete or by a compute function.
+	OpDelete
+	// OpPop is a key removed by Pop.
+	OpPop
+	// OpClear is a key removed by Clear. Clear emits one event per key.
+	OpClear
+	// OpExpire is a key removed because its TTL elapsed.
+	OpExpire
+)
+
+// String returns the name of the operation.
+func (op Op) String() string {
+	switch op {
+	case OpSet:
+		return "set"
+	case OpDelete:
+		return "delete"
+	case OpPop:
+		return "pop"
+	case OpClear:
+		return "clear"
+	case OpExpire:
+		return "expire"
+	default:
+		return "unknown"
+	}
+}
+
+// Event describes a single mutation of a SafeMap.
+type Event[K comparable, V any] struct {
+	Op  Op
+	Key K
+	// OldValue is the value before the mutation; it is only meaningful if Existed is true.
+	OldValue V
+	// NewValue is the value after the mutation; it is only meaningful for OpSet.
+	NewValue V
+	// Existed is true if the key existed before the mutation.
+	Existed bool
+}
+
+// SlowSubscriberPolicy decides what happens when an event is published to a
+// subscriber whose buffer is full.
+type SlowSubscriberPolicy int
+
+const (
+	// Drop discards the event for that subscriber and counts it in Dropped.
+	// It is the default.
+	Drop SlowSubscriberPolicy = iota
+	// Disconnect closes the subscription; Err then returns ErrSlowSubscriber.
+	Disconnect
+	// Block makes the writer wait until the subscriber has room. The writer
+	// waits while holding the map's write lock, so every other user of the map
+	// is stalled for as long as the subscriber does not read, and a subscriber
+	// that calls the map's methods before draining its buffer deadlocks the map.
+	Block
+)
+
+// SubscribeOptions configures a subscription.
+type SubscribeOptions[K comparable] struct {
+	// Buffer is the number of events buffered for the subscriber.
+	// If it is not positive, DefaultWatchBuffer is used.
+	Buffer int
+	// Policy decides what happens when the buffer is full. The zero value is Drop.
+	Policy SlowSubscriberPolicy
+	// Filter, if set, selects the keys the subscriber receives events for.
+	Filter func(k K) bool
+}
+
+// KeyPrefix returns a filter that selects keys starting with prefix.
+func KeyPrefix[K ~string](prefix string) func(k K) bool {
+	return func(k K) bool {
+		return strings.HasPrefix(string(k), prefix)
+	}
+}
+
+// Subscription receives the change events of a SafeMap.
+type Subscription[K comparable, V any] struct {
+	sm      *SafeMap[K, V]
+	ch      chan Event[K, V]
+	policy  SlowSubscriberPolicy
+	filter  func(k K) bool
+	done    chan struct{}
+	once    sync.Once
+	dropped atomic.Uint64
+	slow    atomic.Bool
+}
+
+// Subscribe registers a subscriber for all subsequent mutations of the map.
+// Events are delivered in the order the mutations were applied.
+// Call Close on the subscription to stop receiving events.
+//
+// Events are sent while the map's write lock is held. Under the Drop and
+// Disconnect policies a send never waits, so the code receiving the events may
+// call back into the map. Under Block it must not call the map's methods while
+// its buffer may be full; see Block.
+func (sm *SafeMap[K, V]) Subscribe(opts SubscribeOptions[K]) *Subscription[K, V] {
+	if opts.Buffer <= 0 {
+		opts.Buffer = DefaultWatchBuffer
+	}
+	sub := &Subscription[K, V]{
+		sm:     sm,
+		ch:     make(chan Event[K, V], opts.Buffer),
+		policy: opts.Policy,
+		filter: opts.Filter,
+		done:   make(chan struct{}),
+	}
+	sm.Lock()
+	defer sm.Unlock()
+	if sm.subs == nil {
+		sm.subs = make(map[*Subscription[K, V]]struct{})
+	}
+	sm.subs[sub] = struct{}{}
+	return sub
+}
+
+// Watch subscribes to the map until ctx is done. The returned channel is
+// closed when ctx is done or the subscriber is disconnected. Whether the
+// receiver may call back into the map depends on the policy, as for Subscribe.
+func (sm *SafeMap[K, V]) Watch(ctx context.Context, opts SubscribeOptions[K]) <-chan Event[K, V] {
+	sub := sm.Subscribe(opts)
+	go func() {
+		select {
+		case <-ctx.Done():
+			sub.Close()
+		case <-sub.done:
+		}
+	}()
+	return sub.ch
+}
+
+// Events returns the channel on which events are delivered.
+// It is closed when the subscription ends.
+func (s *Subscription[K, V]) Events() <-chan Event[K, V] {
+	return s.ch
+}
+
+// Close ends the subscription and closes the events channel.
+// It is safe to call Close more than once.
+func (s *Subscription[K, V]) Close() {
+	// unblock a writer that is waiting to deliver to this subscriber,
+	// which may be holding the map lock
+	s.once.Do(func() { close(s.done) })
+	s.sm.Lock()
+	defer s.sm.Unlock()
+	s.sm.unsubscribeLocked(s)
+}
+
+// Dropped returns the number of events discarded under the Drop policy.
+func (s *Subscription[K, V]) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Err returns ErrSlowSubscriber if the subscription was disconnected because
+// it did not keep up, and nil otherwise.
+func (s *Subscription[K, V]) Err() error {
+	if s.slow.Load() {
+		return ErrSlowSubscriber
+	}
+	return nil
+}
+
+// unsubscribeLocked removes the subscriber and closes its channel.
+// The caller must hold the write lock.
+func (sm *SafeMap[K, V]) unsubscribeLocked(s *Subscription[K, V]) {
+	if _, ok := sm.subs[s]; !ok {
+		return
+	}
+	delete(sm.subs, s)
+	s.once.Do(func() { close(s.done) })
+	close(s.ch)
+}
+
+// publish delivers the event to all matching subscribers.
+// The caller must hold the write lock.
+func (sm *SafeMap[K, V]) publish(ev Event[K, V]) {
+	for s := range sm.subs {
+		if s.filter != nil && !s.filter(ev.Key) {
+			continue
+		}
+		switch s.policy {
+		case Block:
+			select {
+			case s.ch <- ev:
+			case <-s.done:
+			}
+		case Disconnect:
+			select {
+			case s.ch <- ev:
+			default:
+				s.slow.Store(true)
+				sm.unsubscribeLocked(s)
+			}
+		default:
+			select {
+			case s.ch <- ev:
+			default:
+				s.dropped.Add(1)
+			}
+		}
+	}
+}
+
+// publishSet publishes an OpSet event. The caller must hold the write lock.
+func (sm *SafeMap[K, V]) publishSet(k K, old V, existed bool, v V) {
+	if len(sm.subs) == 0 {
+		return
+	}
+	sm.publish(Event[K, V]{Op: OpSet, Key: k, OldValue: old, NewValue: v, Existed: existed})
+}
+
+// publishRemove publishes a removal event. The caller must hold the write lock.
+func (sm *SafeMap[K, V]) publishRemove(op Op, k K, old V) {
+	if len(sm.subs) == 0 {
+		return
+	}
+	sm.publish(Event[K, V]{Op: op, Key: k, OldValue: old, Existed: true})
+}
@@ -0,0 +1,239 @@
+package safemap
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func receive[K comparable, V any](t *testing.T, ch <-chan Event[K, V]) Event[K, V] {
+	t.Helper()
+	select {
+	case ev, ok := <-ch:
+		require.True(t, ok, "channel closed")
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+		return Event[K, V]{}
+	}
+}
+
+func requireNoEvent[K comparable, V any](t *testing.T, ch <-chan Event[K, V]) {
+	t.Helper()
+	select {
+	case ev := <-ch:
+		t.Fatalf("unexpected event: %+v", ev)
+	default:
+	}
+}
+
+func TestOp_String(t *testing.T) {
+	require.Equal(t, "set", OpSet.String())
+	require.Equal(t, "delete", OpDelete.String())
+	require.Equal(t, "pop", OpPop.String())
+	require.Equal(t, "clear", OpClear.String())
+	require.Equal(t, "expire", OpExpire.String())
+	require.Equal(t, "unknown", Op(0).String())
+}
+
+func TestSafeMap_Subscribe(t *testing.T) {
+	sm := NewSafeMap[string, int]()
+	sub := sm.Subscribe(SubscribeOptions[string]{})
+	defer sub.Close()
+	ch := sub.Events()
+
+	sm.Set("a", 1)
+	require.Equal(t, Event[string, int]{Op: OpSet, Key: "a", NewValue: 1}, receive(t, ch))
+
+	sm.Set("a", 2)
+	require.Equal(t, Event[string, int]{Op: OpSet, Key: "a", OldValue: 1, NewValue: 2, Existed: true}, receive(t, ch))
+
+	require.False(t, sm.SetNX("a", 3))
+	require.True(t, sm.SetNX("b", 3))
+	require.Equal(t, Event[string, int]{Op: OpSet, Key: "b", NewValue: 3}, receive(t, ch))
+
+	sm.Delete("missing")
+	sm.Delete("b")
+	require.Equal(t, Event[string, int]{Op: OpDelete, Key: "b", OldValue: 3, Existed: true}, receive(t, ch))
+
+	sm.Pop("a")
+	require.Equal(t, Event[string, int]{Op: OpPop, Key: "a", OldValue: 2, Existed: true}, receive(t, ch))
+	requireNoEvent(t, ch)
+}
+
+func TestSafeMap_SubscribeClear(t *testing.T) {
+	sm := NewSafeMapFromMap(map[string]int{"a": 1, "b": 2})
+	sub := sm.Subscribe(SubscribeOptions[string]{})
+	defer sub.Close()
+
+	sm.Clear()
+	events := []Event[string, int]{receive(t, sub.Events()), receive(t, sub.Events())}
+	sort.Slice(events, func(i, j int) bool { return events[i].Key < events[j].Key })
+	require.Equal(t, []Event[string, int]{
+		{Op: OpClear, Key: "a", OldValue: 1, Existed: true},
+		{Op: OpClear, Key: "b", OldValue: 2, Existed: true},
+	}, events)
+	requireNoEvent(t, sub.Events())
+}
+
+func TestSafeMap_SubscribeCompute(t *testing.T) {
+	sm := NewSafeMap[string, int]()
+	sub := sm.Subscribe(SubscribeOptions[string]{})
+	defer sub.Close()
+	ch := sub.Events()
+
+	sm.Compute("a", func(old int, _ bool) (int, bool) { return old + 1, true })
+	require.Equal(t, OpSet, receive(t, ch).Op)
+	sm.Update("a", func(old int) int { return old + 1 })
+	require.Equal(t, 2, receive(t, ch).NewValue)
+	sm.GetOrCompute("a", func() int { return 10 })
+	sm.GetOrCompute("b", func() int { return 10 })
+	require.Equal(t, "b", receive(t, ch).Key)
+	sm.CompareAndSwap("b", 10, 11, func(a, b int) bool { return a == b })
+	require.Equal(t, 11, receive(t, ch).NewValue)
+	sm.Compute("b", func(int, bool) (int, bool) { return 0, false })
+	require.Equal(t, Event[string, int]{Op: OpDelete, Key: "b", OldValue: 11, Existed: true}, receive(t, ch))
+	requireNoEvent(t, ch)
+}
+
+func TestSafeMap_SubscribeExpire(t *testing.T) {
+	clock := newFakeClock()
+	sm := newTTLMap(clock)
+	sub := sm.Subscribe(SubscribeOptions[string]{})
+	defer sub.Close()
+
+	sm.SetWithTTL("a", 1, time.Second)
+	require.Equal(t, OpSet, receive(t, sub.Events()).Op)
+	clock.Advance(time.Second)
+	sm.Sweep()
+	require.Equal(t, Event[string, int]{Op: OpExpire, Key: "a", OldValue: 1, Existed: true}, receive(t, sub.Events()))
+}
+
+func TestSafeMap_SubscribeFilter(t *testing.T) {
+	sm := NewSafeMap[string, int]()
+	sub := sm.Subscribe(SubscribeOptions[string]{Filter: KeyPrefix[string]("cfg/")})
+	defer sub.Close()
+
+	sm.Set("other", 1)
+	sm.Set("cfg/a", 2)
+	require.Equal(t, "cfg/a", receive(t, sub.Events()).Key)
+	requireNoEvent(t, sub.Events())
+}
+
+func TestSafeMap_SubscribeDrop(t *testing.T) {
+	sm := NewSafeMap[int, int]()
+	sub := sm.Subscribe(SubscribeOptions[int]{Buffer: 2, Policy: Drop})
+	defer sub.Close()
+
+	for i := 0; i < 5; i++ {
+		sm.Set(i, i)
+	}
+	require.Equal(t, uint64(3), sub.Dropped())
+	require.Equal(t, 0, receive(t, sub.Events()).Key)
+	require.Equal(t, 1, receive(t, sub.Events()).Key)
+	requireNoEvent(t, sub.Events())
+	require.NoError(t, sub.Err())
+}
+
+func TestSafeMap_SubscribeDefaultPolicy(t *testing.T) {
+	sm := NewSafeMap[int, int]()
+	sub := sm.Subscribe(SubscribeOptions[int]{Buffer: 1})
+	defer sub.Close()
+
+	// a full buffer does not stall writers by default, so the receiver may
+	// call back into the map
+	sm.Set(1, 1)
+	sm.Set(2, 2)
+	require.Equal(t, uint64(1), sub.Dropped())
+	ev := receive(t, sub.Events())
+	require.Equal(t, 1, sm.Get(ev.Key).Value)
+	sm.Set(ev.Key, 10)
+	require.Equal(t, 10, receive(t, sub.Events()).NewValue)
+}
+
+func TestSafeMap_SubscribeDisconnect(t *testing.T) {
+	sm := NewSafeMap[int, int]()
+	sub := sm.Subscribe(SubscribeOptions[int]{Buffer: 1, Policy: Disconnect})
+
+	sm.Set(1, 1)
+	sm.Set(2, 2)
+	require.ErrorIs(t, sub.Err(), ErrSlowSubscriber)
+	require.Equal(t, 1, receive(t, sub.Events()).Key)
+	_, ok := <-sub.Events()
+	require.False(t, ok)
+
+	// the map keeps working without the subscriber
+	sm.Set(3, 3)
+	sub.Close()
+}
+
+func TestSafeMap_SubscribeBlock(t *testing.T) {
+	sm := NewSafeMap[int, int]()
+	sub := sm.Subscribe(SubscribeOptions[int]{Buffer: 1, Policy: Block})
+
+	sm.Set(1, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sm.Set(2, 2)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("writer did not block on a full subscriber")
+	case <-time.After(20 * time.Millisecond):
+	}
+	require.Equal(t, 1, receive(t, sub.Events()).Key)
+	<-done
+	require.Equal(t, 2, receive(t, sub.Events()).Key)
+
+	// closing a subscriber unblocks a waiting writer
+	sm.Set(3, 3)
+	done = make(chan struct{})
+	go func() {
+		defer close(done)
+		sm.Set(4, 4)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	sub.Close()
+	<-done
+	sm.Set(5, 5)
+	require.Equal(t, 5, sm.Len())
+}
+
+func TestSafeMap_Watch(t *testing.T) {
+	sm := NewSafeMap[string, int]()
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := sm.Watch(ctx, SubscribeOptions[string]{})
+
+	sm.Set("a", 1)
+	require.Equal(t, "a", receive(t, ch).Key)
+
+	cancel()
+	select {
+	case _, ok := <-ch:
+		require.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("watch channel was not closed")
+	}
+
+	sm.RLock()
+	defer sm.RUnlock()
+	require.Empty(t, sm.subs)
+}
+
+func TestSafeMap_SubscribeOrdered(t *testing.T) {
+	sm := NewSafeMap[int, int]()
+	sub := sm.Subscribe(SubscribeOptions[int]{Buffer: 1000})
+	defer sub.Close()
+
+	for i := 0; i < 1000; i++ {
+		sm.Set(0, i)
+	}
+	for i := 0; i < 1000; i++ {
+		require.Equal(t, i, receive(t, sub.Events()).NewValue)
+	}
+}
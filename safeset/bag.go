@@ -0,0 +1,279 @@
+package safeset
+
+import (
+	"cmp"
+	"fmt"
+	"iter"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Bag represents a thread-safe multiset: a set that counts how many times each element occurs.
+// Operations on two bags lock them in the same global order as Set.
+type Bag[T comparable] struct {
+	setLock
+	counts map[T]int
+	total  int
+}
+
+// ElementCount is an element of a Bag with its number of occurrences
+type ElementCount[T comparable] struct {
+	Element T
+	Count   int
+}
+
+// NewBag creates and returns a new Bag
+func NewBag[T comparable]() *Bag[T] {
+	return &Bag[T]{
+		counts: make(map[T]int),
+	}
+}
+
+// NewBagWithValues creates and returns a new Bag with one occurrence of each given value
+func NewBagWithValues[T comparable](values ...T) *Bag[T] {
+	b := NewBag[T]()
+	for _, value := range values {
+		b.counts[value]++
+	}
+	b.total = len(values)
+	return b
+}
+
+// NewBagFromSet creates and returns a new Bag with one occurrence of each element of s
+func NewBagFromSet[T comparable](s *Set[T]) *Bag[T] {
+	return NewBagWithValues(s.ToSlice()...)
+}
+
+// Add adds n occurrences of an element to the bag and returns the new count of the element.
+// A non-positive n leaves the bag unchanged
+func (b *Bag[T]) Add(item T, n int) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n > 0 {
+		b.counts[item] += n
+		b.total += n
+	}
+	return b.counts[item]
+}
+
+// Remove removes up to n occurrences of an element from the bag and returns the new count
+// of the element. A non-positive n leaves the bag unchanged
+func (b *Bag[T]) Remove(item T, n int) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n <= 0 {
+		return b.counts[item]
+	}
+	return b.setCount(item, max(b.counts[item]-n, 0))
+}
+
+// RemoveAll removes every occurrence of an element and returns how many there were
+func (b *Bag[T]) RemoveAll(item T) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	count := b.counts[item]
+	b.setCount(item, 0)
+	return count
+}
+
+// SetCount sets the number of occurrences of an element. A non-positive count removes it
+func (b *Bag[T]) SetCount(item T, count int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.setCount(item, max(count, 0))
+}
+
+// Count returns the number of occurrences of an element
+func (b *Bag[T]) Count(item T) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.counts[item]
+}
+
+// Contains checks if an element occurs at least once in the bag
+func (b *Bag[T]) Contains(item T) bool {
+	return b.Count(item) > 0
+}
+
+// Total returns the number of occurrences of all elements in the bag
+func (b *Bag[T]) Total() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.total
+}
+
+// Size returns the number of distinct elements in the bag
+func (b *Bag[T]) Size() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.counts)
+}
+
+// IsEmpty returns true if the bag is empty
+func (b *Bag[T]) IsEmpty() bool {
+	return b.Size() == 0
+}
+
+// Clear removes all elements from the bag
+func (b *Bag[T]) Clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.counts = make(map[T]int)
+	b.total = 0
+}
+
+// MostCommon returns the k elements with the highest counts, from most to least common.
+// Elements with equal counts are returned in no particular order. A negative k returns all elements
+func (b *Bag[T]) MostCommon(k int) []ElementCount[T] {
+	b.mu.RLock()
+	counts := make([]ElementCount[T], 0, len(b.counts))
+	for item, count := range b.counts {
+		counts = append(counts, ElementCount[T]{Element: item, Count: count})
+	}
+	b.mu.RUnlock()
+
+	slices.SortFunc(counts, func(a, b ElementCount[T]) int {
+		return cmp.Compare(b.Count, a.Count)
+	})
+	if k >= 0 && k < len(counts) {
+		counts = counts[:k]
+	}
+	return counts
+}
+
+// All returns an iterator over the distinct elements of the bag and their counts, in no
+// particular order
+func (b *Bag[T]) All() iter.Seq2[T, int] {
+	return func(yield func(T, int) bool) {
+		for item, count := range b.ToMap() {
+			if !yield(item, count) {
+				return
+			}
+		}
+	}
+}
+
+// ToMap returns a map from each distinct element to its count
+func (b *Bag[T]) ToMap() map[T]int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return maps.Clone(b.counts)
+}
+
+// ToSlice returns a slice containing every occurrence of every element, in no particular order
+func (b *Bag[T]) ToSlice() []T {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	slice := make([]T, 0, b.total)
+	for item, count := range b.counts {
+		for i := 0; i < count; i++ {
+			slice = append(slice, item)
+		}
+	}
+	return slice
+}
+
+// ToSet returns a new set with the distinct elements of the bag
+func (b *Bag[T]) ToSet() *Set[T] {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	s := NewSet[T]()
+	for item := range b.counts {
+		s.add(item)
+	}
+	return s
+}
+
+// Sum returns a new bag in which each element occurs as often as in b and other combined
+func (b *Bag[T]) Sum(other *Bag[T]) *Bag[T] {
+	return b.combine(other, func(ours, theirs int) int { return ours + theirs })
+}
+
+// Union returns a new bag in which each element occurs as often as in whichever of b and
+// other has more of it
+func (b *Bag[T]) Union(other *Bag[T]) *Bag[T] {
+	return b.combine(other, func(ours, theirs int) int { return max(ours, theirs) })
+}
+
+// Intersection returns a new bag in which each element occurs as often as in whichever of
+// b and other has fewer of it
+func (b *Bag[T]) Intersection(other *Bag[T]) *Bag[T] {
+	return b.combine(other, func(ours, theirs int) int { return min(ours, theirs) })
+}
+
+// Difference returns a new bag in which each element occurs as often as in b minus its
+// count in other, dropping elements whose count would not be positive
+func (b *Bag[T]) Difference(other *Bag[T]) *Bag[T] {
+	return b.combine(other, func(ours, theirs int) int { return ours - theirs })
+}
+
+// IsSubBagOf returns true if every element occurs in other at least as often as in b
+func (b *Bag[T]) IsSubBagOf(other *Bag[T]) bool {
+	defer lockSets(nil, b, other)()
+	for item, count := range b.counts {
+		if other.counts[item] < count {
+			return false
+		}
+	}
+	return true
+}
+
+// Equal returns true if b and other contain the same elements with the same counts
+func (b *Bag[T]) Equal(other *Bag[T]) bool {
+	defer lockSets(nil, b, other)()
+	return maps.Equal(b.counts, other.counts)
+}
+
+// Clone returns a new bag with the same elements and counts as b
+func (b *Bag[T]) Clone() *Bag[T] {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return &Bag[T]{counts: maps.Clone(b.counts), total: b.total}
+}
+
+// String returns a string representation of the bag
+func (b *Bag[T]) String() string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var sb strings.Builder
+	sb.WriteByte('{')
+	i := 0
+	for item, count := range b.counts {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		fmt.Fprintf(&sb, "%v:%d", item, count)
+		i++
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// setCount sets the count of an element, removing it at zero, and returns the count.
+// The caller must hold the write lock
+func (b *Bag[T]) setCount(item T, count int) int {
+	b.total += count - b.counts[item]
+	if count == 0 {
+		delete(b.counts, item)
+	} else {
+		b.counts[item] = count
+	}
+	return count
+}
+
+// combine returns a new bag with the counts computed by fn for every element of b or other
+func (b *Bag[T]) combine(other *Bag[T], fn func(ours, theirs int) int) *Bag[T] {
+	defer lockSets(nil, b, other)()
+	theirs := other.counts
+	result := NewBag[T]()
+	for item, count := range b.counts {
+		result.setCount(item, max(fn(count, theirs[item]), 0))
+	}
+	for item, count := range theirs {
+		if _, ok := b.counts[item]; !ok {
+			result.setCount(item, max(fn(0, count), 0))
+		}
+	}
+	return result
+}
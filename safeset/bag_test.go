@@ -0,0 +1,151 @@
+package safeset
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBag_AddRemove(t *testing.T) {
+	b := NewBag[string]()
+	require.True(t, b.IsEmpty())
+	require.Equal(t, 2, b.Add("go", 2))
+	require.Equal(t, 3, b.Add("go", 1))
+	require.Equal(t, 1, b.Add("rust", 1))
+	require.Equal(t, 1, b.Add("rust", 0))
+	require.Equal(t, 3, b.Count("go"))
+	require.Equal(t, 0, b.Count("zig"))
+	require.Equal(t, 4, b.Total())
+	require.Equal(t, 2, b.Size())
+
+	require.Equal(t, 1, b.Remove("go", 2))
+	require.Equal(t, 0, b.Remove("go", 5))
+	require.False(t, b.Contains("go"))
+	require.Equal(t, 1, b.Total())
+	require.Equal(t, 1, b.Size())
+
+	b.SetCount("zig", 4)
+	require.Equal(t, 5, b.Total())
+	require.Equal(t, 4, b.RemoveAll("zig"))
+	b.SetCount("rust", -1)
+	require.True(t, b.IsEmpty())
+	require.Equal(t, 0, b.Total())
+}
+
+func TestBag_NewBagWithValues(t *testing.T) {
+	b := NewBagWithValues("a", "b", "a", "c", "a")
+	require.Equal(t, map[string]int{"a": 3, "b": 1, "c": 1}, b.ToMap())
+	require.Equal(t, 5, b.Total())
+	require.ElementsMatch(t, []string{"a", "a", "a", "b", "c"}, b.ToSlice())
+
+	b.Clear()
+	require.True(t, b.IsEmpty())
+	require.Equal(t, 0, b.Total())
+}
+
+func TestBag_MostCommon(t *testing.T) {
+	b := NewBagWithValues("a", "b", "b", "c", "c", "c")
+	require.Equal(t, []ElementCount[string]{{"c", 3}, {"b", 2}}, b.MostCommon(2))
+	require.Len(t, b.MostCommon(10), 3)
+	require.Len(t, b.MostCommon(-1), 3)
+	require.Empty(t, b.MostCommon(0))
+}
+
+func TestBag_Algebra(t *testing.T) {
+	a := NewBagWithValues("x", "x", "x", "y")
+	b := NewBagWithValues("x", "y", "y", "z")
+
+	require.Equal(t, map[string]int{"x": 4, "y": 3, "z": 1}, a.Sum(b).ToMap())
+	require.Equal(t, map[string]int{"x": 3, "y": 2, "z": 1}, a.Union(b).ToMap())
+	require.Equal(t, map[string]int{"x": 1, "y": 1}, a.Intersection(b).ToMap())
+	require.Equal(t, map[string]int{"x": 2}, a.Difference(b).ToMap())
+	require.Equal(t, map[string]int{"y": 1, "z": 1}, b.Difference(a).ToMap())
+	require.Equal(t, 4, a.Intersection(b).Total()+a.Difference(b).Total())
+	require.True(t, a.Sum(a).Equal(a.Union(a).Sum(a)))
+}
+
+func TestBag_Relations(t *testing.T) {
+	a := NewBagWithValues(1, 1, 2)
+	b := NewBagWithValues(1, 1, 2, 3)
+	require.True(t, a.IsSubBagOf(b))
+	require.False(t, b.IsSubBagOf(a))
+	require.False(t, NewBagWithValues(1, 1, 1).IsSubBagOf(b))
+
+	require.True(t, a.Equal(a.Clone()))
+	require.False(t, a.Equal(b))
+	clone := a.Clone()
+	clone.Add(1, 1)
+	require.Equal(t, 2, a.Count(1))
+	require.Equal(t, "{1:3}", clone.Difference(NewBagWithValues(2)).String())
+}
+
+func TestBag_SetConversion(t *testing.T) {
+	b := NewBagWithValues("a", "a", "b")
+	s := b.ToSet()
+	require.True(t, s.Equal(NewSetWithValues("a", "b")))
+
+	fromSet := NewBagFromSet(s)
+	require.Equal(t, map[string]int{"a": 1, "b": 1}, fromSet.ToMap())
+}
+
+func TestBag_All(t *testing.T) {
+	b := NewBagWithValues("a", "a", "b")
+	counts := make(map[string]int)
+	for item, count := range b.All() {
+		counts[item] = count
+		b.Add(item, b.Count(item)) // the body may use the bag
+	}
+	require.Equal(t, map[string]int{"a": 2, "b": 1}, counts)
+	require.Equal(t, map[string]int{"a": 4, "b": 2}, b.ToMap())
+}
+
+func TestBag_Concurrent(t *testing.T) {
+	b := NewBag[int]()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				b.Add(i%10, 2)
+				b.Remove(i%10, 1)
+				b.MostCommon(3)
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, 800, b.Total())
+	for i := 0; i < 10; i++ {
+		require.Equal(t, 80, b.Count(i))
+	}
+}
+
+func TestBag_AlgebraConcurrent(t *testing.T) {
+	a := NewBagWithValues(1, 2, 2)
+	b := NewBagWithValues(2, 3, 3)
+
+	var wg sync.WaitGroup
+	run := func(fn func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 300; i++ {
+				fn(i)
+			}
+		}()
+	}
+
+	// writers
+	run(func(i int) { a.Add(i%5, 1); a.Remove((i+2)%5, 1) })
+	run(func(i int) { b.Add(i%5, 2); b.RemoveAll((i + 3) % 5) })
+
+	// the same operations with their arguments in opposite orders
+	run(func(int) { a.Sum(b); b.Sum(a) })
+	run(func(int) { a.Union(b); b.Intersection(a) })
+	run(func(int) { a.Difference(b); b.Difference(a) })
+	run(func(int) { a.IsSubBagOf(b); b.IsSubBagOf(a); a.Equal(b); b.Equal(a) })
+	wg.Wait()
+
+	require.True(t, a.Sum(b).Equal(b.Sum(a)))
+}
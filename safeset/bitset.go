@@ -0,0 +1,565 @@
+package safeset
+
+import (
+	"fmt"
+	"iter"
+	"math/bits"
+	"math/rand/v2"
+	"slices"
+	"strings"
+)
+
+const wordBits = 64
+
+// MaxBitSetElement is the largest integer a BitSet can hold. A BitSet holding it
+// takes 512 MiB. Adding a larger integer panics instead of allocating a bitmap
+// that cannot fit in memory
+const MaxBitSetElement uint = 1<<32 - 1
+
+// BitSet is a thread-safe set of unsigned integers stored as a bitmap, one bit
+// per integer up to the largest element. It is much smaller and faster than a
+// Set[uint] for dense domains such as IDs and flags; its memory grows with the
+// largest element, not with the number of elements, and elements are limited
+// to MaxBitSetElement. There is no compressed (roaring) variant; use a Set[uint]
+// for sparse sets of large integers.
+// Set algebra works a 64-bit word at a time and locks sets in the same global
+// order as Set. Pop, PopN, RandomElement and Sample scan the words, so they take
+// time proportional to the largest element divided by 64.
+type BitSet struct {
+	setLock
+	words []uint64 // the last word is never zero
+	count int
+	rng   *lockedRand
+}
+
+// NewBitSet creates and returns a new BitSet
+func NewBitSet() *BitSet {
+	return &BitSet{}
+}
+
+// NewBitSetWithValues creates and returns a new BitSet with the given values.
+// It panics if a value is larger than MaxBitSetElement
+func NewBitSetWithValues(values ...uint) *BitSet {
+	b := NewBitSet()
+	for _, value := range values {
+		b.add(value)
+	}
+	return b
+}
+
+// Add adds an element to the set. It panics if item is larger than MaxBitSetElement
+func (b *BitSet) Add(item uint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.add(item)
+}
+
+// AddWithCheck adds an element to the set and returns true if the element was already in the set.
+// It panics if item is larger than MaxBitSetElement
+func (b *BitSet) AddWithCheck(item uint) (existed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !b.add(item)
+}
+
+// Remove removes an element from the set
+func (b *BitSet) Remove(item uint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remove(item)
+}
+
+// Contains checks if an element is in the set
+func (b *BitSet) Contains(item uint) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	w := item / wordBits
+	return w < uint(len(b.words)) && b.words[w]&(1<<(item%wordBits)) != 0
+}
+
+// Size returns the number of elements in the set
+func (b *BitSet) Size() int {
+	return b.Count()
+}
+
+// Count returns the number of elements in the set
+func (b *BitSet) Count() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.count
+}
+
+// Clear removes all elements from the set
+func (b *BitSet) Clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.words, b.count = nil, 0
+}
+
+// IsEmpty returns true if the set is empty
+func (b *BitSet) IsEmpty() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.count == 0
+}
+
+// ToSlice returns a slice containing all elements in the set, in ascending order
+func (b *BitSet) ToSlice() []uint {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	slice := make([]uint, 0, b.count)
+	for item := range b.all {
+		slice = append(slice, item)
+	}
+	return slice
+}
+
+// All returns an iterator over the elements of the set, in ascending order
+func (b *BitSet) All() iter.Seq[uint] {
+	return func(yield func(uint) bool) {
+		b.mu.RLock()
+		c := &BitSet{words: slices.Clone(b.words)}
+		b.mu.RUnlock()
+		c.all(yield)
+	}
+}
+
+// NextSet returns the smallest element that is greater than or equal to i,
+// and false if there is none
+func (b *BitSet) NextSet(i uint) (uint, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	w := i / wordBits
+	if w >= uint(len(b.words)) {
+		return 0, false
+	}
+	if word := b.words[w] >> (i % wordBits); word != 0 {
+		return i + uint(bits.TrailingZeros64(word)), true
+	}
+	for w++; w < uint(len(b.words)); w++ {
+		if word := b.words[w]; word != 0 {
+			return w*wordBits + uint(bits.TrailingZeros64(word)), true
+		}
+	}
+	return 0, false
+}
+
+// Complement returns a new set with the integers in [0, upTo) that are not in b.
+// It panics if upTo-1 is larger than MaxBitSetElement
+func (b *BitSet) Complement(upTo uint) *BitSet {
+	if upTo > 0 {
+		checkBitSetElement(upTo - 1)
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	n := wordsFor(upTo)
+	words := make([]uint64, n)
+	for w := range words {
+		words[w] = ^uint64(0)
+		if w < len(b.words) {
+			words[w] &^= b.words[w]
+		}
+	}
+	if rem := upTo % wordBits; rem != 0 {
+		words[n-1] &= 1<<rem - 1
+	}
+	return newBitSetFromWords(words)
+}
+
+// Union returns a new set that is the union of b and other
+func (b *BitSet) Union(other *BitSet) *BitSet {
+	defer lockSets(nil, b, other)()
+	long, short := b.words, other.words
+	if len(long) < len(short) {
+		long, short = short, long
+	}
+	words := slices.Clone(long)
+	for w, word := range short {
+		words[w] |= word
+	}
+	return newBitSetFromWords(words)
+}
+
+// Intersection returns a new set that is the intersection of b and other
+func (b *BitSet) Intersection(other *BitSet) *BitSet {
+	defer lockSets(nil, b, other)()
+	words := make([]uint64, min(len(b.words), len(other.words)))
+	for w := range words {
+		words[w] = b.words[w] & other.words[w]
+	}
+	return newBitSetFromWords(words)
+}
+
+// Difference returns a new set that is the difference of b and other
+func (b *BitSet) Difference(other *BitSet) *BitSet {
+	defer lockSets(nil, b, other)()
+	words := slices.Clone(b.words)
+	for w := range min(len(words), len(other.words)) {
+		words[w] &^= other.words[w]
+	}
+	return newBitSetFromWords(words)
+}
+
+// SymmetricDifference returns a new set that is the symmetric difference (XOR) of b and other
+func (b *BitSet) SymmetricDifference(other *BitSet) *BitSet {
+	defer lockSets(nil, b, other)()
+	long, short := b.words, other.words
+	if len(long) < len(short) {
+		long, short = short, long
+	}
+	words := slices.Clone(long)
+	for w, word := range short {
+		words[w] ^= word
+	}
+	return newBitSetFromWords(words)
+}
+
+// UnionWith adds the elements of all the given sets to b
+func (b *BitSet) UnionWith(others ...*BitSet) {
+	defer lockSets(b, others...)()
+	for _, other := range others {
+		if len(other.words) > len(b.words) {
+			b.words = append(b.words, make([]uint64, len(other.words)-len(b.words))...)
+		}
+		for w, word := range other.words {
+			b.words[w] |= word
+		}
+	}
+	b.recount()
+}
+
+// IntersectWith removes the elements of b that are not in all of the given sets
+func (b *BitSet) IntersectWith(others ...*BitSet) {
+	defer lockSets(b, others...)()
+	for _, other := range others {
+		b.words = b.words[:min(len(b.words), len(other.words))]
+		for w := range b.words {
+			b.words[w] &= other.words[w]
+		}
+	}
+	b.recount()
+}
+
+// SubtractWith removes the elements of all the given sets from b
+func (b *BitSet) SubtractWith(others ...*BitSet) {
+	defer lockSets(b, others...)()
+	for _, other := range others {
+		for w := range min(len(b.words), len(other.words)) {
+			b.words[w] &^= other.words[w]
+		}
+	}
+	b.recount()
+}
+
+// IsSubsetOf returns true if b is a subset of other
+func (b *BitSet) IsSubsetOf(other *BitSet) bool {
+	defer lockSets(nil, b, other)()
+	if len(b.words) > len(other.words) {
+		return false
+	}
+	for w, word := range b.words {
+		if word&^other.words[w] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// IsSupersetOf returns true if b is a superset of other
+func (b *BitSet) IsSupersetOf(other *BitSet) bool {
+	return other.IsSubsetOf(b)
+}
+
+// Equal returns true if b and other contain the same elements
+func (b *BitSet) Equal(other *BitSet) bool {
+	defer lockSets(nil, b, other)()
+	return slices.Equal(b.words, other.words)
+}
+
+// Clone returns a new set with the same elements as b
+func (b *BitSet) Clone() *BitSet {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return &BitSet{words: slices.Clone(b.words), count: b.count}
+}
+
+// String returns a string representation of the set, in ascending order
+func (b *BitSet) String() string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for item := range b.all {
+		if sb.Len() > 1 {
+			sb.WriteString(", ")
+		}
+		fmt.Fprintf(&sb, "%d", item)
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// Filter returns a new set with the elements for which fn returns true.
+// Like the other functional methods, it runs under a single lock acquisition
+// and fn must not call any method on the set, not even a read (that deadlocks)
+func (b *BitSet) Filter(fn func(uint) bool) *BitSet {
+	matched, _ := b.Partition(fn)
+	return matched
+}
+
+// Partition splits the set into a new set with the elements for which fn
+// returns true and a new set with the rest
+func (b *BitSet) Partition(fn func(uint) bool) (matched, rest *BitSet) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	matched, rest = NewBitSet(), NewBitSet()
+	for item := range b.all {
+		if fn(item) {
+			matched.add(item)
+		} else {
+			rest.add(item)
+		}
+	}
+	return matched, rest
+}
+
+// Any returns true if fn returns true for at least one element
+func (b *BitSet) Any(fn func(uint) bool) bool {
+	_, ok := b.Find(fn)
+	return ok
+}
+
+// Every returns true if fn returns true for every element. It is true for an empty set
+func (b *BitSet) Every(fn func(uint) bool) bool {
+	_, ok := b.Find(func(item uint) bool { return !fn(item) })
+	return !ok
+}
+
+// Find returns the smallest element for which fn returns true, and false if there is none
+func (b *BitSet) Find(fn func(uint) bool) (uint, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for item := range b.all {
+		if fn(item) {
+			return item, true
+		}
+	}
+	return 0, false
+}
+
+// RemoveIf removes the elements for which fn returns true and returns how many were removed
+func (b *BitSet) RemoveIf(fn func(uint) bool) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	removed := 0
+	for w, word := range b.words {
+		for rest := word; rest != 0; rest &= rest - 1 {
+			bit := rest & -rest
+			if fn(uint(w)*wordBits + uint(bits.TrailingZeros64(rest))) {
+				b.words[w] &^= bit
+				removed++
+			}
+		}
+	}
+	b.count -= removed
+	b.trim()
+	return removed
+}
+
+// RetainIf keeps only the elements for which fn returns true and returns how many were removed
+func (b *BitSet) RetainIf(fn func(uint) bool) int {
+	return b.RemoveIf(func(item uint) bool {
+		return !fn(item)
+	})
+}
+
+// SetRandSource sets the source of randomness used by Pop, PopN, RandomElement
+// and Sample, like Set.SetRandSource
+func (b *BitSet) SetRandSource(src rand.Source) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rng = newLockedRand(src)
+}
+
+// Pop removes and returns a random element of the set, and false if the set is empty
+func (b *BitSet) Pop() (uint, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.count == 0 {
+		return 0, false
+	}
+	item := b.nth(b.rng.intN(b.count))
+	b.remove(item)
+	return item, true
+}
+
+// PopN removes and returns up to n random elements of the set, in ascending order
+func (b *BitSet) PopN(n int) []uint {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	popped := b.sample(n)
+	for _, item := range popped {
+		b.remove(item)
+	}
+	return popped
+}
+
+// RandomElement returns a random element of the set without removing it,
+// and false if the set is empty
+func (b *BitSet) RandomElement() (uint, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.count == 0 {
+		return 0, false
+	}
+	return b.nth(b.rng.intN(b.count)), true
+}
+
+// Sample returns k distinct elements chosen uniformly at random, in ascending
+// order. If k is at least the size of the set, all elements are returned.
+// It does not modify the set
+func (b *BitSet) Sample(k int) []uint {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.sample(k)
+}
+
+// sample returns k distinct random elements in ascending order. The caller must hold a lock
+func (b *BitSet) sample(k int) []uint {
+	k = min(max(k, 0), b.count)
+	ranks := make([]int, 0, k)
+	if k == b.count {
+		for i := range k {
+			ranks = append(ranks, i)
+		}
+	} else {
+		// Floyd's algorithm, as in Set.Sample, over the ranks of the elements
+		chosen := make(map[int]struct{}, k)
+		for j := b.count - k; j < b.count; j++ {
+			i := b.rng.intN(j + 1)
+			if _, ok := chosen[i]; ok {
+				i = j
+			}
+			chosen[i] = struct{}{}
+			ranks = append(ranks, i)
+		}
+		slices.Sort(ranks)
+	}
+
+	sample := make([]uint, 0, k)
+	rank := 0
+	for item := range b.all {
+		if len(sample) == k {
+			break
+		}
+		if ranks[len(sample)] == rank {
+			sample = append(sample, item)
+		}
+		rank++
+	}
+	return sample
+}
+
+// nth returns the element with the given rank, counting from zero in
+// ascending order. The caller must hold a lock and ensure 0 <= r < b.count
+func (b *BitSet) nth(r int) uint {
+	for w, word := range b.words {
+		if n := bits.OnesCount64(word); r >= n {
+			r -= n
+			continue
+		}
+		for ; r > 0; r-- {
+			word &= word - 1
+		}
+		return uint(w)*wordBits + uint(bits.TrailingZeros64(word))
+	}
+	panic("safeset: BitSet rank out of range")
+}
+
+// wordsFor returns the number of words needed to hold the integers in [0, n)
+func wordsFor(n uint) uint {
+	words := n / wordBits
+	if n%wordBits != 0 {
+		words++
+	}
+	return words
+}
+
+// checkBitSetElement panics if item is larger than MaxBitSetElement
+func checkBitSetElement(item uint) {
+	if item > MaxBitSetElement {
+		panic(fmt.Sprintf("safeset: BitSet element %d exceeds MaxBitSetElement", item))
+	}
+}
+
+// newBitSetFromWords creates a BitSet that takes ownership of words
+func newBitSetFromWords(words []uint64) *BitSet {
+	b := &BitSet{words: words}
+	b.recount()
+	return b
+}
+
+// add sets the bit of item and returns true if it was not set yet.
+// The caller must hold the write lock
+func (b *BitSet) add(item uint) bool {
+	checkBitSetElement(item)
+	w, mask := item/wordBits, uint64(1)<<(item%wordBits)
+	if w >= uint(len(b.words)) {
+		b.words = append(b.words, make([]uint64, w+1-uint(len(b.words)))...)
+	}
+	if b.words[w]&mask != 0 {
+		return false
+	}
+	b.words[w] |= mask
+	b.count++
+	return true
+}
+
+// remove clears the bit of item and returns true if it was set.
+// The caller must hold the write lock
+func (b *BitSet) remove(item uint) bool {
+	w, mask := item/wordBits, uint64(1)<<(item%wordBits)
+	if w >= uint(len(b.words)) || b.words[w]&mask == 0 {
+		return false
+	}
+	b.words[w] &^= mask
+	b.count--
+	b.trim()
+	return true
+}
+
+// all yields the elements in ascending order. The caller must hold a lock
+func (b *BitSet) all(yield func(uint) bool) {
+	for w, word := range b.words {
+		for word != 0 {
+			item := uint(w)*wordBits + uint(bits.TrailingZeros64(word))
+			if !yield(item) {
+				return
+			}
+			word &= word - 1
+		}
+	}
+}
+
+// trim drops trailing zero words. The caller must hold the write lock
+func (b *BitSet) trim() {
+	n := len(b.words)
+	for n > 0 && b.words[n-1] == 0 {
+		n--
+	}
+	b.words = b.words[:n]
+}
+
+// recount trims the set and recomputes its size after word-level changes.
+// The caller must hold the write lock
+func (b *BitSet) recount() {
+	b.trim()
+	b.count = 0
+	for _, word := range b.words {
+		b.count += bits.OnesCount64(word)
+	}
+}
@@ -0,0 +1,263 @@
+package safeset
+
+import (
+	"math"
+	"math/bits"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitSet_AddRemoveContains(t *testing.T) {
+	b := NewBitSet()
+	require.True(t, b.IsEmpty())
+
+	b.Add(3)
+	b.Add(64)
+	b.Add(1000)
+	require.False(t, b.AddWithCheck(5))
+	require.True(t, b.AddWithCheck(5))
+	require.Equal(t, 4, b.Count())
+	require.Equal(t, 4, b.Size())
+	require.True(t, b.Contains(64))
+	require.False(t, b.Contains(63))
+	require.False(t, b.Contains(1<<40))
+
+	b.Remove(1000)
+	b.Remove(999)
+	b.Remove(1 << 40)
+	require.Equal(t, 3, b.Count())
+	require.Len(t, b.words, 2)
+	require.Equal(t, []uint{3, 5, 64}, b.ToSlice())
+
+	b.Clear()
+	require.True(t, b.IsEmpty())
+	require.Empty(t, b.ToSlice())
+}
+
+func TestBitSet_All(t *testing.T) {
+	b := NewBitSetWithValues(130, 0, 7, 64)
+	var items []uint
+	for item := range b.All() {
+		items = append(items, item)
+		b.Remove(item + 1) // the body may use the set
+		require.True(t, b.Contains(item))
+		if len(items) == 3 {
+			break
+		}
+	}
+	require.Equal(t, []uint{0, 7, 64}, items)
+	require.Equal(t, "{0, 7, 64, 130}", b.String())
+	require.Equal(t, "{}", NewBitSet().String())
+}
+
+func TestBitSet_NextSet(t *testing.T) {
+	b := NewBitSetWithValues(2, 63, 200)
+	var items []uint
+	for i, ok := b.NextSet(0); ok; i, ok = b.NextSet(i + 1) {
+		items = append(items, i)
+	}
+	require.Equal(t, []uint{2, 63, 200}, items)
+
+	i, ok := b.NextSet(64)
+	require.True(t, ok)
+	require.Equal(t, uint(200), i)
+	_, ok = b.NextSet(201)
+	require.False(t, ok)
+	_, ok = NewBitSet().NextSet(0)
+	require.False(t, ok)
+}
+
+func TestBitSet_Complement(t *testing.T) {
+	b := NewBitSetWithValues(1, 3, 100)
+	c := b.Complement(6)
+	require.Equal(t, []uint{0, 2, 4, 5}, c.ToSlice())
+	require.Equal(t, 4, c.Count())
+
+	c = b.Complement(128)
+	require.Equal(t, 125, c.Count())
+	require.False(t, c.Contains(100))
+	require.False(t, c.Contains(128))
+	require.True(t, NewBitSet().Complement(0).IsEmpty())
+}
+
+func TestBitSet_LargeElement(t *testing.T) {
+	if bits.UintSize < 64 {
+		t.Skip("MaxBitSetElement is the largest uint")
+	}
+	shift, maxElement := 40, MaxBitSetElement
+	large := uint(1) << shift
+	b := NewBitSetWithValues(1, 2)
+
+	require.Panics(t, func() { b.Add(large) })
+	require.Panics(t, func() { b.AddWithCheck(maxElement + 1) })
+	require.Panics(t, func() { NewBitSetWithValues(large) })
+	require.Panics(t, func() { b.Complement(large) })
+	require.Equal(t, []uint{1, 2}, b.ToSlice())
+
+	// reads and removals of large integers do not grow the set
+	require.False(t, b.Contains(large))
+	b.Remove(large)
+	require.Equal(t, 1, len(b.words))
+
+	require.ErrorIs(t, b.UnmarshalJSON([]byte(`[3, 1099511627776]`)), ErrBitSetElementTooLarge)
+	require.Equal(t, []uint{1, 2}, b.ToSlice())
+}
+
+func TestBitSet_Algebra(t *testing.T) {
+	a := NewBitSetWithValues(1, 2, 3, 200)
+	b := NewBitSetWithValues(2, 3, 4)
+
+	require.Equal(t, []uint{1, 2, 3, 4, 200}, a.Union(b).ToSlice())
+	require.Equal(t, []uint{2, 3}, a.Intersection(b).ToSlice())
+	require.Equal(t, []uint{1, 200}, a.Difference(b).ToSlice())
+	require.Equal(t, []uint{4}, b.Difference(a).ToSlice())
+	require.Equal(t, []uint{1, 4, 200}, a.SymmetricDifference(b).ToSlice())
+	require.Equal(t, 3, a.Difference(NewBitSetWithValues(2)).Count())
+
+	require.True(t, NewBitSetWithValues(2, 3).IsSubsetOf(a))
+	require.False(t, b.IsSubsetOf(a))
+	require.True(t, a.IsSupersetOf(NewBitSetWithValues(200)))
+	require.False(t, NewBitSetWithValues(500).IsSubsetOf(a))
+	require.True(t, a.Equal(a.Clone()))
+	require.False(t, a.Equal(b))
+
+	// removing the highest element makes the sets equal again
+	c := a.Clone()
+	c.Add(5000)
+	c.Remove(5000)
+	require.True(t, a.Equal(c))
+}
+
+func TestBitSet_InPlace(t *testing.T) {
+	b := NewBitSetWithValues(1, 2)
+	b.UnionWith(NewBitSetWithValues(2, 300), b)
+	require.Equal(t, []uint{1, 2, 300}, b.ToSlice())
+	require.Equal(t, 3, b.Count())
+
+	b.IntersectWith(NewBitSetWithValues(1, 2, 3), b)
+	require.Equal(t, []uint{1, 2}, b.ToSlice())
+
+	b.SubtractWith(NewBitSetWithValues(1))
+	require.Equal(t, []uint{2}, b.ToSlice())
+	b.SubtractWith(b)
+	require.True(t, b.IsEmpty())
+}
+
+func TestBitSet_Concurrent(t *testing.T) {
+	a := NewBitSetWithValues(1, 2, 3)
+	b := NewBitSetWithValues(2, 3, 400)
+
+	var wg sync.WaitGroup
+	run := func(fn func(i uint)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := uint(0); i < 500; i++ {
+				fn(i)
+			}
+		}()
+	}
+	run(func(i uint) { a.Add(i % 300); a.Remove((i + 7) % 300) })
+	run(func(i uint) { b.Add(i % 500); b.Remove((i + 3) % 500) })
+	run(func(uint) { a.Union(b); b.Intersection(a) })
+	run(func(uint) { a.IsSubsetOf(b); b.Equal(a) })
+	run(func(uint) { a.UnionWith(b); b.SubtractWith(a) })
+	run(func(uint) { b.IntersectWith(a); a.SymmetricDifference(b) })
+	wg.Wait()
+
+	for _, s := range []*BitSet{a, b} {
+		require.Equal(t, len(s.ToSlice()), s.Count())
+	}
+}
+
+func TestBitSet_WordsFor(t *testing.T) {
+	require.Equal(t, uint(0), wordsFor(0))
+	require.Equal(t, uint(1), wordsFor(1))
+	require.Equal(t, uint(1), wordsFor(64))
+	require.Equal(t, uint(2), wordsFor(65))
+	// does not overflow near the top of the range
+	require.Equal(t, uint(math.MaxUint/64+1), wordsFor(math.MaxUint))
+}
+
+func TestBitSet_Functional(t *testing.T) {
+	b := NewBitSetWithValues(1, 2, 3, 64, 65, 200)
+	even := func(x uint) bool { return x%2 == 0 }
+
+	require.Equal(t, []uint{2, 64, 200}, b.Filter(even).ToSlice())
+	matched, rest := b.Partition(even)
+	require.Equal(t, []uint{2, 64, 200}, matched.ToSlice())
+	require.Equal(t, []uint{1, 3, 65}, rest.ToSlice())
+	require.True(t, b.Any(even))
+	require.False(t, b.Every(even))
+	require.True(t, matched.Every(even))
+	require.True(t, NewBitSet().Every(even))
+	require.False(t, NewBitSet().Any(even))
+
+	v, ok := b.Find(func(x uint) bool { return x > 3 })
+	require.True(t, ok)
+	require.Equal(t, uint(64), v)
+	_, ok = b.Find(func(x uint) bool { return x > 1000 })
+	require.False(t, ok)
+
+	require.Equal(t, 2, b.RemoveIf(func(x uint) bool { return x >= 65 }))
+	require.Equal(t, []uint{1, 2, 3, 64}, b.ToSlice())
+	require.Len(t, b.words, 2)
+	require.Equal(t, 3, b.RetainIf(func(x uint) bool { return x < 2 }))
+	require.Equal(t, []uint{1}, b.ToSlice())
+	require.Equal(t, 1, b.Count())
+}
+
+func TestBitSet_PopAndSample(t *testing.T) {
+	b := NewBitSetWithValues(5, 70, 300)
+	b.SetRandSource(rand.NewPCG(1, 2))
+
+	v, ok := b.RandomElement()
+	require.True(t, ok)
+	require.True(t, b.Contains(v))
+
+	sample := b.Sample(2)
+	require.Len(t, sample, 2)
+	require.True(t, slices.IsSorted(sample))
+	require.True(t, NewBitSetWithValues(sample...).IsSubsetOf(b))
+	require.Equal(t, []uint{5, 70, 300}, b.Sample(10))
+	require.Empty(t, b.Sample(-1))
+	require.Equal(t, 3, b.Count())
+
+	popped := b.PopN(2)
+	require.Len(t, popped, 2)
+	require.Equal(t, 1, b.Count())
+	last, ok := b.Pop()
+	require.True(t, ok)
+	require.ElementsMatch(t, []uint{5, 70, 300}, append(popped, last))
+	require.True(t, b.IsEmpty())
+	require.Empty(t, b.words)
+
+	_, ok = b.Pop()
+	require.False(t, ok)
+	_, ok = b.RandomElement()
+	require.False(t, ok)
+}
+
+func TestBitSet_SampleUniform(t *testing.T) {
+	b := NewBitSetWithValues(0, 1, 100, 200, 1000)
+	b.SetRandSource(rand.NewPCG(3, 4))
+
+	const rounds = 10000
+	sampled := make(map[uint]int)
+	drawn := make(map[uint]int)
+	for range rounds {
+		for _, item := range b.Sample(2) {
+			sampled[item]++
+		}
+		v, _ := b.RandomElement()
+		drawn[v]++
+	}
+	for _, item := range b.ToSlice() {
+		require.InDelta(t, rounds*2/5, sampled[item], rounds/20)
+		require.InDelta(t, rounds/5, drawn[item], rounds/20)
+	}
+}
@@ -0,0 +1,11 @@
+// Package safeset provides thread-safe sets: Set, SortedSet, HashSet, BitSet
+// and Bag.
+//
+// # Iteration
+//
+// The All methods of the set types, and SortedSet.Range, copy the elements
+// when iteration starts. The sequence reflects one consistent state of the
+// set, no lock is held while the loop body runs, and the body may freely read
+// or modify the set. Set.AllLocked iterates without the copy instead, holding
+// the read lock for the whole loop.
+package safeset
@@ -0,0 +1,230 @@
+package safeset
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrHashSetUninitialized is returned when decoding into a HashSet that was not
+// created with NewHashSet, since it has no hash or equality function
+var ErrHashSetUninitialized = errors.New("hash set has no hash or equal function")
+
+// ErrBitSetElementTooLarge is returned when decoding into a BitSet an integer
+// larger than MaxBitSetElement
+var ErrBitSetElementTooLarge = errors.New("bit set element exceeds MaxBitSetElement")
+
+var (
+	_ json.Marshaler             = (*Set[int])(nil)
+	_ json.Unmarshaler           = (*Set[int])(nil)
+	_ gob.GobEncoder             = (*Set[int])(nil)
+	_ gob.GobDecoder             = (*Set[int])(nil)
+	_ encoding.BinaryMarshaler   = (*Set[int])(nil)
+	_ encoding.BinaryUnmarshaler = (*Set[int])(nil)
+)
+
+// MarshalJSON implements json.Marshaler. The set is encoded as a JSON array
+func (s *Set[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.ToSlice())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It decodes a JSON array and
+// atomically replaces the elements of the set
+func (s *Set[T]) UnmarshalJSON(data []byte) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		return nil
+	}
+	var items []T
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	s.replace(items)
+	return nil
+}
+
+// GobEncode implements gob.GobEncoder
+func (s *Set[T]) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s.ToSlice()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode implements gob.GobDecoder. It atomically replaces the elements of the set
+func (s *Set[T]) GobDecode(data []byte) error {
+	var items []T
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&items); err != nil {
+		return err
+	}
+	s.replace(items)
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler using the gob encoding
+func (s *Set[T]) MarshalBinary() ([]byte, error) {
+	return s.GobEncode()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler using the gob encoding
+func (s *Set[T]) UnmarshalBinary(data []byte) error {
+	return s.GobDecode(data)
+}
+
+// replace atomically replaces the elements of the set with items
+func (s *Set[T]) replace(items []T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reset(len(items))
+	for _, item := range items {
+		s.add(item)
+	}
+}
+
+var (
+	_ json.Marshaler             = (*BitSet)(nil)
+	_ json.Unmarshaler           = (*BitSet)(nil)
+	_ gob.GobEncoder             = (*BitSet)(nil)
+	_ gob.GobDecoder             = (*BitSet)(nil)
+	_ encoding.BinaryMarshaler   = (*BitSet)(nil)
+	_ encoding.BinaryUnmarshaler = (*BitSet)(nil)
+)
+
+// MarshalJSON implements json.Marshaler. The set is encoded as a JSON array in
+// ascending order, like a Set[uint]
+func (b *BitSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.ToSlice())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It decodes a JSON array and
+// atomically replaces the elements of the set
+func (b *BitSet) UnmarshalJSON(data []byte) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		return nil
+	}
+	var items []uint
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	return b.replace(items)
+}
+
+// GobEncode implements gob.GobEncoder
+func (b *BitSet) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(b.ToSlice()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode implements gob.GobDecoder. It atomically replaces the elements of the set
+func (b *BitSet) GobDecode(data []byte) error {
+	var items []uint
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&items); err != nil {
+		return err
+	}
+	return b.replace(items)
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler using the gob encoding
+func (b *BitSet) MarshalBinary() ([]byte, error) {
+	return b.GobEncode()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler using the gob encoding
+func (b *BitSet) UnmarshalBinary(data []byte) error {
+	return b.GobDecode(data)
+}
+
+// replace atomically replaces the elements of the set with items. It leaves the
+// set unchanged if an item is larger than MaxBitSetElement
+func (b *BitSet) replace(items []uint) error {
+	for _, item := range items {
+		if item > MaxBitSetElement {
+			return fmt.Errorf("%w: %d", ErrBitSetElementTooLarge, item)
+		}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.words, b.count = nil, 0
+	for _, item := range items {
+		b.add(item)
+	}
+	return nil
+}
+
+var (
+	_ json.Marshaler             = (*HashSet[int])(nil)
+	_ json.Unmarshaler           = (*HashSet[int])(nil)
+	_ gob.GobEncoder             = (*HashSet[int])(nil)
+	_ gob.GobDecoder             = (*HashSet[int])(nil)
+	_ encoding.BinaryMarshaler   = (*HashSet[int])(nil)
+	_ encoding.BinaryUnmarshaler = (*HashSet[int])(nil)
+)
+
+// MarshalJSON implements json.Marshaler. The set is encoded as a JSON array
+func (s *HashSet[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.ToSlice())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It decodes a JSON array and
+// atomically replaces the elements of the set, merging equal elements.
+// The set must have been created with NewHashSet
+func (s *HashSet[T]) UnmarshalJSON(data []byte) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		return nil
+	}
+	var items []T
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	return s.replace(items)
+}
+
+// GobEncode implements gob.GobEncoder
+func (s *HashSet[T]) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s.ToSlice()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode implements gob.GobDecoder. It atomically replaces the elements of the set.
+// The set must have been created with NewHashSet
+func (s *HashSet[T]) GobDecode(data []byte) error {
+	var items []T
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&items); err != nil {
+		return err
+	}
+	return s.replace(items)
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler using the gob encoding
+func (s *HashSet[T]) MarshalBinary() ([]byte, error) {
+	return s.GobEncode()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler using the gob encoding
+func (s *HashSet[T]) UnmarshalBinary(data []byte) error {
+	return s.GobDecode(data)
+}
+
+// replace atomically replaces the elements of the set with items. It returns
+// ErrHashSetUninitialized if the set has no hash or equality function
+func (s *HashSet[T]) replace(items []T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.hash == nil || s.equal == nil {
+		return ErrHashSetUninitialized
+	}
+	s.reset(len(items))
+	for _, item := range items {
+		s.add(item)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package safeset
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSet_MarshalJSON(t *testing.T) {
+	s := NewSetWithValues[int](1, 2, 3)
+	data, err := json.Marshal(s)
+	require.NoError(t, err)
+
+	var items []int
+	require.NoError(t, json.Unmarshal(data, &items))
+	require.ElementsMatch(t, []int{1, 2, 3}, items)
+
+	data, err = json.Marshal(NewSet[string]())
+	require.NoError(t, err)
+	require.Equal(t, "[]", string(data))
+}
+
+func TestSet_UnmarshalJSON(t *testing.T) {
+	s := NewSetWithValues[string]("old")
+	require.NoError(t, json.Unmarshal([]byte(`["a","b","a"]`), s))
+	require.ElementsMatch(t, []string{"a", "b"}, s.ToSlice())
+
+	require.Error(t, json.Unmarshal([]byte(`{"a":1}`), s))
+	require.ElementsMatch(t, []string{"a", "b"}, s.ToSlice())
+}
+
+func TestSet_JSONEmbedded(t *testing.T) {
+	type user struct {
+		Name  string
+		Roles *Set[string]
+	}
+	in := user{Name: "alice", Roles: NewSetWithValues[string]("admin")}
+	data, err := json.Marshal(in)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"Name":"alice","Roles":["admin"]}`, string(data))
+
+	var out user
+	require.NoError(t, json.Unmarshal(data, &out))
+	require.True(t, out.Roles.Contains("admin"))
+	out.Roles.Add("dev")
+	require.Equal(t, 2, out.Roles.Size())
+}
+
+func TestSet_Gob(t *testing.T) {
+	type state struct {
+		IDs *Set[int]
+	}
+	in := state{IDs: NewSetWithValues[int](1, 2, 3)}
+	var buf bytes.Buffer
+	require.NoError(t, gob.NewEncoder(&buf).Encode(in))
+
+	var out state
+	require.NoError(t, gob.NewDecoder(&buf).Decode(&out))
+	require.True(t, in.IDs.Equal(out.IDs))
+}
+
+func TestSet_Binary(t *testing.T) {
+	s := NewSetWithValues[string]("a", "b")
+	data, err := s.MarshalBinary()
+	require.NoError(t, err)
+
+	decoded := NewSetWithValues[string]("c")
+	require.NoError(t, decoded.UnmarshalBinary(data))
+	require.True(t, s.Equal(decoded))
+}
+
+func TestBitSet_JSON(t *testing.T) {
+	data, err := json.Marshal(NewBitSetWithValues(70, 3, 1))
+	require.NoError(t, err)
+	require.Equal(t, "[1,3,70]", string(data))
+
+	b := NewBitSetWithValues(500)
+	require.NoError(t, json.Unmarshal([]byte(`[2, 2, 64]`), b))
+	require.Equal(t, []uint{2, 64}, b.ToSlice())
+	require.Equal(t, 2, b.Count())
+	require.Error(t, json.Unmarshal([]byte(`[-1]`), b))
+	require.Equal(t, []uint{2, 64}, b.ToSlice())
+
+	var out struct{ Flags *BitSet }
+	require.NoError(t, json.Unmarshal([]byte(`{"Flags":[0,9]}`), &out))
+	require.Equal(t, []uint{0, 9}, out.Flags.ToSlice())
+}
+
+func TestBitSet_GobBinary(t *testing.T) {
+	in := NewBitSetWithValues(0, 63, 64, 1000)
+	var buf bytes.Buffer
+	require.NoError(t, gob.NewEncoder(&buf).Encode(struct{ Shards *BitSet }{in}))
+	var out struct{ Shards *BitSet }
+	require.NoError(t, gob.NewDecoder(&buf).Decode(&out))
+	require.True(t, in.Equal(out.Shards))
+
+	data, err := in.MarshalBinary()
+	require.NoError(t, err)
+	decoded := NewBitSet()
+	require.NoError(t, decoded.UnmarshalBinary(data))
+	require.True(t, in.Equal(decoded))
+}
+
+func TestHashSet_JSON(t *testing.T) {
+	data, err := json.Marshal(NewHashSetWithValues(sliceHash, slices.Equal, []int{1, 2}))
+	require.NoError(t, err)
+	require.Equal(t, "[[1,2]]", string(data))
+
+	s := newFoldSet("old")
+	require.NoError(t, json.Unmarshal([]byte(`["Go", "GO", "rust"]`), s))
+	require.Equal(t, 2, s.Size())
+	require.True(t, s.Contains("go"))
+	require.False(t, s.Contains("old"))
+	require.Error(t, json.Unmarshal([]byte(`[1]`), s))
+	require.Equal(t, 2, s.Size())
+
+	var out struct{ Tags *HashSet[string] }
+	require.ErrorIs(t, json.Unmarshal([]byte(`{"Tags":["a"]}`), &out), ErrHashSetUninitialized)
+
+	out.Tags = newFoldSet()
+	require.NoError(t, json.Unmarshal([]byte(`{"Tags":["a","A"]}`), &out))
+	require.Equal(t, []string{"a"}, out.Tags.ToSlice())
+}
+
+func TestHashSet_GobBinary(t *testing.T) {
+	in := NewHashSetWithValues(sliceHash, slices.Equal, []int{1}, []int{2, 3}, nil)
+	var buf bytes.Buffer
+	require.NoError(t, gob.NewEncoder(&buf).Encode(struct{ Rows *HashSet[[]int] }{in}))
+	out := struct{ Rows *HashSet[[]int] }{NewHashSet(sliceHash, slices.Equal)}
+	require.NoError(t, gob.NewDecoder(&buf).Decode(&out))
+	require.True(t, in.Equal(out.Rows))
+
+	data, err := in.MarshalBinary()
+	require.NoError(t, err)
+	decoded := NewHashSet(sliceHash, slices.Equal)
+	require.NoError(t, decoded.UnmarshalBinary(data))
+	require.True(t, in.Equal(decoded))
+	require.ErrorIs(t, new(HashSet[[]int]).UnmarshalBinary(data), ErrHashSetUninitialized)
+}
@@ -0,0 +1,126 @@
+package safeset
+
+// The functions in this file run under a single lock acquisition, so their
+// results reflect one consistent state of the set. The callbacks must not
+// call any method on the set, not even a read such as Contains or Size
+// (that deadlocks).
+
+// Filter returns a new set with the elements for which fn returns true
+func (s *Set[T]) Filter(fn func(T) bool) *Set[T] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := NewSet[T]()
+	for item := range s.items {
+		if fn(item) {
+			result.add(item)
+		}
+	}
+	return result
+}
+
+// Partition splits the set into a new set with the elements for which fn
+// returns true and a new set with the rest
+func (s *Set[T]) Partition(fn func(T) bool) (matched, rest *Set[T]) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matched, rest = NewSet[T](), NewSet[T]()
+	for item := range s.items {
+		if fn(item) {
+			matched.add(item)
+		} else {
+			rest.add(item)
+		}
+	}
+	return matched, rest
+}
+
+// Any returns true if fn returns true for at least one element
+func (s *Set[T]) Any(fn func(T) bool) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for item := range s.items {
+		if fn(item) {
+			return true
+		}
+	}
+	return false
+}
+
+// Every returns true if fn returns true for every element. It is true for an empty set
+func (s *Set[T]) Every(fn func(T) bool) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for item := range s.items {
+		if !fn(item) {
+			return false
+		}
+	}
+	return true
+}
+
+// Find returns an element for which fn returns true, and false if there is none.
+// If several elements match, which one is returned is unspecified
+func (s *Set[T]) Find(fn func(T) bool) (T, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for item := range s.items {
+		if fn(item) {
+			return item, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// RemoveIf removes the elements for which fn returns true and returns how many were removed
+func (s *Set[T]) RemoveIf(fn func(T) bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for item := range s.items {
+		if fn(item) {
+			s.remove(item)
+			removed++
+		}
+	}
+	return removed
+}
+
+// RetainIf keeps only the elements for which fn returns true and returns how many were removed
+func (s *Set[T]) RetainIf(fn func(T) bool) int {
+	return s.RemoveIf(func(item T) bool {
+		return !fn(item)
+	})
+}
+
+// MapSet returns a new set with the results of applying fn to every element of s.
+// Elements that map to the same value are merged, so the result may be smaller than s
+func MapSet[T, U comparable](s *Set[T], fn func(T) U) *Set[U] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := NewSet[U]()
+	for item := range s.items {
+		result.add(fn(item))
+	}
+	return result
+}
+
+// ReduceSet folds the elements of s into an accumulator, starting from initial.
+// The elements are visited in no particular order, so fn should not depend on it
+func ReduceSet[T comparable, A any](s *Set[T], initial A, fn func(A, T) A) A {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	acc := initial
+	for item := range s.items {
+		acc = fn(acc, item)
+	}
+	return acc
+}
@@ -0,0 +1,80 @@
+package safeset
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func isEven(x int) bool { return x%2 == 0 }
+
+func TestSet_Filter(t *testing.T) {
+	s := NewSetWithValues(1, 2, 3, 4, 5)
+	require.ElementsMatch(t, []int{2, 4}, s.Filter(isEven).ToSlice())
+	require.Equal(t, 5, s.Size())
+	require.True(t, NewSet[int]().Filter(isEven).IsEmpty())
+}
+
+func TestSet_Partition(t *testing.T) {
+	s := NewSetWithValues(1, 2, 3, 4, 5)
+	even, odd := s.Partition(isEven)
+	require.ElementsMatch(t, []int{2, 4}, even.ToSlice())
+	require.ElementsMatch(t, []int{1, 3, 5}, odd.ToSlice())
+}
+
+func TestSet_AnyEvery(t *testing.T) {
+	s := NewSetWithValues(2, 4, 5)
+	require.True(t, s.Any(isEven))
+	require.False(t, s.Every(isEven))
+	s.Remove(5)
+	require.True(t, s.Every(isEven))
+
+	empty := NewSet[int]()
+	require.False(t, empty.Any(isEven))
+	require.True(t, empty.Every(isEven))
+}
+
+func TestSet_Find(t *testing.T) {
+	s := NewSetWithValues(1, 3, 4)
+	v, ok := s.Find(isEven)
+	require.True(t, ok)
+	require.Equal(t, 4, v)
+
+	s.Remove(4)
+	v, ok = s.Find(isEven)
+	require.False(t, ok)
+	require.Zero(t, v)
+}
+
+func TestSet_RemoveIfRetainIf(t *testing.T) {
+	s := NewSetWithValues(1, 2, 3, 4, 5, 6)
+	require.Equal(t, 3, s.RemoveIf(isEven))
+	require.ElementsMatch(t, []int{1, 3, 5}, s.ToSlice())
+
+	require.Equal(t, 2, s.RetainIf(func(x int) bool { return x > 4 }))
+	require.ElementsMatch(t, []int{5}, s.ToSlice())
+	require.Zero(t, s.RemoveIf(isEven))
+}
+
+func TestMapSet(t *testing.T) {
+	s := NewSetWithValues(1, 2, 3, 4)
+	strs := MapSet(s, strconv.Itoa)
+	require.ElementsMatch(t, []string{"1", "2", "3", "4"}, strs.ToSlice())
+
+	parity := MapSet(s, isEven)
+	require.ElementsMatch(t, []bool{true, false}, parity.ToSlice())
+}
+
+func TestReduceSet(t *testing.T) {
+	s := NewSetWithValues(1, 2, 3, 4)
+	sum := ReduceSet(s, 0, func(acc, x int) int { return acc + x })
+	require.Equal(t, 10, sum)
+
+	lengths := ReduceSet(NewSetWithValues("a", "bb", "ccc"), map[int]bool{}, func(acc map[int]bool, x string) map[int]bool {
+		acc[len(x)] = true
+		return acc
+	})
+	require.Equal(t, map[int]bool{1: true, 2: true, 3: true}, lengths)
+	require.Equal(t, 7, ReduceSet(NewSet[int](), 7, func(acc, x int) int { return acc + x }))
+}
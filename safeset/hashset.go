@@ -0,0 +1,540 @@
+package safeset
+
+import (
+	"fmt"
+	"iter"
+	"math/rand/v2"
+	"slices"
+	"strings"
+)
+
+// HashSet is a thread-safe set of elements of any type, including slices, maps
+// and structs containing them. Elements are compared with the equal function
+// given to NewHashSet and bucketed by the hash function, which must return the
+// same value for equal elements. For example, a case-insensitive string set can
+// hash with maphash.String(seed, strings.ToLower(s)) and compare with strings.EqualFold.
+//
+// Elements must not be modified while they are in the set.
+// Operations on two sets use the hash and equal functions of each set for its
+// own elements and return sets with the functions of the receiver.
+type HashSet[T any] struct {
+	setLock
+	hash    func(T) uint64
+	equal   func(a, b T) bool
+	buckets map[uint64][]int // indexes into elems of the elements with each hash
+	elems   []T
+	hashes  []uint64 // hash of each element of elems
+	rng     *lockedRand
+}
+
+// NewHashSet creates and returns a new HashSet with the given hash and equality functions
+func NewHashSet[T any](hash func(T) uint64, equal func(a, b T) bool) *HashSet[T] {
+	return &HashSet[T]{
+		hash:    hash,
+		equal:   equal,
+		buckets: make(map[uint64][]int),
+	}
+}
+
+// NewHashSetWithValues creates and returns a new HashSet with the given
+// hash and equality functions and values
+func NewHashSetWithValues[T any](hash func(T) uint64, equal func(a, b T) bool, values ...T) *HashSet[T] {
+	s := NewHashSet(hash, equal)
+	for _, value := range values {
+		s.add(value)
+	}
+	return s
+}
+
+// Add adds an element to the set. If an equal element is already in the set,
+// the set is left unchanged
+func (s *HashSet[T]) Add(item T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.add(item)
+}
+
+// AddWithCheck adds an element to the set and returns true if an equal element was already in the set
+func (s *HashSet[T]) AddWithCheck(item T) (existed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.add(item)
+}
+
+// Remove removes the element equal to item from the set
+func (s *HashSet[T]) Remove(item T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(item)
+}
+
+// Contains checks if an element equal to item is in the set
+func (s *HashSet[T]) Contains(item T) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.has(item)
+}
+
+// Size returns the number of elements in the set
+func (s *HashSet[T]) Size() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.elems)
+}
+
+// Clear removes all elements from the set
+func (s *HashSet[T]) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reset(0)
+}
+
+// IsEmpty returns true if the set is empty
+func (s *HashSet[T]) IsEmpty() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.elems) == 0
+}
+
+// ToSlice returns a slice containing all elements in the set
+func (s *HashSet[T]) ToSlice() []T {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append(make([]T, 0, len(s.elems)), s.elems...)
+}
+
+// All returns an iterator over the elements of the set, in no particular order
+func (s *HashSet[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for _, item := range s.ToSlice() {
+			if !yield(item) {
+				return
+			}
+		}
+	}
+}
+
+// Union returns a new set that is the union of s and other
+func (s *HashSet[T]) Union(other *HashSet[T]) *HashSet[T] {
+	defer lockSets(nil, s, other)()
+
+	unionSet := s.cloneLocked()
+	for item := range other.all {
+		unionSet.add(item)
+	}
+	return unionSet
+}
+
+// Intersection returns a new set that is the intersection of s and other
+func (s *HashSet[T]) Intersection(other *HashSet[T]) *HashSet[T] {
+	defer lockSets(nil, s, other)()
+
+	intersectionSet := NewHashSet(s.hash, s.equal)
+	for item := range s.all {
+		if other.has(item) {
+			intersectionSet.add(item)
+		}
+	}
+	return intersectionSet
+}
+
+// Difference returns a new set that is the difference of s and other
+func (s *HashSet[T]) Difference(other *HashSet[T]) *HashSet[T] {
+	defer lockSets(nil, s, other)()
+
+	differenceSet := NewHashSet(s.hash, s.equal)
+	for item := range s.all {
+		if !other.has(item) {
+			differenceSet.add(item)
+		}
+	}
+	return differenceSet
+}
+
+// SymmetricDifference returns a new set that is the symmetric difference (XOR) of s and other
+func (s *HashSet[T]) SymmetricDifference(other *HashSet[T]) *HashSet[T] {
+	defer lockSets(nil, s, other)()
+
+	xorSet := NewHashSet(s.hash, s.equal)
+	for item := range s.all {
+		if !other.has(item) {
+			xorSet.add(item)
+		}
+	}
+	for item := range other.all {
+		if !s.has(item) {
+			xorSet.add(item)
+		}
+	}
+	return xorSet
+}
+
+// UnionWith adds the elements of all the given sets to s
+func (s *HashSet[T]) UnionWith(others ...*HashSet[T]) {
+	defer lockSets(s, others...)()
+
+	for _, other := range others {
+		if other == s {
+			continue
+		}
+		for item := range other.all {
+			s.add(item)
+		}
+	}
+}
+
+// IntersectWith removes the elements of s that are not in all of the given sets
+func (s *HashSet[T]) IntersectWith(others ...*HashSet[T]) {
+	defer lockSets(s, others...)()
+
+	s.removeIf(func(item T) bool {
+		for _, other := range others {
+			if !other.has(item) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// SubtractWith removes the elements of all the given sets from s
+func (s *HashSet[T]) SubtractWith(others ...*HashSet[T]) {
+	defer lockSets(s, others...)()
+
+	s.removeIf(func(item T) bool {
+		for _, other := range others {
+			if other.has(item) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// IsSubsetOf returns true if s is a subset of other
+func (s *HashSet[T]) IsSubsetOf(other *HashSet[T]) bool {
+	defer lockSets(nil, s, other)()
+	return s.isSubsetLocked(other)
+}
+
+// IsSupersetOf returns true if s is a superset of other
+func (s *HashSet[T]) IsSupersetOf(other *HashSet[T]) bool {
+	return other.IsSubsetOf(s)
+}
+
+// Equal returns true if s and other contain the same elements
+func (s *HashSet[T]) Equal(other *HashSet[T]) bool {
+	defer lockSets(nil, s, other)()
+	return len(s.elems) == len(other.elems) && s.isSubsetLocked(other)
+}
+
+// Clone returns a new set with the same elements and functions as s
+func (s *HashSet[T]) Clone() *HashSet[T] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cloneLocked()
+}
+
+// String returns a string representation of the set
+func (s *HashSet[T]) String() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var sb strings.Builder
+	sb.WriteByte('{')
+	i := 0
+	for item := range s.all {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		fmt.Fprintf(&sb, "%v", item)
+		i++
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// Filter returns a new set with the elements for which fn returns true.
+// Like the other functional methods, it runs under a single lock acquisition
+// and fn must not call any method on the set, not even a read (that deadlocks)
+func (s *HashSet[T]) Filter(fn func(T) bool) *HashSet[T] {
+	matched, _ := s.Partition(fn)
+	return matched
+}
+
+// Partition splits the set into a new set with the elements for which fn
+// returns true and a new set with the rest
+func (s *HashSet[T]) Partition(fn func(T) bool) (matched, rest *HashSet[T]) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matched, rest = NewHashSet(s.hash, s.equal), NewHashSet(s.hash, s.equal)
+	for i, item := range s.elems {
+		if fn(item) {
+			matched.addHashed(item, s.hashes[i])
+		} else {
+			rest.addHashed(item, s.hashes[i])
+		}
+	}
+	return matched, rest
+}
+
+// Any returns true if fn returns true for at least one element
+func (s *HashSet[T]) Any(fn func(T) bool) bool {
+	_, ok := s.Find(fn)
+	return ok
+}
+
+// Every returns true if fn returns true for every element. It is true for an empty set
+func (s *HashSet[T]) Every(fn func(T) bool) bool {
+	_, ok := s.Find(func(item T) bool { return !fn(item) })
+	return !ok
+}
+
+// Find returns an element for which fn returns true, and false if there is none.
+// If several elements match, which one is returned is unspecified
+func (s *HashSet[T]) Find(fn func(T) bool) (T, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for item := range s.all {
+		if fn(item) {
+			return item, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// RemoveIf removes the elements for which fn returns true and returns how many were removed
+func (s *HashSet[T]) RemoveIf(fn func(T) bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.removeIf(fn)
+}
+
+// RetainIf keeps only the elements for which fn returns true and returns how many were removed
+func (s *HashSet[T]) RetainIf(fn func(T) bool) int {
+	return s.RemoveIf(func(item T) bool {
+		return !fn(item)
+	})
+}
+
+// MapHashSet returns a new set with the results of applying fn to every element
+// of s, hashed and compared with the given functions. Elements that map to equal
+// values are merged, so the result may be smaller than s
+func MapHashSet[T, U any](s *HashSet[T], hash func(U) uint64, equal func(a, b U) bool, fn func(T) U) *HashSet[U] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := NewHashSet(hash, equal)
+	for item := range s.all {
+		result.add(fn(item))
+	}
+	return result
+}
+
+// ReduceHashSet folds the elements of s into an accumulator, starting from initial.
+// The elements are visited in no particular order, so fn should not depend on it
+func ReduceHashSet[T, A any](s *HashSet[T], initial A, fn func(A, T) A) A {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	acc := initial
+	for item := range s.all {
+		acc = fn(acc, item)
+	}
+	return acc
+}
+
+// SetRandSource sets the source of randomness used by Pop, PopN, RandomElement
+// and Sample, like Set.SetRandSource
+func (s *HashSet[T]) SetRandSource(src rand.Source) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rng = newLockedRand(src)
+}
+
+// Pop removes and returns a random element of the set, and false if the set is empty
+func (s *HashSet[T]) Pop() (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.elems) == 0 {
+		var zero T
+		return zero, false
+	}
+	i := s.rng.intN(len(s.elems))
+	item := s.elems[i]
+	s.removeAt(i)
+	return item, true
+}
+
+// PopN removes and returns up to n random elements of the set. It takes O(n) time
+func (s *HashSet[T]) PopN(n int) []T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n = min(max(n, 0), len(s.elems))
+	popped := make([]T, 0, n)
+	for range n {
+		i := s.rng.intN(len(s.elems))
+		popped = append(popped, s.elems[i])
+		s.removeAt(i)
+	}
+	return popped
+}
+
+// RandomElement returns a random element of the set without removing it,
+// and false if the set is empty
+func (s *HashSet[T]) RandomElement() (T, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.elems) == 0 {
+		var zero T
+		return zero, false
+	}
+	return s.elems[s.rng.intN(len(s.elems))], true
+}
+
+// Sample returns k distinct elements chosen uniformly at random, in no
+// particular order. If k is at least the size of the set, all elements are
+// returned. It takes O(k) time and does not modify the set
+func (s *HashSet[T]) Sample(k int) []T {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sampleElems(s.elems, k, s.rng)
+}
+
+// index returns the hash of item and the index in elems of the element equal
+// to it, or -1 if there is none. The caller must hold a lock
+func (s *HashSet[T]) index(item T) (uint64, int) {
+	h := s.hash(item)
+	for _, i := range s.buckets[h] {
+		if s.equal(s.elems[i], item) {
+			return h, i
+		}
+	}
+	return h, -1
+}
+
+// has returns true if an element equal to item is in the set.
+// The caller must hold a lock
+func (s *HashSet[T]) has(item T) bool {
+	_, i := s.index(item)
+	return i >= 0
+}
+
+// add adds an element and returns true if no equal element was in the set yet.
+// The caller must hold the write lock
+func (s *HashSet[T]) add(item T) bool {
+	h, i := s.index(item)
+	if i >= 0 {
+		return false
+	}
+	s.addHashed(item, h)
+	return true
+}
+
+// addHashed adds an element with hash h that is known not to be in the set.
+// The caller must hold the write lock
+func (s *HashSet[T]) addHashed(item T, h uint64) {
+	s.buckets[h] = append(s.buckets[h], len(s.elems))
+	s.elems = append(s.elems, item)
+	s.hashes = append(s.hashes, h)
+}
+
+// remove removes the element equal to item and returns true if there was one.
+// The caller must hold the write lock
+func (s *HashSet[T]) remove(item T) bool {
+	_, i := s.index(item)
+	if i < 0 {
+		return false
+	}
+	s.removeAt(i)
+	return true
+}
+
+// removeAt removes elems[i]. The last element takes its place, so removal is
+// O(1) plus the size of two buckets. The caller must hold the write lock
+func (s *HashSet[T]) removeAt(i int) {
+	last := len(s.elems) - 1
+	s.replaceIndex(s.hashes[i], i, -1)
+	if i != last {
+		s.replaceIndex(s.hashes[last], last, i)
+		s.elems[i], s.hashes[i] = s.elems[last], s.hashes[last]
+	}
+	var zero T
+	s.elems[last] = zero
+	s.elems, s.hashes = s.elems[:last], s.hashes[:last]
+}
+
+// replaceIndex replaces index old by new in bucket h, or removes it if new is
+// negative. The caller must hold the write lock
+func (s *HashSet[T]) replaceIndex(h uint64, old, new int) {
+	bucket := s.buckets[h]
+	j := slices.Index(bucket, old)
+	switch {
+	case new >= 0:
+		bucket[j] = new
+	case len(bucket) == 1:
+		delete(s.buckets, h)
+	default:
+		bucket[j] = bucket[len(bucket)-1]
+		s.buckets[h] = bucket[:len(bucket)-1]
+	}
+}
+
+// removeIf removes the elements for which fn returns true and returns how many
+// were removed. The caller must hold the write lock
+func (s *HashSet[T]) removeIf(fn func(T) bool) int {
+	removed := 0
+	// going backwards, removeAt only moves elements that were already visited
+	for i := len(s.elems) - 1; i >= 0; i-- {
+		if fn(s.elems[i]) {
+			s.removeAt(i)
+			removed++
+		}
+	}
+	return removed
+}
+
+// reset removes all elements, making room for capacity new ones.
+// The caller must hold the write lock
+func (s *HashSet[T]) reset(capacity int) {
+	s.buckets = make(map[uint64][]int, capacity)
+	s.elems = make([]T, 0, capacity)
+	s.hashes = make([]uint64, 0, capacity)
+}
+
+// isSubsetLocked returns true if every element of s is in other.
+// The caller must hold the locks of both sets
+func (s *HashSet[T]) isSubsetLocked(other *HashSet[T]) bool {
+	if len(s.elems) > len(other.elems) {
+		return false
+	}
+	for _, item := range s.elems {
+		if !other.has(item) {
+			return false
+		}
+	}
+	return true
+}
+
+// cloneLocked returns a copy of the set. The caller must hold a lock
+func (s *HashSet[T]) cloneLocked() *HashSet[T] {
+	c := NewHashSet(s.hash, s.equal)
+	for h, bucket := range s.buckets {
+		c.buckets[h] = slices.Clone(bucket)
+	}
+	c.elems = slices.Clone(s.elems)
+	c.hashes = slices.Clone(s.hashes)
+	return c
+}
+
+// all yields the elements of the set. The caller must hold a lock
+func (s *HashSet[T]) all(yield func(T) bool) {
+	for _, item := range s.elems {
+		if !yield(item) {
+			return
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package safeset
+
+import (
+	"hash/maphash"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testSeed = maphash.MakeSeed()
+
+func foldHash(s string) uint64 {
+	return maphash.String(testSeed, strings.ToLower(s))
+}
+
+func sliceHash(s []int) uint64 {
+	var h maphash.Hash
+	h.SetSeed(testSeed)
+	for _, x := range s {
+		h.WriteString(strconv.Itoa(x))
+		h.WriteByte(',')
+	}
+	return h.Sum64()
+}
+
+func newFoldSet(values ...string) *HashSet[string] {
+	return NewHashSetWithValues(foldHash, strings.EqualFold, values...)
+}
+
+func TestHashSet_CaseInsensitive(t *testing.T) {
+	s := newFoldSet("Go", "Rust")
+	require.True(t, s.AddWithCheck("GO"))
+	require.False(t, s.AddWithCheck("zig"))
+	require.Equal(t, 3, s.Size())
+	require.True(t, s.Contains("rust"))
+	require.ElementsMatch(t, []string{"Go", "Rust", "zig"}, s.ToSlice())
+
+	s.Remove("ZIG")
+	require.False(t, s.Contains("zig"))
+	require.Equal(t, 2, s.Size())
+
+	s.Clear()
+	require.True(t, s.IsEmpty())
+}
+
+func TestHashSet_SliceElements(t *testing.T) {
+	s := NewHashSet(sliceHash, slices.Equal[[]int])
+	s.Add([]int{1, 2})
+	s.Add([]int{1, 2})
+	s.Add([]int{2, 1})
+	s.Add(nil)
+	require.Equal(t, 3, s.Size())
+	require.True(t, s.Contains([]int{2, 1}))
+	require.True(t, s.Contains([]int{}))
+	require.False(t, s.Contains([]int{1}))
+}
+
+func TestHashSet_Collisions(t *testing.T) {
+	s := NewHashSet(func(int) uint64 { return 0 }, func(a, b int) bool { return a == b })
+	for i := range 10 {
+		s.Add(i)
+	}
+	require.Equal(t, 10, s.Size())
+	require.Len(t, s.buckets, 1)
+
+	s.Remove(3)
+	s.Remove(9)
+	s.Remove(42)
+	require.Equal(t, 8, s.Size())
+	require.ElementsMatch(t, []int{0, 1, 2, 4, 5, 6, 7, 8}, s.ToSlice())
+
+	s.SubtractWith(NewHashSetWithValues(s.hash, s.equal, 0, 1, 2, 4, 5, 6, 7, 8))
+	require.True(t, s.IsEmpty())
+	require.Empty(t, s.buckets)
+}
+
+func TestHashSet_Algebra(t *testing.T) {
+	a := newFoldSet("a", "B", "c")
+	b := newFoldSet("b", "C", "d")
+
+	require.ElementsMatch(t, []string{"a", "B", "c", "d"}, a.Union(b).ToSlice())
+	require.ElementsMatch(t, []string{"B", "c"}, a.Intersection(b).ToSlice())
+	require.ElementsMatch(t, []string{"a"}, a.Difference(b).ToSlice())
+	require.ElementsMatch(t, []string{"a", "d"}, a.SymmetricDifference(b).ToSlice())
+	require.ElementsMatch(t, []string{"a", "B", "c"}, a.Union(a).ToSlice())
+
+	require.True(t, newFoldSet("A", "C").IsSubsetOf(a))
+	require.False(t, b.IsSubsetOf(a))
+	require.True(t, a.IsSupersetOf(newFoldSet("b")))
+	require.True(t, a.Equal(newFoldSet("C", "b", "A")))
+	require.False(t, a.Equal(b))
+
+	c := a.Clone()
+	c.Add("e")
+	require.False(t, a.Contains("e"))
+}
+
+func TestHashSet_InPlace(t *testing.T) {
+	s := newFoldSet("a", "b")
+	s.UnionWith(newFoldSet("B", "c"), s)
+	require.ElementsMatch(t, []string{"a", "b", "c"}, s.ToSlice())
+
+	s.IntersectWith(newFoldSet("A", "C", "x"), s)
+	require.ElementsMatch(t, []string{"a", "c"}, s.ToSlice())
+	require.Equal(t, 2, s.Size())
+
+	s.SubtractWith(newFoldSet("A"))
+	require.ElementsMatch(t, []string{"c"}, s.ToSlice())
+	s.SubtractWith(s)
+	require.True(t, s.IsEmpty())
+}
+
+func TestHashSet_AllString(t *testing.T) {
+	s := newFoldSet("x")
+	var items []string
+	for item := range s.All() {
+		items = append(items, item)
+		s.Add(strings.ToUpper(item) + "!") // the body may use the set
+	}
+	require.Equal(t, 2, s.Size())
+	s.Remove("X!")
+	require.Equal(t, []string{"x"}, items)
+	require.Equal(t, "{x}", s.String())
+	require.Equal(t, "{}", newFoldSet().String())
+}
+
+func TestHashSet_Concurrent(t *testing.T) {
+	a := newFoldSet("a", "b")
+	b := newFoldSet("b", "c")
+	letters := []string{"a", "B", "c", "D", "e"}
+
+	var wg sync.WaitGroup
+	run := func(fn func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				fn(i)
+			}
+		}()
+	}
+	run(func(i int) { a.Add(letters[i%5]); a.Remove(letters[(i+2)%5]) })
+	run(func(i int) { b.Add(letters[i%5]); b.Remove(letters[(i+3)%5]) })
+	run(func(int) { a.Union(b); b.Intersection(a) })
+	run(func(int) { a.IsSubsetOf(b); b.Equal(a) })
+	run(func(int) { a.UnionWith(b); b.SubtractWith(a) })
+	run(func(int) { b.IntersectWith(a); a.SymmetricDifference(b) })
+	wg.Wait()
+
+	for _, s := range []*HashSet[string]{a, b} {
+		require.Len(t, s.ToSlice(), s.Size())
+	}
+}
+
+func TestHashSet_RemoveKeepsIndex(t *testing.T) {
+	// every element shares a bucket, so removals move indexes inside it
+	s := NewHashSet(func(int) uint64 { return 0 }, func(a, b int) bool { return a == b })
+	for i := range 20 {
+		s.Add(i)
+	}
+	for i := 0; i < 20; i += 3 {
+		s.Remove(i)
+	}
+	require.Equal(t, 13, s.Size())
+	for i := range 20 {
+		require.Equal(t, i%3 != 0, s.Contains(i), i)
+	}
+	require.Len(t, s.buckets[0], 13)
+}
+
+func TestHashSet_Functional(t *testing.T) {
+	s := newFoldSet("Go", "Rust", "Zig", "C")
+	short := func(x string) bool { return len(x) <= 2 }
+
+	require.ElementsMatch(t, []string{"Go", "C"}, s.Filter(short).ToSlice())
+	matched, rest := s.Partition(short)
+	require.ElementsMatch(t, []string{"Go", "C"}, matched.ToSlice())
+	require.ElementsMatch(t, []string{"Rust", "Zig"}, rest.ToSlice())
+	require.True(t, matched.Contains("GO"))
+	require.True(t, s.Any(short))
+	require.False(t, s.Every(short))
+	require.True(t, matched.Every(short))
+	require.True(t, newFoldSet().Every(short))
+	require.False(t, newFoldSet().Any(short))
+
+	v, ok := s.Find(func(x string) bool { return strings.HasPrefix(x, "R") })
+	require.True(t, ok)
+	require.Equal(t, "Rust", v)
+	_, ok = s.Find(func(x string) bool { return x == "" })
+	require.False(t, ok)
+
+	lower := MapHashSet(s, foldHash, strings.EqualFold, strings.ToLower)
+	require.ElementsMatch(t, []string{"go", "rust", "zig", "c"}, lower.ToSlice())
+	merged := MapHashSet(s, sliceHash, slices.Equal, func(x string) []int { return []int{len(x) % 2} })
+	require.Equal(t, 2, merged.Size())
+	require.Equal(t, 10, ReduceHashSet(s, 0, func(acc int, x string) int { return acc + len(x) }))
+
+	require.Equal(t, 2, s.RemoveIf(short))
+	require.ElementsMatch(t, []string{"Rust", "Zig"}, s.ToSlice())
+	require.Equal(t, 1, s.RetainIf(func(x string) bool { return x == "zig" || x == "Zig" }))
+	require.ElementsMatch(t, []string{"Zig"}, s.ToSlice())
+	require.True(t, s.Contains("ZIG"))
+}
+
+func TestHashSet_PopAndSample(t *testing.T) {
+	s := NewHashSetWithValues(sliceHash, slices.Equal, []int{1}, []int{2, 3}, []int{4})
+	s.SetRandSource(rand.NewPCG(1, 2))
+
+	v, ok := s.RandomElement()
+	require.True(t, ok)
+	require.True(t, s.Contains(v))
+
+	sample := s.Sample(2)
+	require.Len(t, sample, 2)
+	require.True(t, NewHashSetWithValues(sliceHash, slices.Equal, sample...).IsSubsetOf(s))
+	require.Len(t, s.Sample(10), 3)
+	require.Empty(t, s.Sample(-1))
+	require.Equal(t, 3, s.Size())
+
+	popped := s.PopN(2)
+	require.Len(t, popped, 2)
+	require.Equal(t, 1, s.Size())
+	last, ok := s.Pop()
+	require.True(t, ok)
+	require.ElementsMatch(t, [][]int{{1}, {2, 3}, {4}}, append(popped, last))
+	require.True(t, s.IsEmpty())
+	require.Empty(t, s.buckets)
+
+	_, ok = s.Pop()
+	require.False(t, ok)
+	_, ok = s.RandomElement()
+	require.False(t, ok)
+}
+
+func TestHashSet_SampleUniform(t *testing.T) {
+	s := newFoldSet("a", "b", "c", "d", "e")
+	s.SetRandSource(rand.NewPCG(3, 4))
+
+	const rounds = 10000
+	sampled := make(map[string]int)
+	drawn := make(map[string]int)
+	for range rounds {
+		for _, item := range s.Sample(2) {
+			sampled[item]++
+		}
+		v, _ := s.RandomElement()
+		drawn[v]++
+	}
+	for _, item := range s.ToSlice() {
+		require.InDelta(t, rounds*2/5, sampled[item], rounds/20)
+		require.InDelta(t, rounds/5, drawn[item], rounds/20)
+	}
+}
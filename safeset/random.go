@@ -0,0 +1,116 @@
+package safeset
+
+import (
+	"math/rand/v2"
+	"sync"
+)
+
+// lockedRand is a rand.Rand that is safe for concurrent use, so that readers
+// holding only the set's read lock can share it
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+// SetRandSource sets the source of randomness used by Pop, PopN, RandomElement
+// and Sample, so that tests can make them reproducible. A nil source restores
+// the default, the global generator of math/rand/v2
+func (s *Set[T]) SetRandSource(src rand.Source) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rng = newLockedRand(src)
+}
+
+// Pop removes and returns a random element of the set, and false if the set is empty
+func (s *Set[T]) Pop() (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.elems) == 0 {
+		var zero T
+		return zero, false
+	}
+	item := s.elems[s.intN(len(s.elems))]
+	s.remove(item)
+	return item, true
+}
+
+// PopN removes and returns up to n random elements of the set. It takes O(n) time
+func (s *Set[T]) PopN(n int) []T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n = min(max(n, 0), len(s.elems))
+	popped := make([]T, 0, n)
+	for range n {
+		item := s.elems[s.intN(len(s.elems))]
+		s.remove(item)
+		popped = append(popped, item)
+	}
+	return popped
+}
+
+// RandomElement returns a random element of the set without removing it,
+// and false if the set is empty
+func (s *Set[T]) RandomElement() (T, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.elems) == 0 {
+		var zero T
+		return zero, false
+	}
+	return s.elems[s.intN(len(s.elems))], true
+}
+
+// Sample returns k distinct elements chosen uniformly at random, in no
+// particular order. If k is at least the size of the set, all elements are
+// returned. It takes O(k) time and does not modify the set
+func (s *Set[T]) Sample(k int) []T {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return sampleElems(s.elems, k, s.rng)
+}
+
+// intN returns a random number in [0, n). The caller must hold a lock on the set
+func (s *Set[T]) intN(n int) int {
+	return s.rng.intN(n)
+}
+
+// sampleElems returns k distinct elements of elems chosen uniformly at random
+// with Floyd's algorithm, under which every k-subset of the indexes is equally
+// likely. If k is at least len(elems), it returns a copy of elems
+func sampleElems[T any](elems []T, k int, rng *lockedRand) []T {
+	n := len(elems)
+	k = min(max(k, 0), n)
+	sample := make([]T, 0, k)
+	if k == n {
+		return append(sample, elems...)
+	}
+	chosen := make(map[int]struct{}, k)
+	for j := n - k; j < n; j++ {
+		i := rng.intN(j + 1)
+		if _, ok := chosen[i]; ok {
+			i = j
+		}
+		chosen[i] = struct{}{}
+		sample = append(sample, elems[i])
+	}
+	return sample
+}
+
+// newLockedRand returns a lockedRand using src, or nil for a nil src
+func newLockedRand(src rand.Source) *lockedRand {
+	if src == nil {
+		return nil
+	}
+	return &lockedRand{r: rand.New(src)}
+}
+
+// intN returns a random number in [0, n). A nil lockedRand uses the global
+// generator of math/rand/v2
+func (r *lockedRand) intN(n int) int {
+	if r == nil {
+		return rand.IntN(n)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.r.IntN(n)
+}
@@ -0,0 +1,159 @@
+package safeset
+
+import (
+	"math/rand/v2"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSet_Pop(t *testing.T) {
+	s := NewSetWithValues(1, 2, 3)
+	var popped []int
+	for {
+		item, ok := s.Pop()
+		if !ok {
+			break
+		}
+		require.False(t, s.Contains(item))
+		popped = append(popped, item)
+	}
+	require.ElementsMatch(t, []int{1, 2, 3}, popped)
+	require.True(t, s.IsEmpty())
+
+	item, ok := s.Pop()
+	require.False(t, ok)
+	require.Zero(t, item)
+}
+
+func TestSet_PopN(t *testing.T) {
+	s := NewSetWithValues(1, 2, 3, 4, 5)
+	popped := s.PopN(3)
+	require.Len(t, popped, 3)
+	require.Equal(t, 2, s.Size())
+	for _, item := range popped {
+		require.False(t, s.Contains(item))
+	}
+
+	rest := s.PopN(10)
+	require.ElementsMatch(t, []int{1, 2, 3, 4, 5}, append(popped, rest...))
+	require.True(t, s.IsEmpty())
+	require.Empty(t, s.PopN(1))
+	require.Empty(t, NewSetWithValues(1).PopN(-1))
+}
+
+func TestSet_PopConcurrent(t *testing.T) {
+	const workers, items = 8, 1000
+	pool := NewSet[int]()
+	for i := range items {
+		pool.Add(i)
+	}
+
+	var mu sync.Mutex
+	taken := make(map[int]int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				id, ok := pool.Pop()
+				if !ok {
+					return
+				}
+				mu.Lock()
+				taken[id]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, taken, items)
+	for id, n := range taken {
+		require.Equal(t, 1, n, "id %d taken more than once", id)
+	}
+}
+
+func TestSet_RandomElement(t *testing.T) {
+	s := NewSet[int]()
+	_, ok := s.RandomElement()
+	require.False(t, ok)
+
+	s = NewSetWithValues(1, 2, 3)
+	seen := make(map[int]bool)
+	for range 200 {
+		item, ok := s.RandomElement()
+		require.True(t, ok)
+		seen[item] = true
+	}
+	require.Equal(t, map[int]bool{1: true, 2: true, 3: true}, seen)
+	require.Equal(t, 3, s.Size())
+}
+
+func TestSet_Sample(t *testing.T) {
+	s := NewSetWithValues(1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+
+	sample := s.Sample(4)
+	require.Len(t, sample, 4)
+	require.True(t, NewSetWithValues(sample...).IsSubsetOf(s))
+	require.Equal(t, 4, NewSetWithValues(sample...).Size())
+	require.Equal(t, 10, s.Size())
+
+	require.ElementsMatch(t, s.ToSlice(), s.Sample(20))
+	require.Empty(t, s.Sample(0))
+	require.Empty(t, s.Sample(-1))
+	require.Empty(t, NewSet[int]().Sample(3))
+}
+
+func TestSet_SampleUniform(t *testing.T) {
+	s := NewSetWithValues(0, 1, 2, 3, 4)
+	s.SetRandSource(rand.NewPCG(1, 2))
+
+	const rounds = 10000
+	counts := make([]int, 5)
+	for range rounds {
+		for _, item := range s.Sample(2) {
+			counts[item]++
+		}
+	}
+	// every element is expected in 2/5 of the samples
+	for _, n := range counts {
+		require.InDelta(t, rounds*2/5, n, rounds/20)
+	}
+}
+
+func TestSet_SetRandSource(t *testing.T) {
+	draw := func() ([]int, int) {
+		s := NewSet[int]()
+		for i := range 100 {
+			s.Add(i)
+		}
+		s.SetRandSource(rand.NewPCG(42, 7))
+		sample := s.Sample(5)
+		item, _ := s.Pop()
+		return sample, item
+	}
+	sample1, item1 := draw()
+	sample2, item2 := draw()
+	require.Equal(t, sample1, sample2)
+	require.Equal(t, item1, item2)
+}
+
+func TestSet_RemoveKeepsIndex(t *testing.T) {
+	s := NewSet[int]()
+	r := rand.New(rand.NewPCG(3, 4))
+	for range 2000 {
+		x := r.IntN(50)
+		if r.IntN(2) == 0 {
+			s.Add(x)
+		} else {
+			s.Remove(x)
+		}
+	}
+	require.Len(t, s.elems, len(s.items))
+	for i, item := range s.elems {
+		require.Equal(t, i, s.items[item])
+	}
+}
@@ -1,20 +1,28 @@
 package safeset
 
 import (
+	"cmp"
 	"fmt"
+	"iter"
+	"slices"
 	"sync"
+	"sync/atomic"
 )
 
-// Set represents a thread-safe set of elements of type T
+// Set represents a thread-safe set of elements of type T.
+// Operations on several sets lock them in a fixed global order, so they are
+// safe to run concurrently on overlapping sets in any argument order
 type Set[T comparable] struct {
-	mu    sync.RWMutex
-	items map[T]struct{}
+	setLock
+	items map[T]int // index of each element in elems
+	elems []T
+	rng   *lockedRand
 }
 
 // NewSet creates and returns a new Set
 func NewSet[T comparable]() *Set[T] {
 	return &Set[T]{
-		items: make(map[T]struct{}),
+		items: make(map[T]int),
 	}
 }
 
@@ -31,23 +39,21 @@ func NewSetWithValues[T comparable](values ...T) *Set[T] {
 func (s *Set[T]) Add(item T) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.items[item] = struct{}{}
+	s.add(item)
 }
 
 // AddWithCheck adds an element to the set and returns true if the element was already in the set
 func (s *Set[T]) AddWithCheck(item T) (existed bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	_, existed = s.items[item]
-	s.items[item] = struct{}{}
-	return existed
+	return !s.add(item)
 }
 
 // Remove removes an element from the set
 func (s *Set[T]) Remove(item T) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	delete(s.items, item)
+	s.remove(item)
 }
 
 // Contains checks if an element is in the set
@@ -69,7 +75,7 @@ func (s *Set[T]) Size() int {
 func (s *Set[T]) Clear() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.items = make(map[T]struct{})
+	s.reset(0)
 }
 
 // IsEmpty returns true if the set is empty
@@ -83,57 +89,56 @@ func (s *Set[T]) IsEmpty() bool {
 func (s *Set[T]) ToSlice() []T {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	slice := make([]T, 0, len(s.items))
-	for item := range s.items {
-		slice = append(slice, item)
-	}
+	slice := make([]T, len(s.elems))
+	copy(slice, s.elems)
 	return slice
 }
 
-// Union returns a new set that is the union of s and other
-func (s *Set[T]) Union(other *Set[T]) *Set[T] {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	other.mu.RLock()
-	defer other.mu.RUnlock()
-
-	unionSet := NewSet[T]()
-	for item := range s.items {
-		unionSet.Add(item)
+// All returns an iterator over a copy of the elements of the set, in no particular order
+func (s *Set[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for _, item := range s.ToSlice() {
+			if !yield(item) {
+				return
+			}
+		}
 	}
-	for item := range other.items {
-		unionSet.Add(item)
+}
+
+// AllLocked returns an iterator over the elements of the set, in no particular order,
+// that holds the read lock for the whole loop instead of copying the elements.
+// It allocates nothing, but writers wait until the loop ends, and the loop body must
+// not call any method on the set (that deadlocks)
+func (s *Set[T]) AllLocked() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		for _, item := range s.elems {
+			if !yield(item) {
+				return
+			}
+		}
 	}
-	return unionSet
+}
+
+// Union returns a new set that is the union of s and other
+func (s *Set[T]) Union(other *Set[T]) *Set[T] {
+	return UnionAll(s, other)
 }
 
 // Intersection returns a new set that is the intersection of s and other
 func (s *Set[T]) Intersection(other *Set[T]) *Set[T] {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	other.mu.RLock()
-	defer other.mu.RUnlock()
-
-	intersectionSet := NewSet[T]()
-	for item := range s.items {
-		if other.Contains(item) {
-			intersectionSet.Add(item)
-		}
-	}
-	return intersectionSet
+	return IntersectAll(s, other)
 }
 
 // Difference returns a new set that is the difference of s and other
 func (s *Set[T]) Difference(other *Set[T]) *Set[T] {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	other.mu.RLock()
-	defer other.mu.RUnlock()
+	defer lockSets(nil, s, other)()
 
 	differenceSet := NewSet[T]()
 	for item := range s.items {
-		if !other.Contains(item) {
-			differenceSet.Add(item)
+		if _, ok := other.items[item]; !ok {
+			differenceSet.add(item)
 		}
 	}
 	return differenceSet
@@ -141,17 +146,8 @@ func (s *Set[T]) Difference(other *Set[T]) *Set[T] {
 
 // IsSubsetOf returns true if s is a subset of other
 func (s *Set[T]) IsSubsetOf(other *Set[T]) bool {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	other.mu.RLock()
-	defer other.mu.RUnlock()
-
-	for item := range s.items {
-		if !other.Contains(item) {
-			return false
-		}
-	}
-	return true
+	defer lockSets(nil, s, other)()
+	return isSubset(s.items, other.items)
 }
 
 // IsSupersetOf returns true if s is a superset of other
@@ -161,7 +157,8 @@ func (s *Set[T]) IsSupersetOf(other *Set[T]) bool {
 
 // Equal returns true if s and other contain the same elements
 func (s *Set[T]) Equal(other *Set[T]) bool {
-	return s.IsSubsetOf(other) && s.IsSupersetOf(other)
+	defer lockSets(nil, s, other)()
+	return len(s.items) == len(other.items) && isSubset(s.items, other.items)
 }
 
 // Clone returns a new set with the same elements as s
@@ -196,21 +193,227 @@ func (s *Set[T]) String() string {
 
 // SymmetricDifference returns a new set that is the symmetric difference (XOR) of s and other
 func (s *Set[T]) SymmetricDifference(other *Set[T]) *Set[T] {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	other.mu.RLock()
-	defer other.mu.RUnlock()
+	defer lockSets(nil, s, other)()
 
 	xorSet := NewSet[T]()
 	for item := range s.items {
-		if !other.Contains(item) {
-			xorSet.Add(item)
+		if _, ok := other.items[item]; !ok {
+			xorSet.add(item)
 		}
 	}
 	for item := range other.items {
-		if !s.Contains(item) {
-			xorSet.Add(item)
+		if _, ok := s.items[item]; !ok {
+			xorSet.add(item)
 		}
 	}
 	return xorSet
 }
+
+// UnionAll returns a new set with the elements of all the given sets
+func UnionAll[T comparable](sets ...*Set[T]) *Set[T] {
+	defer lockSets(nil, sets...)()
+
+	unionSet := NewSet[T]()
+	for _, set := range sets {
+		for item := range set.items {
+			unionSet.add(item)
+		}
+	}
+	return unionSet
+}
+
+// IntersectAll returns a new set with the elements that are in every given set.
+// With no sets it returns an empty set
+func IntersectAll[T comparable](sets ...*Set[T]) *Set[T] {
+	defer lockSets(nil, sets...)()
+
+	intersectionSet := NewSet[T]()
+	if len(sets) == 0 {
+		return intersectionSet
+	}
+	smallest := sets[0]
+	for _, set := range sets[1:] {
+		if len(set.items) < len(smallest.items) {
+			smallest = set
+		}
+	}
+	for item := range smallest.items {
+		if inAll(item, sets) {
+			intersectionSet.add(item)
+		}
+	}
+	return intersectionSet
+}
+
+// UnionWith adds the elements of all the given sets to s
+func (s *Set[T]) UnionWith(others ...*Set[T]) {
+	defer lockSets(s, others...)()
+
+	for _, other := range others {
+		if other == s {
+			continue
+		}
+		for item := range other.items {
+			s.add(item)
+		}
+	}
+}
+
+// IntersectWith removes the elements of s that are not in all of the given sets
+func (s *Set[T]) IntersectWith(others ...*Set[T]) {
+	defer lockSets(s, others...)()
+
+	for item := range s.items {
+		if !inAll(item, others) {
+			s.remove(item)
+		}
+	}
+}
+
+// SubtractWith removes the elements of all the given sets from s
+func (s *Set[T]) SubtractWith(others ...*Set[T]) {
+	defer lockSets(s, others...)()
+
+	for _, other := range others {
+		if other == s {
+			s.reset(0)
+			return
+		}
+	}
+	for _, other := range others {
+		for item := range other.items {
+			s.remove(item)
+		}
+	}
+}
+
+// add adds an element and returns true if it was not in the set yet.
+// The caller must hold the write lock
+func (s *Set[T]) add(item T) bool {
+	if _, ok := s.items[item]; ok {
+		return false
+	}
+	s.items[item] = len(s.elems)
+	s.elems = append(s.elems, item)
+	return true
+}
+
+// remove removes an element and returns true if it was in the set. The last
+// element takes its place in elems, so removal is O(1).
+// The caller must hold the write lock
+func (s *Set[T]) remove(item T) bool {
+	i, ok := s.items[item]
+	if !ok {
+		return false
+	}
+	last := len(s.elems) - 1
+	if i != last {
+		s.elems[i] = s.elems[last]
+		s.items[s.elems[i]] = i
+	}
+	var zero T
+	s.elems[last] = zero
+	s.elems = s.elems[:last]
+	delete(s.items, item)
+	return true
+}
+
+// reset removes all elements, making room for capacity new ones.
+// The caller must hold the write lock
+func (s *Set[T]) reset(capacity int) {
+	s.items = make(map[T]int, capacity)
+	s.elems = make([]T, 0, capacity)
+}
+
+// lastSetID is the last identity handed out to a set for lock ordering
+var lastSetID atomic.Uint64
+
+// setLock is the lock of a set together with the identity that orders its
+// acquisition in multi-set operations. Every set type embeds one, which makes
+// it an orderedLocker
+type setLock struct {
+	mu sync.RWMutex
+	id atomic.Uint64 // assigned on first use
+}
+
+// identity returns a process-unique, stable number for the set, used to order
+// lock acquisition across sets
+func (l *setLock) identity() uint64 {
+	if id := l.id.Load(); id != 0 {
+		return id
+	}
+	l.id.CompareAndSwap(0, lastSetID.Add(1))
+	return l.id.Load()
+}
+
+// rwMutex returns the lock of the set, for lockSets
+func (l *setLock) rwMutex() *sync.RWMutex {
+	return &l.mu
+}
+
+// orderedLocker is a set that can take part in a multi-set operation
+type orderedLocker interface {
+	comparable
+	identity() uint64
+	rwMutex() *sync.RWMutex
+}
+
+// lockSets write-locks target, if it is not nil, and read-locks the other sets.
+// Every set is locked once, in identity order, so concurrent calls over
+// overlapping sets cannot deadlock and no lock is ever acquired recursively.
+// It returns a function that releases all the locks
+func lockSets[S orderedLocker](target S, others ...S) (unlock func()) {
+	var none S
+	sets := make([]S, 0, len(others)+1)
+	if target != none {
+		sets = append(sets, target)
+	}
+	for _, set := range others {
+		if !slices.Contains(sets, set) {
+			sets = append(sets, set)
+		}
+	}
+	slices.SortFunc(sets, func(a, b S) int {
+		return cmp.Compare(a.identity(), b.identity())
+	})
+
+	for _, set := range sets {
+		if set == target {
+			set.rwMutex().Lock()
+		} else {
+			set.rwMutex().RLock()
+		}
+	}
+	return func() {
+		for _, set := range sets {
+			if set == target {
+				set.rwMutex().Unlock()
+			} else {
+				set.rwMutex().RUnlock()
+			}
+		}
+	}
+}
+
+// isSubset returns true if every element of a is in b
+func isSubset[T comparable](a, b map[T]int) bool {
+	if len(a) > len(b) {
+		return false
+	}
+	for item := range a {
+		if _, ok := b[item]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// inAll returns true if item is in every one of sets. The caller must hold their locks
+func inAll[T comparable](item T, sets []*Set[T]) bool {
+	for _, set := range sets {
+		if _, ok := set.items[item]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -1,6 +1,7 @@
 package safeset
 
 import (
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -650,3 +651,148 @@ func TestSet_IsNotEmpty(t *testing.T) {
 	s.Add(1)
 	require.True(t, !s.IsEmpty())
 }
+
+func TestSet_All(t *testing.T) {
+	s := NewSetWithValues[int](1, 2, 3)
+	var items []int
+	for item := range s.All() {
+		items = append(items, item)
+	}
+	require.ElementsMatch(t, []int{1, 2, 3}, items)
+}
+
+func TestSet_AllBreak(t *testing.T) {
+	s := NewSetWithValues[int](1, 2, 3)
+	n := 0
+	for range s.All() {
+		n++
+		break
+	}
+	require.Equal(t, 1, n)
+
+	// the lock is released after breaking out of the loop
+	s.Add(4)
+	require.Equal(t, 4, s.Size())
+}
+
+func TestSet_AllReadAndModify(t *testing.T) {
+	s := NewSetWithValues(1, 2, 3)
+	var items []int
+	for item := range s.All() {
+		// calling other methods from the loop body must not deadlock
+		require.True(t, s.Contains(item))
+		s.Remove(item)
+		s.Add(item * 10)
+		items = append(items, item)
+	}
+	require.ElementsMatch(t, []int{1, 2, 3}, items)
+	require.ElementsMatch(t, []int{10, 20, 30}, s.ToSlice())
+}
+
+func TestSet_AllEmpty(t *testing.T) {
+	s := NewSet[int]()
+	for range s.All() {
+		t.Fatal("unexpected element")
+	}
+}
+
+func TestSet_AllLocked(t *testing.T) {
+	s := NewSetWithValues(1, 2, 3)
+	var items []int
+	for item := range s.AllLocked() {
+		items = append(items, item)
+	}
+	require.ElementsMatch(t, []int{1, 2, 3}, items)
+
+	for range s.AllLocked() {
+		break
+	}
+	// the lock is released after breaking out of the loop
+	s.Add(4)
+	require.Equal(t, 4, s.Size())
+
+	allocs := testing.AllocsPerRun(10, func() {
+		for range s.AllLocked() {
+		}
+	})
+	require.Zero(t, allocs)
+}
+
+func TestSet_UnionAll(t *testing.T) {
+	s1 := NewSetWithValues(1, 2)
+	s2 := NewSetWithValues(2, 3)
+	s3 := NewSetWithValues(4)
+
+	require.ElementsMatch(t, []int{1, 2, 3, 4}, UnionAll(s1, s2, s3, s1).ToSlice())
+	require.True(t, UnionAll[int]().IsEmpty())
+}
+
+func TestSet_IntersectAll(t *testing.T) {
+	s1 := NewSetWithValues(1, 2, 3, 4)
+	s2 := NewSetWithValues(2, 3, 4)
+	s3 := NewSetWithValues(3, 4, 5)
+
+	require.ElementsMatch(t, []int{3, 4}, IntersectAll(s1, s2, s3).ToSlice())
+	require.ElementsMatch(t, []int{1, 2, 3, 4}, IntersectAll(s1, s1).ToSlice())
+	require.True(t, IntersectAll(s1, NewSet[int]()).IsEmpty())
+	require.True(t, IntersectAll[int]().IsEmpty())
+}
+
+func TestSet_UnionWith(t *testing.T) {
+	s := NewSetWithValues(1, 2)
+	s.UnionWith(NewSetWithValues(2, 3), NewSetWithValues(4), s)
+	require.ElementsMatch(t, []int{1, 2, 3, 4}, s.ToSlice())
+}
+
+func TestSet_IntersectWith(t *testing.T) {
+	s := NewSetWithValues(1, 2, 3, 4)
+	s.IntersectWith(NewSetWithValues(2, 3, 4), s, NewSetWithValues(3, 4, 5))
+	require.ElementsMatch(t, []int{3, 4}, s.ToSlice())
+
+	s.IntersectWith()
+	require.ElementsMatch(t, []int{3, 4}, s.ToSlice())
+}
+
+func TestSet_SubtractWith(t *testing.T) {
+	s := NewSetWithValues(1, 2, 3, 4)
+	s.SubtractWith(NewSetWithValues(1), NewSetWithValues(3, 5))
+	require.ElementsMatch(t, []int{2, 4}, s.ToSlice())
+
+	s.SubtractWith(s)
+	require.True(t, s.IsEmpty())
+}
+
+func TestSet_AlgebraConcurrent(t *testing.T) {
+	a := NewSetWithValues(1, 2, 3)
+	b := NewSetWithValues(2, 3, 4)
+	c := NewSetWithValues(3, 4, 5)
+
+	var wg sync.WaitGroup
+	run := func(fn func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				fn(i)
+			}
+		}()
+	}
+
+	// writers
+	run(func(i int) { a.Add(i % 10); a.Remove((i + 5) % 10) })
+	run(func(i int) { b.Add(i % 10); b.Remove((i + 3) % 10) })
+	run(func(i int) { c.AddWithCheck(i % 10); c.Remove((i + 7) % 10) })
+
+	// the same operations with their arguments in opposite orders
+	run(func(int) { a.Union(b); b.Union(a) })
+	run(func(int) { a.Intersection(c); c.Intersection(a) })
+	run(func(int) { b.Difference(c); c.Difference(b) })
+	run(func(int) { a.SymmetricDifference(b); b.SymmetricDifference(a) })
+	run(func(int) { a.IsSubsetOf(c); c.IsSupersetOf(a); a.Equal(c) })
+	run(func(int) { UnionAll(a, b, c); IntersectAll(c, b, a) })
+	run(func(int) { a.UnionWith(b, c); a.SubtractWith(c) })
+	run(func(int) { b.IntersectWith(c, a); b.UnionWith(a) })
+	run(func(int) { c.SubtractWith(a); c.UnionWith(b, a) })
+
+	wg.Wait()
+}
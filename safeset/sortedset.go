@@ -0,0 +1,529 @@
+package safeset
+
+import (
+	"cmp"
+	"fmt"
+	"iter"
+	"math/rand/v2"
+	"slices"
+	"strings"
+)
+
+// sortedMaxLevel bounds the height of the skip list; with a branching factor of 4
+// it comfortably indexes billions of elements
+const sortedMaxLevel = 24
+
+// SortedSet represents a thread-safe set whose elements are kept in order.
+// It is backed by an indexable skip list, so Add, Remove, Contains, Rank, At and
+// the Pop methods are O(log n), and set algebra walks both sets in order in linear time.
+// Operations on two sets lock them in the same global order as Set.
+type SortedSet[T any] struct {
+	setLock
+	compare func(a, b T) int
+	head    *sortedNode[T] // sentinel, its links start every level
+	tail    *sortedNode[T]
+	level   int
+	length  int
+}
+
+// sortedNode is an element of the skip list
+type sortedNode[T any] struct {
+	item T
+	prev *sortedNode[T] // previous node on level 0, nil for the first node
+	next []sortedLink[T]
+}
+
+// sortedLink is a forward pointer of a skip list node. span is the number of
+// positions it skips; for a nil pointer it is the distance to the end of the list
+type sortedLink[T any] struct {
+	node *sortedNode[T]
+	span int
+}
+
+// NewSortedSet creates and returns a new SortedSet ordered by the natural order of T
+func NewSortedSet[T cmp.Ordered]() *SortedSet[T] {
+	return NewSortedSetFunc[T](cmp.Compare[T])
+}
+
+// NewSortedSetWithValues creates and returns a new SortedSet with the given values
+func NewSortedSetWithValues[T cmp.Ordered](values ...T) *SortedSet[T] {
+	items := slices.Clone(values)
+	slices.Sort(items)
+	return newSortedSetFromSorted(cmp.Compare[T], slices.Compact(items))
+}
+
+// NewSortedSetFunc creates and returns a new SortedSet ordered by compare, which returns a
+// negative number if a < b, zero if a == b and a positive number if a > b
+func NewSortedSetFunc[T any](compare func(a, b T) int) *SortedSet[T] {
+	return &SortedSet[T]{
+		compare: compare,
+		head:    &sortedNode[T]{next: make([]sortedLink[T], sortedMaxLevel)},
+		level:   1,
+	}
+}
+
+// newSortedSetFromSorted creates a SortedSet from items, which must be in ascending
+// order without duplicates, in linear time
+func newSortedSetFromSorted[T any](compare func(a, b T) int, items []T) *SortedSet[T] {
+	s := NewSortedSetFunc(compare)
+	b := s.builder()
+	for _, item := range items {
+		b.append(item)
+	}
+	b.finish()
+	return s
+}
+
+// Add adds an element to the set
+func (s *SortedSet[T]) Add(item T) {
+	s.AddWithCheck(item)
+}
+
+// AddWithCheck adds an element to the set and returns true if the element was already in the set
+func (s *SortedSet[T]) AddWithCheck(item T) (existed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var update [sortedMaxLevel]*sortedNode[T]
+	var rank [sortedMaxLevel]int
+	if n := s.search(item, &update, &rank); n != nil && s.compare(n.item, item) == 0 {
+		return true
+	}
+	s.insert(item, &update, &rank)
+	return false
+}
+
+// Remove removes an element from the set
+func (s *SortedSet[T]) Remove(item T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delete(item)
+}
+
+// Contains checks if an element is in the set
+func (s *SortedSet[T]) Contains(item T) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := s.search(item, nil, nil)
+	return n != nil && s.compare(n.item, item) == 0
+}
+
+// Size returns the number of elements in the set
+func (s *SortedSet[T]) Size() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.length
+}
+
+// Clear removes all elements from the set
+func (s *SortedSet[T]) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.head.next)
+	s.tail = nil
+	s.level = 1
+	s.length = 0
+}
+
+// IsEmpty returns true if the set is empty
+func (s *SortedSet[T]) IsEmpty() bool {
+	return s.Size() == 0
+}
+
+// ToSlice returns a slice containing all elements in the set in ascending order
+func (s *SortedSet[T]) ToSlice() []T {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.toSlice()
+}
+
+// All returns an iterator over the elements of the set in ascending order
+func (s *SortedSet[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for _, item := range s.ToSlice() {
+			if !yield(item) {
+				return
+			}
+		}
+	}
+}
+
+// Range returns an iterator over the elements in the half-open interval [lo, hi) in
+// ascending order
+func (s *SortedSet[T]) Range(lo, hi T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		s.mu.RLock()
+		var items []T
+		for n := s.search(lo, nil, nil); n != nil && s.compare(n.item, hi) < 0; n = n.next[0].node {
+			items = append(items, n.item)
+		}
+		s.mu.RUnlock()
+		for _, item := range items {
+			if !yield(item) {
+				return
+			}
+		}
+	}
+}
+
+// Rank returns the number of elements in the set that are less than item
+func (s *SortedSet[T]) Rank(item T) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var rank [sortedMaxLevel]int
+	s.search(item, nil, &rank)
+	return rank[0]
+}
+
+// At returns the element at index i in ascending order.
+// The boolean is false if i is out of range
+func (s *SortedSet[T]) At(i int) (T, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return itemOf(s.at(i))
+}
+
+// Min returns the smallest element. The boolean is false if the set is empty
+func (s *SortedSet[T]) Min() (T, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return itemOf(s.head.next[0].node)
+}
+
+// Max returns the largest element. The boolean is false if the set is empty
+func (s *SortedSet[T]) Max() (T, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return itemOf(s.tail)
+}
+
+// PopMin removes and returns the smallest element. The boolean is false if the set is empty
+func (s *SortedSet[T]) PopMin() (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.head.next[0].node
+	if n != nil {
+		s.delete(n.item)
+	}
+	return itemOf(n)
+}
+
+// PopMax removes and returns the largest element. The boolean is false if the set is empty
+func (s *SortedSet[T]) PopMax() (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.tail
+	if n != nil {
+		s.delete(n.item)
+	}
+	return itemOf(n)
+}
+
+// Floor returns the largest element less than or equal to item.
+// The boolean is false if there is no such element
+func (s *SortedSet[T]) Floor(item T) (T, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := s.search(item, nil, nil)
+	switch {
+	case n != nil && s.compare(n.item, item) == 0:
+		return n.item, true
+	case n == nil:
+		return itemOf(s.tail)
+	default:
+		return itemOf(n.prev)
+	}
+}
+
+// Ceiling returns the smallest element greater than or equal to item.
+// The boolean is false if there is no such element
+func (s *SortedSet[T]) Ceiling(item T) (T, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return itemOf(s.search(item, nil, nil))
+}
+
+// Union returns a new set that is the union of s and other
+func (s *SortedSet[T]) Union(other *SortedSet[T]) *SortedSet[T] {
+	return s.merge(other, true, true, true)
+}
+
+// Intersection returns a new set that is the intersection of s and other
+func (s *SortedSet[T]) Intersection(other *SortedSet[T]) *SortedSet[T] {
+	return s.merge(other, false, true, false)
+}
+
+// Difference returns a new set that is the difference of s and other
+func (s *SortedSet[T]) Difference(other *SortedSet[T]) *SortedSet[T] {
+	return s.merge(other, true, false, false)
+}
+
+// SymmetricDifference returns a new set that is the symmetric difference (XOR) of s and other
+func (s *SortedSet[T]) SymmetricDifference(other *SortedSet[T]) *SortedSet[T] {
+	return s.merge(other, true, false, true)
+}
+
+// IsSubsetOf returns true if s is a subset of other
+func (s *SortedSet[T]) IsSubsetOf(other *SortedSet[T]) bool {
+	defer lockSets(nil, s, other)()
+	if s.length > other.length {
+		return false
+	}
+	theirs := other.head.next[0].node
+	for n := s.head.next[0].node; n != nil; n = n.next[0].node {
+		for theirs != nil && s.compare(theirs.item, n.item) < 0 {
+			theirs = theirs.next[0].node
+		}
+		if theirs == nil || s.compare(theirs.item, n.item) != 0 {
+			return false
+		}
+		theirs = theirs.next[0].node
+	}
+	return true
+}
+
+// IsSupersetOf returns true if s is a superset of other
+func (s *SortedSet[T]) IsSupersetOf(other *SortedSet[T]) bool {
+	return other.IsSubsetOf(s)
+}
+
+// Equal returns true if s and other contain the same elements
+func (s *SortedSet[T]) Equal(other *SortedSet[T]) bool {
+	defer lockSets(nil, s, other)()
+	if s.length != other.length {
+		return false
+	}
+	theirs := other.head.next[0].node
+	for n := s.head.next[0].node; n != nil; n = n.next[0].node {
+		if s.compare(n.item, theirs.item) != 0 {
+			return false
+		}
+		theirs = theirs.next[0].node
+	}
+	return true
+}
+
+// Clone returns a new set with the same elements and order as s
+func (s *SortedSet[T]) Clone() *SortedSet[T] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return newSortedSetFromSorted(s.compare, s.toSlice())
+}
+
+// String returns a string representation of the set in ascending order
+func (s *SortedSet[T]) String() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var b strings.Builder
+	b.WriteByte('{')
+	for n := s.head.next[0].node; n != nil; n = n.next[0].node {
+		if n != s.head.next[0].node {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%v", n.item)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// search returns the first node with an element greater than or equal to item, or nil.
+// If update is not nil, update[i] is set to the last node before item on level i; if
+// rank is not nil, rank[i] is set to the number of elements up to and including that
+// node, so rank[0] is the number of elements less than item. The caller must hold the lock
+func (s *SortedSet[T]) search(item T, update *[sortedMaxLevel]*sortedNode[T], rank *[sortedMaxLevel]int) *sortedNode[T] {
+	x := s.head
+	pos := 0
+	for i := s.level - 1; i >= 0; i-- {
+		for x.next[i].node != nil && s.compare(x.next[i].node.item, item) < 0 {
+			pos += x.next[i].span
+			x = x.next[i].node
+		}
+		if update != nil {
+			update[i] = x
+		}
+		if rank != nil {
+			rank[i] = pos
+		}
+	}
+	return x.next[0].node
+}
+
+// at returns the node at index i, or nil if i is out of range. The caller must hold the lock
+func (s *SortedSet[T]) at(i int) *sortedNode[T] {
+	if i < 0 || i >= s.length {
+		return nil
+	}
+	x := s.head
+	pos := 0
+	for level := s.level - 1; level >= 0; level-- {
+		for x.next[level].node != nil && pos+x.next[level].span <= i+1 {
+			pos += x.next[level].span
+			x = x.next[level].node
+		}
+		if pos == i+1 {
+			return x
+		}
+	}
+	return nil
+}
+
+// insert links a new node for item after the nodes in update, which must have been
+// filled in by search together with rank. The caller must hold the write lock
+func (s *SortedSet[T]) insert(item T, update *[sortedMaxLevel]*sortedNode[T], rank *[sortedMaxLevel]int) {
+	level := randomLevel()
+	for i := s.level; i < level; i++ {
+		update[i] = s.head
+		rank[i] = 0
+		s.head.next[i].span = s.length
+	}
+	s.level = max(s.level, level)
+
+	n := &sortedNode[T]{item: item, next: make([]sortedLink[T], level)}
+	for i := 0; i < level; i++ {
+		prev := &update[i].next[i]
+		n.next[i] = sortedLink[T]{node: prev.node, span: prev.span - (rank[0] - rank[i])}
+		*prev = sortedLink[T]{node: n, span: rank[0] - rank[i] + 1}
+	}
+	for i := level; i < s.level; i++ {
+		update[i].next[i].span++
+	}
+	if update[0] != s.head {
+		n.prev = update[0]
+	}
+	if n.next[0].node != nil {
+		n.next[0].node.prev = n
+	} else {
+		s.tail = n
+	}
+	s.length++
+}
+
+// delete unlinks the node with the element item and returns true, or false if the
+// element is not in the set. The caller must hold the write lock
+func (s *SortedSet[T]) delete(item T) bool {
+	var update [sortedMaxLevel]*sortedNode[T]
+	n := s.search(item, &update, nil)
+	if n == nil || s.compare(n.item, item) != 0 {
+		return false
+	}
+	for i := 0; i < s.level; i++ {
+		prev := &update[i].next[i]
+		if prev.node == n {
+			*prev = sortedLink[T]{node: n.next[i].node, span: prev.span + n.next[i].span - 1}
+		} else {
+			prev.span--
+		}
+	}
+	if n.next[0].node != nil {
+		n.next[0].node.prev = n.prev
+	} else {
+		s.tail = n.prev
+	}
+	for s.level > 1 && s.head.next[s.level-1].node == nil {
+		s.level--
+	}
+	s.length--
+	return true
+}
+
+// toSlice returns the elements in ascending order. The caller must hold the lock
+func (s *SortedSet[T]) toSlice() []T {
+	items := make([]T, 0, s.length)
+	for n := s.head.next[0].node; n != nil; n = n.next[0].node {
+		items = append(items, n.item)
+	}
+	return items
+}
+
+// merge walks both sets in order and returns a new set with the elements found only in s,
+// in both sets or only in other, as selected
+func (s *SortedSet[T]) merge(other *SortedSet[T], onlyOurs, both, onlyTheirs bool) *SortedSet[T] {
+	defer lockSets(nil, s, other)()
+	merged := NewSortedSetFunc(s.compare)
+	b := merged.builder()
+	ours, theirs := s.head.next[0].node, other.head.next[0].node
+	for ours != nil && theirs != nil {
+		switch c := s.compare(ours.item, theirs.item); {
+		case c < 0:
+			if onlyOurs {
+				b.append(ours.item)
+			}
+			ours = ours.next[0].node
+		case c > 0:
+			if onlyTheirs {
+				b.append(theirs.item)
+			}
+			theirs = theirs.next[0].node
+		default:
+			if both {
+				b.append(ours.item)
+			}
+			ours = ours.next[0].node
+			theirs = theirs.next[0].node
+		}
+	}
+	for ; onlyOurs && ours != nil; ours = ours.next[0].node {
+		b.append(ours.item)
+	}
+	for ; onlyTheirs && theirs != nil; theirs = theirs.next[0].node {
+		b.append(theirs.item)
+	}
+	b.finish()
+	return merged
+}
+
+// sortedBuilder fills an empty SortedSet with elements appended in ascending order,
+// in constant expected time per element
+type sortedBuilder[T any] struct {
+	s    *SortedSet[T]
+	last [sortedMaxLevel]*sortedNode[T] // last node on each level
+	pos  [sortedMaxLevel]int            // position of last[i]; the head is at 0
+}
+
+// builder returns a sortedBuilder for s, which must be empty and not yet shared
+func (s *SortedSet[T]) builder() *sortedBuilder[T] {
+	b := &sortedBuilder[T]{s: s}
+	for i := range b.last {
+		b.last[i] = s.head
+	}
+	return b
+}
+
+// append adds item after the current last element, which must be less than item
+func (b *sortedBuilder[T]) append(item T) {
+	s := b.s
+	s.length++
+	level := randomLevel()
+	s.level = max(s.level, level)
+	n := &sortedNode[T]{item: item, prev: s.tail, next: make([]sortedLink[T], level)}
+	for i := 0; i < level; i++ {
+		b.last[i].next[i] = sortedLink[T]{node: n, span: s.length - b.pos[i]}
+		b.last[i] = n
+		b.pos[i] = s.length
+	}
+	s.tail = n
+}
+
+// finish sets the spans of the last node on each level to the end of the list
+func (b *sortedBuilder[T]) finish() {
+	for i := 0; i < b.s.level; i++ {
+		b.last[i].next[i].span = b.s.length - b.pos[i]
+	}
+}
+
+// itemOf returns the element of n, or the zero value and false if n is nil
+func itemOf[T any](n *sortedNode[T]) (T, bool) {
+	if n == nil {
+		var zero T
+		return zero, false
+	}
+	return n.item, true
+}
+
+// randomLevel returns the height of a new skip list node
+func randomLevel() int {
+	level := 1
+	for level < sortedMaxLevel && rand.IntN(4) == 0 {
+		level++
+	}
+	return level
+}
@@ -0,0 +1,265 @@
+package safeset
+
+import (
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortedSet_AddRemove(t *testing.T) {
+	s := NewSortedSet[int]()
+	require.True(t, s.IsEmpty())
+	for _, v := range []int{5, 1, 4, 1, 3} {
+		s.Add(v)
+	}
+	require.True(t, s.AddWithCheck(4))
+	require.False(t, s.AddWithCheck(2))
+	require.Equal(t, []int{1, 2, 3, 4, 5}, s.ToSlice())
+	require.Equal(t, 5, s.Size())
+	require.True(t, s.Contains(3))
+
+	s.Remove(3)
+	s.Remove(42)
+	require.False(t, s.Contains(3))
+	require.Equal(t, "{1, 2, 4, 5}", s.String())
+
+	s.Clear()
+	require.True(t, s.IsEmpty())
+	require.Equal(t, "{}", s.String())
+}
+
+func TestSortedSet_NewSortedSetWithValues(t *testing.T) {
+	values := []string{"pear", "apple", "fig", "apple"}
+	s := NewSortedSetWithValues(values...)
+	require.Equal(t, []string{"apple", "fig", "pear"}, s.ToSlice())
+	require.Equal(t, []string{"pear", "apple", "fig", "apple"}, values)
+}
+
+func TestSortedSet_Queries(t *testing.T) {
+	s := NewSortedSetWithValues(10, 20, 30, 40)
+
+	tests := []struct {
+		item                 int
+		rank                 int
+		floor, ceiling       int
+		hasFloor, hasCeiling bool
+	}{
+		{5, 0, 0, 10, false, true},
+		{10, 0, 10, 10, true, true},
+		{25, 2, 20, 30, true, true},
+		{40, 3, 40, 40, true, true},
+		{45, 4, 40, 0, true, false},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.rank, s.Rank(tt.item))
+		floor, ok := s.Floor(tt.item)
+		require.Equal(t, tt.hasFloor, ok)
+		require.Equal(t, tt.floor, floor)
+		ceiling, ok := s.Ceiling(tt.item)
+		require.Equal(t, tt.hasCeiling, ok)
+		require.Equal(t, tt.ceiling, ceiling)
+	}
+
+	item, ok := s.At(2)
+	require.True(t, ok)
+	require.Equal(t, 30, item)
+	_, ok = s.At(4)
+	require.False(t, ok)
+	_, ok = s.At(-1)
+	require.False(t, ok)
+
+	require.Equal(t, []int{20, 30}, slices.Collect(s.Range(15, 40)))
+	require.Empty(t, slices.Collect(s.Range(40, 10)))
+	require.Equal(t, []int{10, 20, 30, 40}, slices.Collect(s.All()))
+	for item := range s.All() {
+		s.Remove(item) // the body may use the set
+	}
+	require.True(t, s.IsEmpty())
+}
+
+func TestSortedSet_MinMaxPop(t *testing.T) {
+	s := NewSortedSet[int]()
+	_, ok := s.Min()
+	require.False(t, ok)
+	_, ok = s.PopMax()
+	require.False(t, ok)
+
+	for _, v := range rand.Perm(10) {
+		s.Add(v)
+	}
+	minimum, _ := s.Min()
+	maximum, _ := s.Max()
+	require.Equal(t, 0, minimum)
+	require.Equal(t, 9, maximum)
+
+	item, ok := s.PopMin()
+	require.True(t, ok)
+	require.Equal(t, 0, item)
+	item, ok = s.PopMax()
+	require.True(t, ok)
+	require.Equal(t, 9, item)
+	require.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8}, s.ToSlice())
+}
+
+func TestSortedSet_MatchesSortedSlice(t *testing.T) {
+	s := NewSortedSet[int]()
+	want := []int{}
+	check := func() {
+		require.Equal(t, want, s.ToSlice())
+		require.Equal(t, len(want), s.Size())
+		for i, item := range want {
+			got, ok := s.At(i)
+			require.True(t, ok)
+			require.Equal(t, item, got)
+			require.Equal(t, i, s.Rank(item))
+		}
+		// the element after the last one is ranked at the end
+		require.Equal(t, len(want), s.Rank(1000))
+	}
+
+	r := rand.New(rand.NewPCG(1, 2))
+	for i := 0; i < 2000; i++ {
+		item := r.IntN(500)
+		switch op := r.IntN(10); {
+		case op < 6:
+			if i, found := slices.BinarySearch(want, item); !found {
+				want = slices.Insert(want, i, item)
+			}
+			s.Add(item)
+		case op < 8:
+			if i, found := slices.BinarySearch(want, item); found {
+				want = slices.Delete(want, i, i+1)
+			}
+			s.Remove(item)
+		case op < 9:
+			if len(want) > 0 {
+				want = want[1:]
+			}
+			s.PopMin()
+		default:
+			if len(want) > 0 {
+				want = want[:len(want)-1]
+			}
+			s.PopMax()
+		}
+		if i%100 == 0 {
+			check()
+		}
+	}
+	check()
+
+	// sets built in linear time are indexed the same way
+	other := NewSortedSetWithValues(want...)
+	s = other.Union(NewSortedSet[int]())
+	check()
+	s = other.Clone()
+	check()
+}
+
+func TestSortedSet_Func(t *testing.T) {
+	s := NewSortedSetFunc(func(a, b string) int {
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	})
+	s.Add("Banana")
+	s.Add("apple")
+	require.True(t, s.AddWithCheck("BANANA"))
+	require.Equal(t, []string{"apple", "Banana"}, s.ToSlice())
+}
+
+func TestSortedSet_Algebra(t *testing.T) {
+	a := NewSortedSetWithValues(1, 2, 3, 4)
+	b := NewSortedSetWithValues(3, 4, 5, 6)
+
+	require.Equal(t, []int{1, 2, 3, 4, 5, 6}, a.Union(b).ToSlice())
+	require.Equal(t, []int{3, 4}, a.Intersection(b).ToSlice())
+	require.Equal(t, []int{1, 2}, a.Difference(b).ToSlice())
+	require.Equal(t, []int{5, 6}, b.Difference(a).ToSlice())
+	require.Equal(t, []int{1, 2, 5, 6}, a.SymmetricDifference(b).ToSlice())
+
+	require.Equal(t, a.ToSlice(), a.Union(a).ToSlice())
+	require.True(t, a.Difference(a).IsEmpty())
+	require.True(t, a.Union(NewSortedSet[int]()).Equal(a))
+}
+
+func TestSortedSet_Relations(t *testing.T) {
+	a := NewSortedSetWithValues(1, 2, 3)
+	b := NewSortedSetWithValues(1, 2, 3, 4)
+	c := NewSortedSetWithValues(1, 2, 5)
+
+	require.True(t, a.IsSubsetOf(b))
+	require.False(t, b.IsSubsetOf(a))
+	require.False(t, c.IsSubsetOf(b))
+	require.True(t, b.IsSupersetOf(a))
+	require.True(t, a.IsSubsetOf(a))
+	require.True(t, NewSortedSet[int]().IsSubsetOf(a))
+
+	require.True(t, a.Equal(a.Clone()))
+	require.False(t, a.Equal(b))
+	require.False(t, a.Equal(c))
+
+	clone := a.Clone()
+	clone.Add(10)
+	require.False(t, a.Contains(10))
+}
+
+func TestSortedSet_Concurrent(t *testing.T) {
+	a := NewSortedSet[int]()
+	b := NewSortedSet[int]()
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(2)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				a.Add(g*100 + i)
+				a.Union(b)
+				a.IsSubsetOf(b)
+			}
+		}(g)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				b.Add(g*100 + i)
+				b.Intersection(a)
+				b.Equal(a)
+			}
+		}(g)
+	}
+	wg.Wait()
+	require.Equal(t, 400, a.Size())
+	require.True(t, a.Equal(b))
+	require.True(t, slices.IsSorted(a.ToSlice()))
+}
+
+func TestSortedSet_AlgebraConcurrent(t *testing.T) {
+	a := NewSortedSetWithValues(1, 2, 3)
+	b := NewSortedSetWithValues(2, 3, 4)
+
+	var wg sync.WaitGroup
+	run := func(fn func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 300; i++ {
+				fn(i)
+			}
+		}()
+	}
+
+	// writers
+	run(func(i int) { a.Add(i % 10); a.Remove((i + 5) % 10) })
+	run(func(i int) { b.Add(i % 10); b.PopMin() })
+
+	// the same operations with their arguments in opposite orders
+	run(func(int) { a.Union(b); b.Union(a) })
+	run(func(int) { a.Intersection(b); b.Difference(a) })
+	run(func(int) { a.SymmetricDifference(b); b.SymmetricDifference(a) })
+	run(func(int) { a.IsSubsetOf(b); b.IsSupersetOf(a); a.Equal(b); b.Equal(a) })
+	wg.Wait()
+
+	require.True(t, a.Union(b).Equal(b.Union(a)))
+}
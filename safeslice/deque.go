@@ -0,0 +1,328 @@
+package safeslice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrFull is returned when pushing to a bounded Deque that is at capacity.
+	ErrFull = errors.New("deque is full")
+	// ErrClosed is returned when pushing to a closed Deque, or taking from a
+	// closed Deque that has been drained.
+	ErrClosed = errors.New("deque is closed")
+)
+
+// minDequeCapacity is the smallest backing array a Deque allocates.
+const minDequeCapacity = 8
+
+// Deque is a thread-safe double-ended queue backed by a ring buffer.
+// The buffer grows as needed and shrinks again when the deque empties, so
+// popped elements never pin memory. A Deque may be bounded, in which case
+// pushes fail with ErrFull and Put blocks while it is at capacity.
+//
+// Take and Put block until they can proceed, the context is done or the deque
+// is closed, which makes a Deque usable as a work queue between goroutines.
+type Deque[T any] struct {
+	mu       sync.Mutex
+	buf      []T
+	head     int // index of the front element in buf
+	size     int
+	capacity int // 0 means unbounded
+	closed   bool
+	notEmpty chan struct{} // closed when an element is added or the deque is closed
+	notFull  chan struct{} // closed when an element is removed or the deque is closed
+}
+
+// NewDeque creates a new unbounded Deque.
+func NewDeque[T any]() *Deque[T] {
+	return &Deque[T]{}
+}
+
+// NewBoundedDeque creates a new Deque that holds at most capacity elements.
+// It panics if capacity is not positive.
+func NewBoundedDeque[T any](capacity int) *Deque[T] {
+	if capacity <= 0 {
+		panic(fmt.Sprintf("safeslice: Deque capacity must be positive, got %d", capacity))
+	}
+	return &Deque[T]{capacity: capacity}
+}
+
+// PushBack adds an element to the back of the Deque.
+// It returns ErrClosed if the Deque is closed and ErrFull if it is at capacity.
+func (d *Deque[T]) PushBack(x T) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkPush(); err != nil {
+		return err
+	}
+	d.pushBack(x)
+	return nil
+}
+
+// PushFront adds an element to the front of the Deque.
+// It returns ErrClosed if the Deque is closed and ErrFull if it is at capacity.
+func (d *Deque[T]) PushFront(x T) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.checkPush(); err != nil {
+		return err
+	}
+	d.grow()
+	d.head = (d.head - 1 + len(d.buf)) % len(d.buf)
+	d.buf[d.head] = x
+	d.size++
+	broadcast(&d.notEmpty)
+	return nil
+}
+
+// PopFront removes and returns the element at the front of the Deque.
+// The boolean is false if the Deque is empty.
+func (d *Deque[T]) PopFront() (T, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.size == 0 {
+		var zero T
+		return zero, false
+	}
+	return d.popFront(), true
+}
+
+// PopBack removes and returns the element at the back of the Deque.
+// The boolean is false if the Deque is empty.
+func (d *Deque[T]) PopBack() (T, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var zero T
+	if d.size == 0 {
+		return zero, false
+	}
+	i := (d.head + d.size - 1) % len(d.buf)
+	x := d.buf[i]
+	d.buf[i] = zero
+	d.size--
+	d.afterPop()
+	return x, true
+}
+
+// PeekFront returns the element at the front of the Deque without removing it.
+// The boolean is false if the Deque is empty.
+func (d *Deque[T]) PeekFront() (T, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.size == 0 {
+		var zero T
+		return zero, false
+	}
+	return d.buf[d.head], true
+}
+
+// PeekBack returns the element at the back of the Deque without removing it.
+// The boolean is false if the Deque is empty.
+func (d *Deque[T]) PeekBack() (T, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.size == 0 {
+		var zero T
+		return zero, false
+	}
+	return d.buf[(d.head+d.size-1)%len(d.buf)], true
+}
+
+// Put adds an element to the back of the Deque, waiting while it is at capacity.
+// It returns ErrClosed if the Deque is or becomes closed, and the context's
+// error if ctx is done first.
+func (d *Deque[T]) Put(ctx context.Context, x T) error {
+	d.mu.Lock()
+	for d.capacity > 0 && d.size >= d.capacity && !d.closed {
+		ch := wait(&d.notFull)
+		d.mu.Unlock()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		d.mu.Lock()
+	}
+	defer d.mu.Unlock()
+	if d.closed {
+		return ErrClosed
+	}
+	d.pushBack(x)
+	return nil
+}
+
+// Take removes and returns the element at the front of the Deque, waiting
+// while it is empty. Elements left in a closed Deque can still be taken; once
+// it is drained Take returns ErrClosed. It returns the context's error if ctx
+// is done first.
+func (d *Deque[T]) Take(ctx context.Context) (T, error) {
+	var zero T
+	d.mu.Lock()
+	for d.size == 0 {
+		if d.closed {
+			d.mu.Unlock()
+			return zero, ErrClosed
+		}
+		ch := wait(&d.notEmpty)
+		d.mu.Unlock()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+		d.mu.Lock()
+	}
+	defer d.mu.Unlock()
+	return d.popFront(), nil
+}
+
+// Close closes the Deque. Further pushes fail with ErrClosed, and blocked Put
+// and Take calls are woken up. Elements already in the Deque can still be
+// popped or taken. Closing a closed Deque has no effect.
+func (d *Deque[T]) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	d.closed = true
+	broadcast(&d.notEmpty)
+	broadcast(&d.notFull)
+}
+
+// IsClosed returns true if the Deque has been closed.
+func (d *Deque[T]) IsClosed() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.closed
+}
+
+// Len returns the number of elements in the Deque.
+func (d *Deque[T]) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.size
+}
+
+// IsEmpty returns true if the Deque is empty.
+func (d *Deque[T]) IsEmpty() bool {
+	return d.Len() == 0
+}
+
+// Cap returns the maximum number of elements of a bounded Deque, or 0 if the
+// Deque is unbounded.
+func (d *Deque[T]) Cap() int {
+	return d.capacity
+}
+
+// Clear removes all elements from the Deque and releases the backing array.
+func (d *Deque[T]) Clear() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.buf = nil
+	d.head = 0
+	d.size = 0
+	broadcast(&d.notFull)
+}
+
+// Export returns a new slice containing the elements of the Deque from front to back.
+func (d *Deque[T]) Export() []T {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	exported := make([]T, d.size)
+	d.copyTo(exported)
+	return exported
+}
+
+// checkPush returns the error a push would fail with, if any.
+func (d *Deque[T]) checkPush() error {
+	if d.closed {
+		return ErrClosed
+	}
+	if d.capacity > 0 && d.size >= d.capacity {
+		return ErrFull
+	}
+	return nil
+}
+
+// pushBack adds an element to the back. The caller must hold the lock and
+// have checked the capacity.
+func (d *Deque[T]) pushBack(x T) {
+	d.grow()
+	d.buf[(d.head+d.size)%len(d.buf)] = x
+	d.size++
+	broadcast(&d.notEmpty)
+}
+
+// popFront removes the front element. The caller must hold the lock and the
+// Deque must not be empty.
+func (d *Deque[T]) popFront() T {
+	var zero T
+	x := d.buf[d.head]
+	d.buf[d.head] = zero
+	d.head = (d.head + 1) % len(d.buf)
+	d.size--
+	d.afterPop()
+	return x
+}
+
+// afterPop shrinks the buffer if it is mostly empty and wakes blocked producers.
+func (d *Deque[T]) afterPop() {
+	if len(d.buf) > minDequeCapacity && d.size <= len(d.buf)/4 {
+		d.resize(len(d.buf) / 2)
+	}
+	broadcast(&d.notFull)
+}
+
+// grow makes room for one more element.
+func (d *Deque[T]) grow() {
+	if d.size < len(d.buf) {
+		return
+	}
+	n := max(2*len(d.buf), minDequeCapacity)
+	if d.capacity > 0 {
+		n = min(n, d.capacity)
+	}
+	d.resize(n)
+}
+
+// resize moves the elements to a new backing array of length n.
+func (d *Deque[T]) resize(n int) {
+	buf := make([]T, n)
+	d.copyTo(buf)
+	d.buf = buf
+	d.head = 0
+}
+
+// copyTo copies the elements from front to back into dst.
+func (d *Deque[T]) copyTo(dst []T) {
+	if d.size == 0 {
+		return
+	}
+	if d.head+d.size <= len(d.buf) {
+		copy(dst, d.buf[d.head:d.head+d.size])
+		return
+	}
+	n := copy(dst, d.buf[d.head:])
+	copy(dst[n:], d.buf[:d.size-n])
+}
+
+// wait returns a channel that is closed by the next broadcast on ch.
+// The caller must hold the lock.
+func wait(ch *chan struct{}) <-chan struct{} {
+	if *ch == nil {
+		*ch = make(chan struct{})
+	}
+	return *ch
+}
+
+// broadcast wakes every goroutine waiting on ch. The caller must hold the lock.
+func broadcast(ch *chan struct{}) {
+	if *ch != nil {
+		close(*ch)
+		*ch = nil
+	}
+}
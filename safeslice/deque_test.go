@@ -0,0 +1,210 @@
+package safeslice
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeque_PushPop(t *testing.T) {
+	d := NewDeque[int]()
+	_, ok := d.PopFront()
+	require.False(t, ok)
+	_, ok = d.PopBack()
+	require.False(t, ok)
+
+	require.NoError(t, d.PushBack(2))
+	require.NoError(t, d.PushBack(3))
+	require.NoError(t, d.PushFront(1))
+	require.NoError(t, d.PushFront(0))
+	require.Equal(t, []int{0, 1, 2, 3}, d.Export())
+
+	front, ok := d.PeekFront()
+	require.True(t, ok)
+	require.Equal(t, 0, front)
+	back, ok := d.PeekBack()
+	require.True(t, ok)
+	require.Equal(t, 3, back)
+
+	x, ok := d.PopFront()
+	require.True(t, ok)
+	require.Equal(t, 0, x)
+	x, ok = d.PopBack()
+	require.True(t, ok)
+	require.Equal(t, 3, x)
+	require.Equal(t, 2, d.Len())
+}
+
+func TestDeque_ZeroValueElements(t *testing.T) {
+	d := NewDeque[int]()
+	require.NoError(t, d.PushBack(0))
+	x, ok := d.PopFront()
+	require.True(t, ok)
+	require.Equal(t, 0, x)
+	_, ok = d.PopFront()
+	require.False(t, ok)
+}
+
+func TestDeque_GrowAndShrink(t *testing.T) {
+	d := NewDeque[int]()
+	// interleave pushes and pops so the ring wraps around while growing
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, d.PushBack(i))
+		if i%3 == 0 {
+			require.NoError(t, d.PushFront(-i))
+			_, ok := d.PopFront()
+			require.True(t, ok)
+		}
+	}
+	require.Equal(t, 1000, d.Len())
+	require.GreaterOrEqual(t, len(d.buf), 1000)
+
+	for i := 0; i < 1000; i++ {
+		x, ok := d.PopFront()
+		require.True(t, ok)
+		require.Equal(t, i, x)
+	}
+	require.True(t, d.IsEmpty())
+	require.Equal(t, minDequeCapacity, len(d.buf))
+}
+
+func TestDeque_Bounded(t *testing.T) {
+	require.Panics(t, func() { NewBoundedDeque[int](0) })
+
+	d := NewBoundedDeque[string](2)
+	require.Equal(t, 2, d.Cap())
+	require.NoError(t, d.PushBack("a"))
+	require.NoError(t, d.PushFront("b"))
+	require.ErrorIs(t, d.PushBack("c"), ErrFull)
+	require.ErrorIs(t, d.PushFront("c"), ErrFull)
+	require.Equal(t, 2, len(d.buf))
+	require.Equal(t, []string{"b", "a"}, d.Export())
+}
+
+func TestDeque_TakeWaitsForPut(t *testing.T) {
+	d := NewDeque[int]()
+	result := make(chan int)
+	go func() {
+		x, err := d.Take(context.Background())
+		require.NoError(t, err)
+		result <- x
+	}()
+
+	select {
+	case <-result:
+		t.Fatal("Take returned before an element was added")
+	case <-time.After(20 * time.Millisecond):
+	}
+	require.NoError(t, d.Put(context.Background(), 42))
+	require.Equal(t, 42, <-result)
+}
+
+func TestDeque_PutWaitsForTake(t *testing.T) {
+	d := NewBoundedDeque[int](1)
+	require.NoError(t, d.Put(context.Background(), 1))
+
+	done := make(chan error)
+	go func() {
+		done <- d.Put(context.Background(), 2)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("Put returned while the deque was full")
+	case <-time.After(20 * time.Millisecond):
+	}
+	x, ok := d.PopFront()
+	require.True(t, ok)
+	require.Equal(t, 1, x)
+	require.NoError(t, <-done)
+	require.Equal(t, []int{2}, d.Export())
+}
+
+func TestDeque_ContextCancel(t *testing.T) {
+	d := NewBoundedDeque[int](1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := d.Take(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	require.NoError(t, d.PushBack(1))
+	require.ErrorIs(t, d.Put(ctx, 2), context.DeadlineExceeded)
+	require.Equal(t, []int{1}, d.Export())
+}
+
+func TestDeque_Close(t *testing.T) {
+	d := NewBoundedDeque[int](2)
+	require.NoError(t, d.PushBack(1))
+	require.NoError(t, d.PushBack(2))
+
+	blocked := make(chan error)
+	go func() {
+		blocked <- d.Put(context.Background(), 3)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	d.Close()
+	d.Close()
+	require.True(t, d.IsClosed())
+	require.ErrorIs(t, <-blocked, ErrClosed)
+	require.ErrorIs(t, d.PushBack(3), ErrClosed)
+
+	// remaining elements are drained before Take reports the close
+	ctx := context.Background()
+	x, err := d.Take(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, x)
+	x, err = d.Take(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, x)
+	_, err = d.Take(ctx)
+	require.ErrorIs(t, err, ErrClosed)
+}
+
+func TestDeque_ProducersConsumers(t *testing.T) {
+	d := NewBoundedDeque[int](4)
+	ctx := context.Background()
+	const producers, perProducer = 4, 250
+
+	var consumed sync.Map
+	var consumers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		consumers.Add(1)
+		go func() {
+			defer consumers.Done()
+			for {
+				x, err := d.Take(ctx)
+				if err != nil {
+					require.ErrorIs(t, err, ErrClosed)
+					return
+				}
+				_, dup := consumed.LoadOrStore(x, true)
+				require.False(t, dup)
+			}
+		}()
+	}
+
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < perProducer; i++ {
+				require.NoError(t, d.Put(ctx, p*perProducer+i))
+			}
+		}(p)
+	}
+	wg.Wait()
+	d.Close()
+	consumers.Wait()
+
+	count := 0
+	consumed.Range(func(_, _ any) bool {
+		count++
+		return true
+	})
+	require.Equal(t, producers*perProducer, count)
+}
@@ -0,0 +1,73 @@
+package safeslice
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"encoding/json"
+)
+
+var (
+	_ json.Marshaler             = (*SafeSlice[int])(nil)
+	_ json.Unmarshaler           = (*SafeSlice[int])(nil)
+	_ gob.GobEncoder             = (*SafeSlice[int])(nil)
+	_ gob.GobDecoder             = (*SafeSlice[int])(nil)
+	_ encoding.BinaryMarshaler   = (*SafeSlice[int])(nil)
+	_ encoding.BinaryUnmarshaler = (*SafeSlice[int])(nil)
+)
+
+// MarshalJSON implements json.Marshaler. The SafeSlice is encoded as a JSON array.
+func (s *SafeSlice[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Export())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+// It atomically replaces the elements of the SafeSlice.
+func (s *SafeSlice[T]) UnmarshalJSON(data []byte) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		return nil
+	}
+	var elements []T
+	if err := json.Unmarshal(data, &elements); err != nil {
+		return err
+	}
+	s.replace(elements)
+	return nil
+}
+
+// GobEncode implements gob.GobEncoder.
+func (s *SafeSlice[T]) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s.Export()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode implements gob.GobDecoder.
+// It atomically replaces the elements of the SafeSlice.
+func (s *SafeSlice[T]) GobDecode(data []byte) error {
+	var elements []T
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&elements); err != nil {
+		return err
+	}
+	s.replace(elements)
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler using the gob encoding.
+func (s *SafeSlice[T]) MarshalBinary() ([]byte, error) {
+	return s.GobEncode()
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler using the gob encoding.
+func (s *SafeSlice[T]) UnmarshalBinary(data []byte) error {
+	return s.GobDecode(data)
+}
+
+// replace atomically replaces the elements of the SafeSlice.
+func (s *SafeSlice[T]) replace(elements []T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.slice = elements
+}
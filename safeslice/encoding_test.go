@@ -0,0 +1,75 @@
+package safeslice
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSafeSlice_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		elements []int
+		expected string
+	}{
+		{"EmptySlice", []int{}, "[]"},
+		{"IntSlice", []int{3, 1, 2}, "[3,1,2]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(NewSafeSliceFromSlice(tt.elements))
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, string(data))
+		})
+	}
+}
+
+func TestSafeSlice_UnmarshalJSON(t *testing.T) {
+	s := NewSafeSliceFromSlice([]string{"old"})
+	require.NoError(t, json.Unmarshal([]byte(`["a","b"]`), s))
+	require.Equal(t, []string{"a", "b"}, s.Export())
+
+	require.Error(t, json.Unmarshal([]byte(`[1]`), s))
+	require.Equal(t, []string{"a", "b"}, s.Export())
+}
+
+func TestSafeSlice_JSONEmbedded(t *testing.T) {
+	type queue struct {
+		Jobs *SafeSlice[string]
+	}
+	in := queue{Jobs: NewSafeSliceFromSlice([]string{"a", "b"})}
+	data, err := json.Marshal(in)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"Jobs":["a","b"]}`, string(data))
+
+	var out queue
+	require.NoError(t, json.Unmarshal(data, &out))
+	require.Equal(t, []string{"a", "b"}, out.Jobs.Export())
+}
+
+func TestSafeSlice_Gob(t *testing.T) {
+	type state struct {
+		Values *SafeSlice[float64]
+	}
+	in := state{Values: NewSafeSliceFromSlice([]float64{1.5, 2.5})}
+	var buf bytes.Buffer
+	require.NoError(t, gob.NewEncoder(&buf).Encode(in))
+
+	var out state
+	require.NoError(t, gob.NewDecoder(&buf).Decode(&out))
+	require.Equal(t, in.Values.Export(), out.Values.Export())
+}
+
+func TestSafeSlice_Binary(t *testing.T) {
+	s := NewSafeSliceFromSlice([]int{1, 2, 3})
+	data, err := s.MarshalBinary()
+	require.NoError(t, err)
+
+	decoded := NewSafeSliceFromSlice([]int{9})
+	require.NoError(t, decoded.UnmarshalBinary(data))
+	require.Equal(t, []int{1, 2, 3}, decoded.Export())
+}
@@ -2,6 +2,7 @@ package safeslice
 
 import (
 	"errors"
+	"iter"
 	"sort"
 	"sync"
 )
@@ -81,7 +82,67 @@ func (s *SafeSlice[T]) Values() []T {
 	return s.slice
 }
 
-// Range ?
+// Iter returns an iterator over the indices and elements of the SafeSlice,
+// from first to last.
+//
+// The elements are copied when iteration starts, so the sequence reflects a
+// single consistent state and no lock is held while the loop body runs: the
+// body may freely read or modify the SafeSlice. To iterate without the copy,
+// use IterLocked.
+func (s *SafeSlice[T]) Iter() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		for i, e := range s.Export() {
+			if !yield(i, e) {
+				return
+			}
+		}
+	}
+}
+
+// Backward returns an iterator over the indices and elements of the SafeSlice,
+// from last to first. Like Iter, it iterates a copy; BackwardLocked does not.
+func (s *SafeSlice[T]) Backward() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		elems := s.Export()
+		for i := len(elems) - 1; i >= 0; i-- {
+			if !yield(i, elems[i]) {
+				return
+			}
+		}
+	}
+}
+
+// IterLocked returns an iterator over the indices and elements of the
+// SafeSlice, from first to last, that holds the lock for the whole loop instead
+// of copying the elements. It allocates nothing, but every other caller waits
+// until the loop ends, and the loop body must not call any method on the
+// SafeSlice (that deadlocks).
+func (s *SafeSlice[T]) IterLocked() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i, e := range s.slice {
+			if !yield(i, e) {
+				return
+			}
+		}
+	}
+}
+
+// BackwardLocked returns an iterator over the indices and elements of the
+// SafeSlice, from last to first. Like IterLocked, it holds the lock for the
+// whole loop.
+func (s *SafeSlice[T]) BackwardLocked() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for i := len(s.slice) - 1; i >= 0; i-- {
+			if !yield(i, s.slice[i]) {
+				return
+			}
+		}
+	}
+}
 
 // Clear removes all elements from the SafeSlice.
 func (s *SafeSlice[T]) Clear() {
@@ -148,6 +209,7 @@ func (s *SafeSlice[T]) Pop() T {
 
 // PopFront removes and returns the first element from the SafeSlice.
 // If the SafeSlice is empty, it returns the zero value of the element type.
+// For queue workloads use Deque, which reports empty pops and reclaims memory.
 func (s *SafeSlice[T]) PopFront() T {
 	s.mu.Lock()
 	defer s.mu.Unlock()
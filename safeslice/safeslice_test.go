@@ -1315,3 +1315,128 @@ func TestSafeSlice_Copy(t *testing.T) {
 		})
 	}
 }
+
+func TestSafeSlice_Iter(t *testing.T) {
+	tests := []struct {
+		name            string
+		elements        []int
+		expectedIndices []int
+		expectedValues  []int
+	}{
+		{"EmptySlice", []int{}, nil, nil},
+		{"IntSlice", []int{10, 20, 30}, []int{0, 1, 2}, []int{10, 20, 30}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSafeSliceFromSlice(tt.elements)
+			var indices, values []int
+			for i, e := range s.Iter() {
+				indices = append(indices, i)
+				values = append(values, e)
+			}
+			require.Equal(t, tt.expectedIndices, indices)
+			require.Equal(t, tt.expectedValues, values)
+		})
+	}
+}
+
+func TestSafeSlice_Backward(t *testing.T) {
+	tests := []struct {
+		name            string
+		elements        []int
+		expectedIndices []int
+		expectedValues  []int
+	}{
+		{"EmptySlice", []int{}, nil, nil},
+		{"IntSlice", []int{10, 20, 30}, []int{2, 1, 0}, []int{30, 20, 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSafeSliceFromSlice(tt.elements)
+			var indices, values []int
+			for i, e := range s.Backward() {
+				indices = append(indices, i)
+				values = append(values, e)
+			}
+			require.Equal(t, tt.expectedIndices, indices)
+			require.Equal(t, tt.expectedValues, values)
+		})
+	}
+}
+
+func TestSafeSlice_IterBreak(t *testing.T) {
+	s := NewSafeSliceFromSlice([]int{1, 2, 3})
+	for _, e := range s.Iter() {
+		if e == 2 {
+			break
+		}
+	}
+	for range s.Backward() {
+		break
+	}
+
+	// the slice is usable after breaking out of the loop
+	s.Append(4)
+	require.Equal(t, 4, s.Len())
+}
+
+func TestSafeSlice_IterLocked(t *testing.T) {
+	s := NewSafeSliceFromSlice([]int{10, 20, 30})
+	var indices, values []int
+	for i, e := range s.IterLocked() {
+		indices = append(indices, i)
+		values = append(values, e)
+	}
+	require.Equal(t, []int{0, 1, 2}, indices)
+	require.Equal(t, []int{10, 20, 30}, values)
+
+	indices, values = nil, nil
+	for i, e := range s.BackwardLocked() {
+		indices = append(indices, i)
+		values = append(values, e)
+	}
+	require.Equal(t, []int{2, 1, 0}, indices)
+	require.Equal(t, []int{30, 20, 10}, values)
+
+	for range s.IterLocked() {
+		break
+	}
+	for range s.BackwardLocked() {
+		break
+	}
+	// the lock is released after breaking out of the loop
+	s.Append(40)
+	require.Equal(t, 4, s.Len())
+
+	allocs := testing.AllocsPerRun(10, func() {
+		for range s.IterLocked() {
+		}
+		for range s.BackwardLocked() {
+		}
+	})
+	require.Zero(t, allocs)
+}
+
+func TestSafeSlice_IterCallsBack(t *testing.T) {
+	s := NewSafeSliceFromSlice([]int{1, 2, 3})
+
+	// the loop body may use the slice; it sees the elements of the copy
+	var values []int
+	for i, e := range s.Iter() {
+		require.Equal(t, e, s.Get(i).Element)
+		s.Append(e * 10)
+		values = append(values, e)
+	}
+	require.Equal(t, []int{1, 2, 3}, values)
+	require.Equal(t, 6, s.Len())
+
+	values = nil
+	for _, e := range s.Backward() {
+		values = append(values, e)
+		s.Append(e)
+	}
+	require.Equal(t, []int{30, 20, 10, 3, 2, 1}, values)
+	require.Equal(t, 12, s.Len())
+}